@@ -0,0 +1,145 @@
+package apperrors
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Code identifies an error to API clients. Codes are part of the API: never rename one, add a
+// new code instead.
+type Code string
+
+const (
+	// Requests
+	InvalidRequest   Code = "invalid_request"
+	ValidationFailed Code = "validation_failed"
+	InternalError    Code = "internal_error"
+	UpstreamError    Code = "upstream_error"
+
+	// Authentication
+	Unauthenticated        Code = "unauthenticated"
+	InvalidToken           Code = "invalid_token"
+	SessionUserNotFound    Code = "session_user_not_found"
+	ImpersonatorNotFound   Code = "impersonator_not_found"
+	ImpersonatorNotAdmin   Code = "impersonator_not_admin"
+	InvalidApiKey          Code = "invalid_api_key"
+	UnknownEmail           Code = "unknown_email"
+	InvalidPassword        Code = "invalid_password"
+	InvalidCredentials     Code = "invalid_credentials"
+	AccountDisabled        Code = "account_disabled"
+	PasswordExpired        Code = "password_expired"
+	UnknownProvider        Code = "unknown_provider"
+	InvalidLoginSession    Code = "invalid_login_session"
+	ExternalLoginFailed    Code = "external_login_failed"
+	UnverifiedEmail        Code = "unverified_email"
+	InvalidWebAuthnSession Code = "invalid_webauthn_session"
+	WebAuthnFailed         Code = "webauthn_failed"
+	SecurityKeyRejected    Code = "security_key_rejected"
+	NoSecurityKey          Code = "no_security_key"
+	ClonedSecurityKey      Code = "cloned_security_key"
+	SecurityKeyRequired    Code = "security_key_required"
+
+	// Authorization
+	Forbidden               Code = "forbidden"
+	ApiKeyScope             Code = "api_key_scope"
+	ApiKeyNotAllowed        Code = "api_key_not_allowed"
+	ImpersonationNotAllowed Code = "impersonation_not_allowed"
+	SelfImpersonation       Code = "self_impersonation"
+	PrivilegedImpersonation Code = "privileged_impersonation"
+	WrongOrganization       Code = "wrong_organization"
+	OrganizationDisabled    Code = "organization_disabled"
+
+	// Passwords
+	PasswordMismatch Code = "password_mismatch"
+	PasswordPolicy   Code = "password_policy"
+
+	// Resources
+	UserNotFound            Code = "user_not_found"
+	LevelNotFound           Code = "level_not_found"
+	GroupNotFound           Code = "group_not_found"
+	ParentGroupNotFound     Code = "parent_group_not_found"
+	UnknownGroupManager     Code = "unknown_group_manager"
+	MembershipNotFound      Code = "membership_not_found"
+	InvalidMembershipPeriod Code = "invalid_membership_period"
+	OrganizationNotFound    Code = "organization_not_found"
+	ApiKeyNotFound          Code = "api_key_not_found"
+	CredentialNotFound      Code = "credential_not_found"
+
+	// Conflicts
+	EmailTaken           Code = "email_taken"
+	SlugTaken            Code = "slug_taken"
+	GroupCycle           Code = "group_cycle"
+	GroupHasChildren     Code = "group_has_children"
+	OrganizationHasUsers Code = "organization_has_users"
+	DefaultOrganization  Code = "default_organization"
+)
+
+// statuses holds the HTTP status of every code. The messages are in the i18n catalogues.
+var statuses = map[Code]int{
+	InvalidRequest:   http.StatusBadRequest,
+	ValidationFailed: http.StatusUnprocessableEntity,
+	InternalError:    http.StatusInternalServerError,
+	UpstreamError:    http.StatusBadGateway,
+
+	Unauthenticated:        http.StatusUnauthorized,
+	InvalidToken:           http.StatusUnauthorized,
+	SessionUserNotFound:    http.StatusUnauthorized,
+	ImpersonatorNotFound:   http.StatusUnauthorized,
+	ImpersonatorNotAdmin:   http.StatusUnauthorized,
+	InvalidApiKey:          http.StatusUnauthorized,
+	UnknownEmail:           http.StatusUnauthorized,
+	InvalidPassword:        http.StatusUnauthorized,
+	InvalidCredentials:     http.StatusUnauthorized,
+	AccountDisabled:        http.StatusForbidden,
+	PasswordExpired:        http.StatusForbidden,
+	UnknownProvider:        http.StatusNotFound,
+	InvalidLoginSession:    http.StatusBadRequest,
+	ExternalLoginFailed:    http.StatusUnauthorized,
+	UnverifiedEmail:        http.StatusForbidden,
+	InvalidWebAuthnSession: http.StatusBadRequest,
+	WebAuthnFailed:         http.StatusUnauthorized,
+	SecurityKeyRejected:    http.StatusBadRequest,
+	NoSecurityKey:          http.StatusBadRequest,
+	ClonedSecurityKey:      http.StatusUnauthorized,
+	SecurityKeyRequired:    http.StatusForbidden,
+
+	Forbidden:               http.StatusForbidden,
+	ApiKeyScope:             http.StatusForbidden,
+	ApiKeyNotAllowed:        http.StatusForbidden,
+	ImpersonationNotAllowed: http.StatusForbidden,
+	SelfImpersonation:       http.StatusUnprocessableEntity,
+	PrivilegedImpersonation: http.StatusForbidden,
+	WrongOrganization:       http.StatusForbidden,
+	OrganizationDisabled:    http.StatusForbidden,
+
+	PasswordMismatch: http.StatusUnprocessableEntity,
+	PasswordPolicy:   http.StatusUnprocessableEntity,
+
+	UserNotFound:            http.StatusNotFound,
+	LevelNotFound:           http.StatusNotFound,
+	GroupNotFound:           http.StatusNotFound,
+	ParentGroupNotFound:     http.StatusUnprocessableEntity,
+	UnknownGroupManager:     http.StatusUnprocessableEntity,
+	MembershipNotFound:      http.StatusNotFound,
+	InvalidMembershipPeriod: http.StatusUnprocessableEntity,
+	OrganizationNotFound:    http.StatusNotFound,
+	ApiKeyNotFound:          http.StatusNotFound,
+	CredentialNotFound:      http.StatusNotFound,
+
+	EmailTaken:           http.StatusConflict,
+	SlugTaken:            http.StatusConflict,
+	GroupCycle:           http.StatusConflict,
+	GroupHasChildren:     http.StatusConflict,
+	OrganizationHasUsers: http.StatusConflict,
+	DefaultOrganization:  http.StatusConflict,
+}
+
+// Codes lists every known code.
+func Codes() []Code {
+	codes := make([]Code, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
@@ -0,0 +1,106 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Error is an error that can be shown to API clients. Err is the underlying cause; it is
+// logged but never sent.
+type Error struct {
+	Code    Code
+	Status  int
+	Details interface{}
+	Err     error
+}
+
+// FieldError describes one invalid field of a request body. Message is filled in the locale of
+// the response when it is written.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	cause validator.FieldError
+}
+
+// Cause returns the validator error the field was made from, nil for decoding errors.
+func (field FieldError) Cause() validator.FieldError {
+	return field.cause
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns the error for code with its status.
+func New(code Code) *Error {
+	status, found := statuses[code]
+	if !found {
+		status = http.StatusInternalServerError
+	}
+	return &Error{Code: code, Status: status}
+}
+
+// Wrap returns the error for code, keeping err as its cause.
+func Wrap(code Code, err error) *Error {
+	appErr := New(code)
+	appErr.Err = err
+	return appErr
+}
+
+// Internal hides err behind internal_error.
+func Internal(err error) *Error {
+	return Wrap(InternalError, err)
+}
+
+// WithDetails adds machine-readable details, e.g. the violated password rules.
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// From returns err when it already is an *Error and an internal_error otherwise.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
+
+// Binding is the error for a request body that could not be decoded. Fields of the wrong JSON
+// type are listed in the details.
+func Binding(err error) *Error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Wrap(InvalidRequest, err).WithDetails([]FieldError{{Field: typeErr.Field, Rule: "type", Param: typeErr.Type.String()}})
+	}
+	return Wrap(InvalidRequest, err)
+}
+
+// Validation is the error for a request body that validator rejected, with one FieldError per
+// failed rule.
+func Validation(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return Wrap(InvalidRequest, err)
+	}
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields = append(fields, FieldError{Field: fieldErr.Field(), Rule: fieldErr.Tag(), Param: fieldErr.Param(), cause: fieldErr})
+	}
+	return Wrap(ValidationFailed, err).WithDetails(fields)
+}
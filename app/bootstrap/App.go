@@ -0,0 +1,254 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/controllers"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/i18n"
+	"github.com/RadenAbror/UserManagement/app/migrations"
+	"github.com/RadenAbror/UserManagement/app/openapi"
+	"github.com/RadenAbror/UserManagement/app/routes"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// These errors tell main which stage of startup failed, so it can exit with a matching status.
+var (
+	ErrConfig    = errors.New("invalid configuration")
+	ErrDatabase  = errors.New("database unavailable")
+	ErrMigration = errors.New("database migration failed")
+	ErrServer    = errors.New("http server failed")
+)
+
+// App owns everything the service needs at runtime and releases it again on shutdown.
+type App struct {
+	Config config.Config
+	Client *mongo.Client
+	Server *http.Server
+
+	// router serves the requests. Its handlers keep the configuration they were built with, so a
+	// reload takes effect by building a new router and swapping it in.
+	router          atomic.Pointer[gin.Engine]
+	routerMu        sync.Mutex
+	shutdownTracing func(context.Context) error
+}
+
+// New loads and validates the configuration, publishes it through config.Get and connects to
+// MongoDB. The router is only built by Run, so one-off commands such as migrate can use the
+// connection without starting the HTTP side.
+func New(ctx context.Context) (*App, error) {
+	// JSON logs from the start, so configuration errors are structured too
+	slog.SetDefault(helpers.NewLogger(slog.LevelInfo, "json"))
+
+	appConfig, err := config.LoadConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+
+	if err := appConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+
+	if err := i18n.Check(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+
+	logLevel, _ := appConfig.ParseLogLevel()
+	slog.SetDefault(helpers.NewLogger(logLevel, appConfig.LogFormat))
+
+	if err := config.ConfigurePasswordHashing(appConfig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+
+	config.Set(appConfig)
+
+	shutdownTracing, err := config.SetupTracing(ctx, appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+
+	connectTimeout := appConfig.DBConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = time.Minute
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	client, err := config.ConnectDB(connectCtx, appConfig.DBUri, helpers.MongoMonitor())
+	if err != nil {
+		shutdownTracing(context.Background())
+		return nil, fmt.Errorf("%w: %v", ErrDatabase, err)
+	}
+	config.DB = client
+
+	app := &App{Config: appConfig, Client: client, shutdownTracing: shutdownTracing}
+	if appConfig.ConfigHotReload {
+		config.Watch(app.reload)
+	}
+	return app, nil
+}
+
+// reload applies a configuration that config.Watch has validated and published.
+func (app *App) reload(reloaded config.Config) {
+	if err := config.ConfigurePasswordHashing(reloaded); err != nil {
+		slog.Warn("keeping previous password hashing settings", "error", err)
+	}
+	if level, err := reloaded.ParseLogLevel(); err == nil {
+		helpers.SetLogLevel(level)
+	}
+
+	app.routerMu.Lock()
+	defer app.routerMu.Unlock()
+
+	// Run builds the first router from the published configuration, which already is reloaded
+	if app.router.Load() == nil {
+		return
+	}
+	router, err := app.buildRouter(reloaded)
+	if err != nil {
+		slog.Warn("keeping previous routes", "error", err)
+		return
+	}
+	app.router.Store(router)
+}
+
+// Migrate applies pending database migrations.
+func (app *App) Migrate(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	applied, err := migrations.Run(ctx, config.GetDatabase(app.Client))
+	for _, migration := range applied {
+		slog.Info("applied migration", "version", migration.Version, "description", migration.Description)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMigration, err)
+	}
+	return nil
+}
+
+// buildRouter registers every route with appConfig and checks that the OpenAPI document describes
+// each of them.
+func (app *App) buildRouter(appConfig config.Config) (*gin.Engine, error) {
+	router := gin.New()
+	router.Use(helpers.UseConfig(appConfig), helpers.Tracing(appConfig.ServiceName()), helpers.RequestID(), helpers.AccessLog(), helpers.Metrics(), helpers.Recovery())
+
+	if origins := appConfig.AllowedOrigins(); len(origins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		corsConfig.AllowOrigins = origins
+		corsConfig.AllowCredentials = true
+		corsConfig.AddAllowHeaders(helpers.RequestIdHeader)
+		corsConfig.AddExposeHeaders(helpers.RequestIdHeader)
+
+		router.Use(cors.New(corsConfig))
+	}
+
+	// registered before the tenant-aware routes, scraping, probes and docs do not depend on an organization
+	router.GET("/metrics", helpers.MetricsHandler())
+	router.GET("/healthz", controllers.Healthz())
+	router.GET("/readyz", controllers.Readyz(appConfig))
+	router.GET("/openapi.json", controllers.OpenApiSpec())
+	router.GET("/docs", controllers.ApiDocs())
+
+	// route link
+	routes.Routes(router, appConfig)
+
+	if err := openapi.Check(router.Routes()); err != nil {
+		return nil, err
+	}
+	return router, nil
+}
+
+// Run serves HTTP until ctx is cancelled, then stops accepting connections and waits for
+// in-flight requests to finish within SHUTDOWN_TIMEOUT.
+func (app *App) Run(ctx context.Context) error {
+	// the published configuration, in case it was reloaded while the migrations ran
+	app.routerMu.Lock()
+	router, err := app.buildRouter(config.Get())
+	if err == nil {
+		app.router.Store(router)
+	}
+	app.routerMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrServer, err)
+	}
+
+	app.Server = &http.Server{
+		Addr:              app.Config.ListenAddr(),
+		Handler:           app,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		var err error
+		if app.Config.TLSEnabled() {
+			slog.Info("listening", "address", "https://"+app.Server.Addr)
+			err = app.Server.ListenAndServeTLS(app.Config.TLSCertFile, app.Config.TLSKeyFile)
+		} else {
+			slog.Info("listening", "address", "http://"+app.Server.Addr)
+			err = app.Server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("%w: %v", ErrServer, err)
+	case <-ctx.Done():
+	}
+
+	// fail readiness first and keep serving for a moment, so no new traffic is routed here
+	// by the time the listener closes
+	helpers.BeginShutdown()
+	if app.Config.ShutdownDelay > 0 {
+		slog.Info("shutting down, failing readiness", "delay", app.Config.ShutdownDelay.String())
+		time.Sleep(app.Config.ShutdownDelay)
+	}
+
+	shutdownTimeout := app.Config.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 15 * time.Second
+	}
+
+	slog.Info("shutting down, draining requests", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := app.Server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("%w: graceful shutdown: %v", ErrServer, err)
+	}
+	return nil
+}
+
+// ServeHTTP hands the request to the current router.
+func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	app.router.Load().ServeHTTP(w, r)
+}
+
+// Close disconnects from MongoDB and flushes the spans not exported yet.
+func (app *App) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := app.Client.Disconnect(ctx); err != nil {
+		slog.Error("could not disconnect from MongoDB", "error", err)
+	}
+	if err := app.shutdownTracing(ctx); err != nil {
+		slog.Error("could not flush traces", "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package bootstrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoutesMatchOpenApiSpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &App{}
+	router, err := app.buildRouter(config.Config{})
+	if err != nil {
+		t.Fatalf("registered routes and the OpenAPI document differ: %v", err)
+	}
+
+	routes := router.Routes()
+	if len(routes) == 0 {
+		t.Fatal("no routes registered")
+	}
+
+	router.GET("/undocumented/:id", func(*gin.Context) {})
+	if err := openapi.Check(router.Routes()); err == nil || !strings.Contains(err.Error(), "undocumented route GET /undocumented/{id}") {
+		t.Errorf("Check() = %v, want the undocumented route reported", err)
+	}
+
+	missing := routes[0]
+	if err := openapi.Check(routes[1:]); err == nil || !strings.Contains(err.Error(), "documented route "+missing.Method+" ") {
+		t.Errorf("Check() = %v, want %s %s reported as not registered", err, missing.Method, missing.Path)
+	}
+}
+
+func TestReloadSwapsRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	app := &App{}
+	app.reload(config.Config{})
+	if app.router.Load() != nil {
+		t.Fatal("reload() built a router before Run")
+	}
+
+	router, err := app.buildRouter(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.router.Store(router)
+
+	app.reload(config.Config{CorsAllowedOrigins: "http://localhost:3000"})
+	if app.router.Load() == router {
+		t.Error("reload() kept the router built with the previous configuration")
+	}
+}
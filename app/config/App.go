@@ -1,37 +1,393 @@
-package config
-
-import (
-	"time"
-
-	"github.com/spf13/viper"
-)
-
-type Config struct {
-	Port                   string        `mapstructure:"PORT"`
-	DBUri                  string        `mapstructure:"MONGOURI"`
-	RedisUri               string        `mapstructure:"REDIS_URL"`
-	AccessTokenPrivateKey  string        `mapstructure:"ACCESS_TOKEN_PRIVATE_KEY"`
-	AccessTokenPublicKey   string        `mapstructure:"ACCESS_TOKEN_PUBLIC_KEY"`
-	RefreshTokenPrivateKey string        `mapstructure:"REFRESH_TOKEN_PRIVATE_KEY"`
-	RefreshTokenPublicKey  string        `mapstructure:"REFRESH_TOKEN_PUBLIC_KEY"`
-	AccessTokenExpiresIn   time.Duration `mapstructure:"ACCESS_TOKEN_EXPIRED_IN"`
-	RefreshTokenExpiresIn  time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRED_IN"`
-	AccessTokenMaxAge      int           `mapstructure:"ACCESS_TOKEN_MAXAGE"`
-	RefreshTokenMaxAge     int           `mapstructure:"REFRESH_TOKEN_MAXAGE"`
-}
-
-func LoadConfig(path string) (config Config, err error) {
-	viper.AddConfigPath(path)
-	viper.SetConfigType("env")
-	viper.SetConfigName("app")
-
-	viper.AutomaticEnv()
-
-	err = viper.ReadInConfig()
-	if err != nil {
-		return
-	}
-
-	err = viper.Unmarshal(&config)
-	return
-}
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type Config struct {
+	Port                   string        `mapstructure:"PORT"`
+	DBUri                  string        `mapstructure:"MONGOURI"`
+	RedisUri               string        `mapstructure:"REDIS_URL"`
+	AccessTokenPrivateKey  string        `mapstructure:"ACCESS_TOKEN_PRIVATE_KEY"`
+	AccessTokenPublicKey   string        `mapstructure:"ACCESS_TOKEN_PUBLIC_KEY"`
+	RefreshTokenPrivateKey string        `mapstructure:"REFRESH_TOKEN_PRIVATE_KEY"`
+	RefreshTokenPublicKey  string        `mapstructure:"REFRESH_TOKEN_PUBLIC_KEY"`
+	AccessTokenExpiresIn   time.Duration `mapstructure:"ACCESS_TOKEN_EXPIRED_IN"`
+	RefreshTokenExpiresIn  time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRED_IN"`
+	AccessTokenMaxAge      int           `mapstructure:"ACCESS_TOKEN_MAXAGE"`
+	RefreshTokenMaxAge     int           `mapstructure:"REFRESH_TOKEN_MAXAGE"`
+
+	Host               string `mapstructure:"HOST"`
+	CorsAllowedOrigins string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	CookieDomain       string `mapstructure:"COOKIE_DOMAIN"`
+	CookieSecure       bool   `mapstructure:"COOKIE_SECURE"`
+	CookieSameSite     string `mapstructure:"COOKIE_SAMESITE"`
+	TLSCertFile        string `mapstructure:"TLS_CERT_FILE"`
+	TLSKeyFile         string `mapstructure:"TLS_KEY_FILE"`
+
+	DBName           string        `mapstructure:"MONGO_DATABASE"`
+	CollectionPrefix string        `mapstructure:"COLLECTION_PREFIX"`
+	DBConnectTimeout time.Duration `mapstructure:"MONGO_CONNECT_TIMEOUT"`
+	ShutdownTimeout  time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDelay    time.Duration `mapstructure:"SHUTDOWN_DELAY"`
+
+	OAuthClients string `mapstructure:"OAUTH_CLIENTS"`
+
+	AuthProviders    string `mapstructure:"AUTH_PROVIDERS"`
+	LDAPUrl          string `mapstructure:"LDAP_URL"`
+	LDAPStartTLS     bool   `mapstructure:"LDAP_START_TLS"`
+	LDAPBindDN       string `mapstructure:"LDAP_BIND_DN"`
+	LDAPBindPassword string `mapstructure:"LDAP_BIND_PASSWORD"`
+	LDAPBaseDN       string `mapstructure:"LDAP_BASE_DN"`
+	LDAPUserFilter   string `mapstructure:"LDAP_USER_FILTER"`
+	LDAPGroupLevels  string `mapstructure:"LDAP_GROUP_LEVELS"`
+	LDAPDefaultLevel string `mapstructure:"LDAP_DEFAULT_LEVEL"`
+	LDAPDefaultGroup string `mapstructure:"LDAP_DEFAULT_GROUP"`
+	LDAPTenant       string `mapstructure:"LDAP_TENANT"`
+
+	RegisterDefaultLevel string `mapstructure:"REGISTER_DEFAULT_LEVEL"`
+	RegisterDefaultGroup string `mapstructure:"REGISTER_DEFAULT_GROUP"`
+
+	ScimToken        string `mapstructure:"SCIM_TOKEN"`
+	ScimTokens       string `mapstructure:"SCIM_TOKENS"`
+	ScimDefaultLevel string `mapstructure:"SCIM_DEFAULT_LEVEL"`
+
+	OAuthProviders     string `mapstructure:"OAUTH_PROVIDERS"`
+	OAuthDefaultLevel  string `mapstructure:"OAUTH_DEFAULT_LEVEL"`
+	OAuthDefaultGroup  string `mapstructure:"OAUTH_DEFAULT_GROUP"`
+	OAuthTenant        string `mapstructure:"OAUTH_TENANT"`
+	OAuthLoginRedirect string `mapstructure:"OAUTH_LOGIN_REDIRECT"`
+
+	WebAuthnRPID           string `mapstructure:"WEBAUTHN_RP_ID"`
+	WebAuthnRPName         string `mapstructure:"WEBAUTHN_RP_NAME"`
+	WebAuthnRPOrigins      string `mapstructure:"WEBAUTHN_RP_ORIGINS"`
+	WebAuthnRequiredLevels string `mapstructure:"WEBAUTHN_REQUIRED_LEVELS"`
+
+	ImpersonationExpiresIn time.Duration `mapstructure:"IMPERSONATION_EXPIRED_IN"`
+
+	DefaultTenant    string `mapstructure:"DEFAULT_TENANT"`
+	TenantBaseDomain string `mapstructure:"TENANT_BASE_DOMAIN"`
+	AdminLevel       string `mapstructure:"ADMIN_LEVEL"`
+	SuperAdminIds    string `mapstructure:"SUPER_ADMIN_IDS"`
+
+	PasswordMinLength       int           `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper    bool          `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower    bool          `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit    bool          `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol   bool          `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordBannedWordsFile string        `mapstructure:"PASSWORD_BANNED_WORDS_FILE"`
+	PasswordBreachedFile    string        `mapstructure:"PASSWORD_BREACHED_FILE"`
+	PasswordHistory         int           `mapstructure:"PASSWORD_HISTORY"`
+	PasswordMaxAge          time.Duration `mapstructure:"PASSWORD_MAX_AGE"`
+
+	PasswordHashAlgorithm string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	PasswordBcryptCost    int    `mapstructure:"PASSWORD_BCRYPT_COST"`
+	PasswordArgon2Memory  uint32 `mapstructure:"PASSWORD_ARGON2_MEMORY"`
+	PasswordArgon2Time    uint32 `mapstructure:"PASSWORD_ARGON2_TIME"`
+	PasswordArgon2Threads uint8  `mapstructure:"PASSWORD_ARGON2_THREADS"`
+
+	ConfigHotReload bool `mapstructure:"CONFIG_HOT_RELOAD"`
+
+	DefaultLocale string `mapstructure:"DEFAULT_LOCALE"`
+
+	LogLevel  string `mapstructure:"LOG_LEVEL"`
+	LogFormat string `mapstructure:"LOG_FORMAT"`
+
+	TracingExporter    string  `mapstructure:"TRACING_EXPORTER"`
+	TracingEndpoint    string  `mapstructure:"TRACING_ENDPOINT"`
+	TracingInsecure    bool    `mapstructure:"TRACING_INSECURE"`
+	TracingSampleRatio float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+	TracingServiceName string  `mapstructure:"TRACING_SERVICE_NAME"`
+
+	// filled by LoadConfig from the OAUTH_<NAME>_* and COLLECTION_<NAME> keys
+	socialProviders map[string]OAuthProvider
+	collections     map[string]string
+}
+
+func LoadConfig(path string) (config Config, err error) {
+	viper.AddConfigPath(path)
+	viper.SetConfigType("env")
+	viper.SetConfigName("app")
+
+	viper.AutomaticEnv()
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+
+	err = viper.ReadInConfig()
+	if err != nil {
+		return
+	}
+
+	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+
+	config.socialProviders = config.readSocialProviders()
+	config.collections = readCollectionNames()
+	return
+}
+
+func readCollectionNames() map[string]string {
+	collections := map[string]string{}
+	for _, name := range collectionNames {
+		if override := viper.GetString("COLLECTION_" + strings.ToUpper(name)); override != "" {
+			collections[name] = override
+		}
+	}
+	return collections
+}
+
+// Validate checks the settings that decide how the service is exposed, so a bad deployment fails
+// at startup instead of on the first request.
+func (config Config) Validate() error {
+	var problems []string
+
+	if port, err := strconv.Atoi(config.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("PORT %q is not a valid port number", config.Port))
+	}
+
+	for _, origin := range config.AllowedOrigins() {
+		if parsed, err := url.Parse(origin); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			problems = append(problems, fmt.Sprintf("CORS_ALLOWED_ORIGINS entry %q is not an origin such as https://app.example.com", origin))
+		}
+	}
+
+	sameSite := strings.ToLower(config.CookieSameSite)
+	switch sameSite {
+	case "", "lax", "strict", "none":
+	default:
+		problems = append(problems, fmt.Sprintf("COOKIE_SAMESITE %q must be lax, strict or none", config.CookieSameSite))
+	}
+	if sameSite == "none" && !config.CookieSecure {
+		problems = append(problems, "COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		problems = append(problems, "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	for _, file := range []string{config.TLSCertFile, config.TLSKeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Sprintf("TLS file %s: %v", file, err))
+		}
+	}
+
+	// the password policy refuses every password while a list it relies on cannot be read
+	for _, list := range [][2]string{{"PASSWORD_BANNED_WORDS_FILE", config.PasswordBannedWordsFile}, {"PASSWORD_BREACHED_FILE", config.PasswordBreachedFile}} {
+		if list[1] == "" {
+			continue
+		}
+		file, err := os.Open(list[1])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", list[0], err))
+			continue
+		}
+		file.Close()
+	}
+
+	if _, err := config.ParseLogLevel(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	switch strings.ToLower(config.LogFormat) {
+	case "", "json", "text":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT %q must be json or text", config.LogFormat))
+	}
+
+	switch config.DefaultLocale {
+	case "", "id", "en":
+	default:
+		problems = append(problems, fmt.Sprintf("DEFAULT_LOCALE %q must be id or en", config.DefaultLocale))
+	}
+
+	switch strings.ToLower(config.TracingExporter) {
+	case "", "none", "otlp", "stdout":
+	default:
+		problems = append(problems, fmt.Sprintf("TRACING_EXPORTER %q must be none, otlp or stdout", config.TracingExporter))
+	}
+	if config.TracingSampleRatio < 0 || config.TracingSampleRatio > 1 {
+		problems = append(problems, fmt.Sprintf("TRACING_SAMPLE_RATIO %v must be between 0 and 1", config.TracingSampleRatio))
+	}
+
+	tokens := map[string]string{}
+	for _, entry := range strings.Split(config.ScimTokens, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		tenant, token, _ := strings.Cut(entry, ":")
+		if tenant == "" || token == "" {
+			problems = append(problems, fmt.Sprintf("SCIM_TOKENS entry %q is not organization:token", entry))
+			continue
+		}
+		if other, taken := tokens[token]; taken {
+			problems = append(problems, fmt.Sprintf("SCIM_TOKENS gives %s and %s the same token", other, tenant))
+		}
+		tokens[token] = tenant
+	}
+
+	problems = append(problems, config.keyProblems()...)
+
+	seen := map[string]string{}
+	for _, name := range collectionNames {
+		actual := config.CollectionName(name)
+		if other, taken := seen[actual]; taken {
+			problems = append(problems, fmt.Sprintf("collections %s and %s both map to %q", other, name, actual))
+		}
+		seen[actual] = name
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// CheckKeys reports whether all token signing and verification keys can be loaded.
+func (config Config) CheckKeys() error {
+	if problems := config.keyProblems(); len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (config Config) keyProblems() []string {
+	var problems []string
+
+	keys := []struct {
+		name    string
+		value   string
+		private bool
+	}{
+		{"ACCESS_TOKEN_PRIVATE_KEY", config.AccessTokenPrivateKey, true},
+		{"ACCESS_TOKEN_PUBLIC_KEY", config.AccessTokenPublicKey, false},
+		{"REFRESH_TOKEN_PRIVATE_KEY", config.RefreshTokenPrivateKey, true},
+		{"REFRESH_TOKEN_PUBLIC_KEY", config.RefreshTokenPublicKey, false},
+	}
+	for _, key := range keys {
+		var err error
+		if key.private {
+			_, err = ParsePrivateKey(key.value)
+		} else {
+			_, err = ParsePublicKey(key.value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key.name, err))
+		}
+	}
+	return problems
+}
+
+// ListenAddr is the address the HTTP server binds to. An empty HOST listens on all interfaces.
+func (config Config) ListenAddr() string {
+	return net.JoinHostPort(config.Host, config.Port)
+}
+
+func (config Config) TLSEnabled() bool {
+	return config.TLSCertFile != "" && config.TLSKeyFile != ""
+}
+
+func (config Config) AllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(config.CorsAllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+func (config Config) SameSite() http.SameSite {
+	switch strings.ToLower(config.CookieSameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+// ParseLogLevel reads LOG_LEVEL (debug, info, warn or error), which defaults to info.
+func (config Config) ParseLogLevel() (slog.Level, error) {
+	var level slog.Level
+	if config.LogLevel == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("LOG_LEVEL %q must be debug, info, warn or error", config.LogLevel)
+	}
+	return level, nil
+}
+
+// DefaultTenantId is the organization used when a request names none. Data created before
+// multi-tenancy was introduced is migrated into it.
+func (config Config) DefaultTenantId() string {
+	if config.DefaultTenant == "" {
+		return "default"
+	}
+	return config.DefaultTenant
+}
+
+// LDAPTenantId is the only organization whose users the directory authenticates and provisions,
+// since LDAP_GROUP_LEVELS names levels of that organization.
+func (config Config) LDAPTenantId() string {
+	if config.LDAPTenant == "" {
+		return config.DefaultTenantId()
+	}
+	return config.LDAPTenant
+}
+
+// OAuthTenantId is the only organization social login provisions new users into, with
+// OAUTH_DEFAULT_LEVEL and OAUTH_DEFAULT_GROUP.
+func (config Config) OAuthTenantId() string {
+	if config.OAuthTenant == "" {
+		return config.DefaultTenantId()
+	}
+	return config.OAuthTenant
+}
+
+// DefaultAdminLevel is the admin level for organizations that do not set their own.
+func (config Config) DefaultAdminLevel() string {
+	if config.AdminLevel == "" {
+		return "63dc85d1-cfed-45cd-a404-51778f377f63"
+	}
+	return config.AdminLevel
+}
+
+// ScimTenantTokens maps each organization, by id or slug, to the token its identity provider
+// uses for SCIM: SCIM_TOKEN for the default organization and SCIM_TOKENS for the others.
+func (config Config) ScimTenantTokens() map[string]string {
+	tokens := map[string]string{}
+	if config.ScimToken != "" {
+		tokens[config.DefaultTenantId()] = config.ScimToken
+	}
+	for _, entry := range strings.Split(config.ScimTokens, ",") {
+		tenant, token, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if found && tenant != "" && token != "" {
+			tokens[tenant] = token
+		}
+	}
+	return tokens
+}
+
+// IsSuperAdmin reports whether userId is listed in SUPER_ADMIN_IDS.
+func (config Config) IsSuperAdmin(userId string) bool {
+	for _, id := range strings.Split(config.SuperAdminIds, ",") {
+		if id = strings.TrimSpace(id); id != "" && id == userId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidatePasswordLists(t *testing.T) {
+	dir := t.TempDir()
+	words := filepath.Join(dir, "banned.txt")
+	if err := os.WriteFile(words, []byte("acme\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		problem string
+	}{
+		{"missing banned words file", Config{PasswordBannedWordsFile: filepath.Join(dir, "none")}, "PASSWORD_BANNED_WORDS_FILE"},
+		{"missing breached file", Config{PasswordBreachedFile: filepath.Join(dir, "none")}, "PASSWORD_BREACHED_FILE"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.config.Validate(); err == nil || !strings.Contains(err.Error(), test.problem) {
+				t.Errorf("Validate() = %v, want %s reported", err, test.problem)
+			}
+		})
+	}
+
+	// a readable file and a range directory are accepted
+	err := Config{PasswordBannedWordsFile: words, PasswordBreachedFile: dir}.Validate()
+	if err != nil && strings.Contains(err.Error(), "PASSWORD_") {
+		t.Errorf("Validate() = %v, want the password lists accepted", err)
+	}
+}
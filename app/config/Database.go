@@ -1,46 +1,147 @@
-package config
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"time"
-
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-func ConnectDB() *mongo.Client {
-	config, err := LoadConfig(".")
-	if err != nil {
-		log.Fatal("Could not load environment variables", err)
-	}
-
-	client, err := mongo.NewClient(options.Client().ApplyURI(config.DBUri))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	//ping the database
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println("Connected to MongoDB")
-	return client
-}
-
-// Client instance
-var DB *mongo.Client = ConnectDB()
-
-// getting database collections
-func GetCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	collection := client.Database("user_management").Collection(collectionName)
-	return collection
-}
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+// ConnectDB connects to MongoDB and pings it, retrying with exponential backoff until ctx is done.
+// Every command gets a span below the one of the request that issued it; monitor, when not nil,
+// observes the commands as well.
+func ConnectDB(ctx context.Context, uri string, monitor *event.CommandMonitor) (*mongo.Client, error) {
+	client, err := mongo.NewClient(options.Client().ApplyURI(uri).SetMonitor(combineMonitors(otelmongo.NewMonitor(), monitor)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		//ping the database
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = client.Ping(pingCtx, nil)
+		cancel()
+		if err == nil {
+			break
+		}
+
+		slog.Warn("MongoDB not reachable, retrying", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			client.Disconnect(context.Background())
+			return nil, fmt.Errorf("could not connect to MongoDB: %w", err)
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > 10*time.Second {
+			backoff = 10 * time.Second
+		}
+	}
+
+	slog.Info("connected to MongoDB")
+	return client, nil
+}
+
+// combineMonitors passes every command event to each of monitors.
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	var active []*event.CommandMonitor
+	for _, monitor := range monitors {
+		if monitor != nil {
+			active = append(active, monitor)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			for _, monitor := range active {
+				if monitor.Started != nil {
+					monitor.Started(ctx, evt)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			for _, monitor := range active {
+				if monitor.Succeeded != nil {
+					monitor.Succeeded(ctx, evt)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			for _, monitor := range active {
+				if monitor.Failed != nil {
+					monitor.Failed(ctx, evt)
+				}
+			}
+		},
+	}
+}
+
+// Client instance, set by the application bootstrap once the connection is up
+var DB *mongo.Client
+
+// Logical collection names. The actual names can be changed with COLLECTION_PREFIX and
+// COLLECTION_<NAME>, e.g. COLLECTION_USER_LEVELS=levels.
+const (
+	OrganizationsCollection       = "organizations"
+	UsersCollection               = "users"
+	LevelsCollection              = "user_levels"
+	GroupsCollection              = "user_groups"
+	GroupMembershipsCollection    = "group_memberships"
+	RevokedTokensCollection       = "revoked_tokens"
+	ApiKeysCollection             = "api_keys"
+	WebAuthnCredentialsCollection = "webauthn_credentials"
+	WebAuthnSessionsCollection    = "webauthn_sessions"
+	ImpersonationLogsCollection   = "impersonation_logs"
+	SchemaMigrationsCollection    = "schema_migrations"
+)
+
+var collectionNames = []string{
+	OrganizationsCollection,
+	UsersCollection,
+	LevelsCollection,
+	GroupsCollection,
+	GroupMembershipsCollection,
+	RevokedTokensCollection,
+	ApiKeysCollection,
+	WebAuthnCredentialsCollection,
+	WebAuthnSessionsCollection,
+	ImpersonationLogsCollection,
+	SchemaMigrationsCollection,
+}
+
+// DatabaseName is MONGO_DATABASE, or user_management when it is not set.
+func (config Config) DatabaseName() string {
+	if config.DBName == "" {
+		return "user_management"
+	}
+	return config.DBName
+}
+
+// CollectionName maps a logical collection name to the one used in the database.
+func (config Config) CollectionName(name string) string {
+	if override, ok := config.collections[name]; ok {
+		name = override
+	}
+	return config.CollectionPrefix + name
+}
+
+// getting the application database
+func GetDatabase(client *mongo.Client) *mongo.Database {
+	return client.Database(Get().DatabaseName())
+}
+
+// getting database collections by their logical name
+func GetCollection(client *mongo.Client, collectionName string) *mongo.Collection {
+	collection := GetDatabase(client).Collection(Get().CollectionName(collectionName))
+	return collection
+}
@@ -1,20 +1,308 @@
-package config
-
-import (
-	"fmt"
-
-	"golang.org/x/crypto/bcrypt"
-)
-
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-
-	if err != nil {
-		return "", fmt.Errorf("could not hash password %w", err)
-	}
-	return string(hashedPassword), nil
-}
-
-func VerifyPassword(hashedPassword string, candidatePassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(candidatePassword))
-}
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var ErrPasswordMismatch = errors.New("password does not match")
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+	HashPBKDF2   = "pbkdf2"
+)
+
+// Limits on the cost parameters read from stored hashes. A hash outside them is treated as
+// unknown instead of letting a single login panic or allocate without bound.
+const (
+	maxArgon2Memory     = 1024 * 1024 // KiB, 1 GiB
+	maxArgon2Time       = 100
+	maxPBKDF2Iterations = 10_000_000
+	minHashKeyLen       = 16
+	maxHashKeyLen       = 128
+)
+
+// PasswordHashing holds the algorithm and cost parameters used for new password hashes.
+type PasswordHashing struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen int
+}
+
+func DefaultPasswordHashing() PasswordHashing {
+	return PasswordHashing{
+		Algorithm:     HashArgon2id,
+		BcryptCost:    bcrypt.DefaultCost,
+		Argon2Memory:  64 * 1024,
+		Argon2Time:    3,
+		Argon2Threads: 2,
+		Argon2KeyLen:  32,
+		Argon2SaltLen: 16,
+	}
+}
+
+var passwordHashing = struct {
+	sync.RWMutex
+	params PasswordHashing
+}{params: DefaultPasswordHashing()}
+
+// ConfigurePasswordHashing applies the PASSWORD_HASH_* settings, keeping the defaults for unset ones.
+func ConfigurePasswordHashing(config Config) error {
+	params := DefaultPasswordHashing()
+
+	if config.PasswordHashAlgorithm != "" {
+		params.Algorithm = strings.ToLower(config.PasswordHashAlgorithm)
+	}
+	if config.PasswordBcryptCost != 0 {
+		params.BcryptCost = config.PasswordBcryptCost
+	}
+	if config.PasswordArgon2Memory != 0 {
+		params.Argon2Memory = config.PasswordArgon2Memory
+	}
+	if config.PasswordArgon2Time != 0 {
+		params.Argon2Time = config.PasswordArgon2Time
+	}
+	if config.PasswordArgon2Threads != 0 {
+		params.Argon2Threads = config.PasswordArgon2Threads
+	}
+
+	switch params.Algorithm {
+	case HashArgon2id:
+		if !argon2ParamsValid(params.Argon2Memory, params.Argon2Time, params.Argon2Threads) {
+			return fmt.Errorf("PASSWORD_ARGON2_MEMORY must be between 8 per thread and %d, PASSWORD_ARGON2_TIME at most %d", maxArgon2Memory, maxArgon2Time)
+		}
+	case HashBcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("PASSWORD_BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("unsupported PASSWORD_HASH_ALGORITHM %q", params.Algorithm)
+	}
+
+	passwordHashing.Lock()
+	passwordHashing.params = params
+	passwordHashing.Unlock()
+	return nil
+}
+
+func currentPasswordHashing() PasswordHashing {
+	passwordHashing.RLock()
+	defer passwordHashing.RUnlock()
+	return passwordHashing.params
+}
+
+func HashPassword(password string) (string, error) {
+	params := currentPasswordHashing()
+
+	if params.Algorithm == HashBcrypt {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), params.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("could not hash password %w", err)
+		}
+		return string(hashedPassword), nil
+	}
+
+	salt := make([]byte, params.Argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("could not hash password %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, params.Argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Argon2Memory, params.Argon2Time, params.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword checks a password against an argon2id, bcrypt or imported PBKDF2 hash.
+func VerifyPassword(ctx context.Context, hashedPassword string, candidatePassword string) error {
+	_, span := Tracer().Start(ctx, "VerifyPassword", trace.WithAttributes(attribute.String("password.algorithm", hashAlgorithm(hashedPassword))))
+	defer span.End()
+
+	err := verifyPassword(hashedPassword, candidatePassword)
+	span.SetAttributes(attribute.Bool("password.match", err == nil))
+	return err
+}
+
+func verifyPassword(hashedPassword string, candidatePassword string) error {
+	switch hashAlgorithm(hashedPassword) {
+	case HashArgon2id:
+		return verifyArgon2id(hashedPassword, candidatePassword)
+	case HashBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(candidatePassword))
+	case HashPBKDF2:
+		return verifyPBKDF2(hashedPassword, candidatePassword)
+	}
+	return ErrUnknownHashFormat
+}
+
+// NeedsRehash reports whether a stored hash was made with another algorithm or weaker parameters
+// than the current configuration, so it should be replaced after the next successful login.
+func NeedsRehash(hashedPassword string) bool {
+	params := currentPasswordHashing()
+
+	algorithm := hashAlgorithm(hashedPassword)
+	if algorithm != params.Algorithm {
+		return true
+	}
+
+	switch algorithm {
+	case HashBcrypt:
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return err != nil || cost < params.BcryptCost
+	case HashArgon2id:
+		version, memory, time, threads, _, key, err := parseArgon2id(hashedPassword)
+		return err != nil || version != argon2.Version || memory < params.Argon2Memory ||
+			time < params.Argon2Time || threads != params.Argon2Threads || uint32(len(key)) < params.Argon2KeyLen
+	}
+	return true
+}
+
+func hashAlgorithm(hashedPassword string) string {
+	switch {
+	case strings.HasPrefix(hashedPassword, "$argon2id$"):
+		return HashArgon2id
+	case strings.HasPrefix(hashedPassword, "$2a$"), strings.HasPrefix(hashedPassword, "$2b$"), strings.HasPrefix(hashedPassword, "$2y$"):
+		return HashBcrypt
+	case strings.HasPrefix(hashedPassword, "$pbkdf2-"), strings.HasPrefix(hashedPassword, "pbkdf2_"):
+		return HashPBKDF2
+	}
+	return ""
+}
+
+// parseArgon2id splits a PHC string of the form $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func parseArgon2id(hashedPassword string) (version int, memory uint32, time uint32, threads uint8, salt []byte, key []byte, err error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		err = ErrUnknownHashFormat
+		return
+	}
+
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return
+	}
+	if !argon2ParamsValid(memory, time, threads) {
+		err = ErrUnknownHashFormat
+		return
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return
+	}
+	if len(key) < minHashKeyLen || len(key) > maxHashKeyLen {
+		err = ErrUnknownHashFormat
+	}
+	return
+}
+
+// argon2ParamsValid reports whether argon2.IDKey can run with the parameters without panicking,
+// t=0 or p=0, or exhausting memory. Argon2 needs at least 8 KiB of memory per thread.
+func argon2ParamsValid(memory uint32, time uint32, threads uint8) bool {
+	return time >= 1 && time <= maxArgon2Time && threads >= 1 &&
+		memory >= 8*uint32(threads) && memory <= maxArgon2Memory
+}
+
+func verifyArgon2id(hashedPassword string, candidatePassword string) error {
+	_, memory, time, threads, salt, key, err := parseArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey([]byte(candidatePassword), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// verifyPBKDF2 accepts the two formats legacy systems usually export: passlib's
+// $pbkdf2-sha256$<rounds>$<salt>$<key> (adapted base64) and Django's pbkdf2_sha256$<rounds>$<salt>$<key>.
+func verifyPBKDF2(hashedPassword string, candidatePassword string) error {
+	var digest, rounds, salt, encodedKey string
+	var decode func(string) ([]byte, error)
+
+	if strings.HasPrefix(hashedPassword, "$") {
+		parts := strings.Split(hashedPassword, "$")
+		if len(parts) != 5 {
+			return ErrUnknownHashFormat
+		}
+		digest, rounds, salt, encodedKey = strings.TrimPrefix(parts[1], "pbkdf2-"), parts[2], parts[3], parts[4]
+		decode = decodePasslib
+		saltBytes, err := decodePasslib(salt)
+		if err != nil {
+			return err
+		}
+		salt = string(saltBytes)
+	} else {
+		parts := strings.Split(hashedPassword, "$")
+		if len(parts) != 4 {
+			return ErrUnknownHashFormat
+		}
+		digest, rounds, salt, encodedKey = strings.TrimPrefix(parts[0], "pbkdf2_"), parts[1], parts[2], parts[3]
+		decode = base64.StdEncoding.DecodeString
+	}
+
+	var newHash func() hash.Hash
+	switch digest {
+	case "sha1", "":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return ErrUnknownHashFormat
+	}
+
+	iterations, err := strconv.Atoi(rounds)
+	if err != nil || iterations <= 0 || iterations > maxPBKDF2Iterations {
+		return ErrUnknownHashFormat
+	}
+
+	key, err := decode(encodedKey)
+	if err != nil {
+		return err
+	}
+	// an empty key would match every password
+	if len(key) < minHashKeyLen || len(key) > maxHashKeyLen {
+		return ErrUnknownHashFormat
+	}
+
+	candidate := pbkdf2.Key([]byte(candidatePassword), []byte(salt), iterations, len(key), newHash)
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// decodePasslib reads passlib's base64 variant, which uses "." instead of "+" and no padding.
+func decodePasslib(value string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(value, ".", "+"))
+}
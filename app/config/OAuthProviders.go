@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// OAuthProvider describes an upstream OAuth2/OIDC identity provider used for social login.
+// Each provider listed in OAUTH_PROVIDERS is configured with OAUTH_<NAME>_* keys.
+type OAuthProvider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	RedirectURL  string
+	Scopes       []string
+
+	// claim names in the userinfo response
+	SubjectClaim       string
+	EmailClaim         string
+	EmailVerifiedClaim string
+	NameClaim          string
+
+	// TrustEmail treats the email as verified for providers that do not report it. The email then
+	// links the identity to the account with that address, so whoever controls an account at the
+	// provider can log in as any user whose email they are able to enter there. Only enable it for
+	// providers that verify addresses themselves.
+	TrustEmail bool
+	// AutoProvision creates a user on first login when no account matches the email.
+	AutoProvision bool
+}
+
+func providerString(name string, key string, fallback string) string {
+	value := viper.GetString(fmt.Sprintf("OAUTH_%s_%s", strings.ToUpper(name), key))
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// SocialProviders returns the providers listed in OAUTH_PROVIDERS, as read by LoadConfig.
+func (config Config) SocialProviders() map[string]OAuthProvider {
+	return config.socialProviders
+}
+
+func (config Config) readSocialProviders() map[string]OAuthProvider {
+	providers := map[string]OAuthProvider{}
+
+	for _, name := range strings.Split(config.OAuthProviders, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		providers[name] = OAuthProvider{
+			Name:               name,
+			ClientID:           providerString(name, "CLIENT_ID", ""),
+			ClientSecret:       providerString(name, "CLIENT_SECRET", ""),
+			AuthURL:            providerString(name, "AUTH_URL", ""),
+			TokenURL:           providerString(name, "TOKEN_URL", ""),
+			UserInfoURL:        providerString(name, "USERINFO_URL", ""),
+			RedirectURL:        providerString(name, "REDIRECT_URL", ""),
+			Scopes:             strings.Fields(providerString(name, "SCOPES", "openid email profile")),
+			SubjectClaim:       providerString(name, "SUBJECT_CLAIM", "sub"),
+			EmailClaim:         providerString(name, "EMAIL_CLAIM", "email"),
+			EmailVerifiedClaim: providerString(name, "EMAIL_VERIFIED_CLAIM", "email_verified"),
+			NameClaim:          providerString(name, "NAME_CLAIM", "name"),
+			TrustEmail:         viper.GetBool(fmt.Sprintf("OAUTH_%s_TRUST_EMAIL", strings.ToUpper(name))),
+			AutoProvision:      viper.GetBool(fmt.Sprintf("OAUTH_%s_AUTO_PROVISION", strings.ToUpper(name))),
+		}
+	}
+
+	return providers
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang-jwt/jwt"
+	"github.com/spf13/viper"
+)
+
+var current atomic.Pointer[Config]
+
+// Set makes config the configuration returned by Get. The bootstrap calls it once the loaded
+// configuration has passed Validate.
+func Set(config Config) {
+	current.Store(&config)
+}
+
+// Get returns the active configuration, for the reload path and the collection names. Routes and
+// middleware receive theirs when the router is built instead, so a reload never changes the
+// settings in the middle of a request.
+func Get() Config {
+	config := current.Load()
+	if config == nil {
+		panic("config: Get called before the configuration was loaded")
+	}
+	return *config
+}
+
+// Watch reloads app.env whenever it changes. A new configuration only replaces the active one
+// when it passes Validate; onChange is then called with it. Settings read once at startup, such
+// as the listen address or the database connection, keep their running values until a restart.
+func Watch(onChange func(Config)) {
+	viper.OnConfigChange(func(event fsnotify.Event) {
+		var config Config
+		if err := viper.Unmarshal(&config); err != nil {
+			slog.Warn("ignoring configuration change", "file", event.Name, "error", err)
+			return
+		}
+		config.socialProviders = config.readSocialProviders()
+		config.collections = readCollectionNames()
+
+		if changed := config.keepStartupSettings(Get()); len(changed) > 0 {
+			slog.Warn("configuration change needs a restart, keeping the running values", "file", event.Name, "settings", changed)
+		}
+
+		if err := config.Validate(); err != nil {
+			slog.Warn("ignoring configuration change", "file", event.Name, "error", err)
+			return
+		}
+
+		Set(config)
+		slog.Info("configuration reloaded", "file", event.Name)
+		if onChange != nil {
+			onChange(config)
+		}
+	})
+	viper.WatchConfig()
+}
+
+// keepStartupSettings replaces the settings only read at startup with those of the running
+// configuration and returns the names of the ones that differed. GetDatabase and GetCollection
+// read the configuration on every call, so taking a new database or collection name would send
+// queries elsewhere while the connection, listener and tracing stay as they were.
+func (config *Config) keepStartupSettings(running Config) []string {
+	var changed []string
+
+	keep(&changed, "HOST", &config.Host, running.Host)
+	keep(&changed, "PORT", &config.Port, running.Port)
+	keep(&changed, "TLS_CERT_FILE", &config.TLSCertFile, running.TLSCertFile)
+	keep(&changed, "TLS_KEY_FILE", &config.TLSKeyFile, running.TLSKeyFile)
+	keep(&changed, "MONGOURI", &config.DBUri, running.DBUri)
+	keep(&changed, "MONGO_DATABASE", &config.DBName, running.DBName)
+	keep(&changed, "MONGO_CONNECT_TIMEOUT", &config.DBConnectTimeout, running.DBConnectTimeout)
+	keep(&changed, "COLLECTION_PREFIX", &config.CollectionPrefix, running.CollectionPrefix)
+	keep(&changed, "LOG_FORMAT", &config.LogFormat, running.LogFormat)
+	keep(&changed, "TRACING_EXPORTER", &config.TracingExporter, running.TracingExporter)
+	keep(&changed, "TRACING_ENDPOINT", &config.TracingEndpoint, running.TracingEndpoint)
+	keep(&changed, "TRACING_INSECURE", &config.TracingInsecure, running.TracingInsecure)
+	keep(&changed, "TRACING_SAMPLE_RATIO", &config.TracingSampleRatio, running.TracingSampleRatio)
+	keep(&changed, "TRACING_SERVICE_NAME", &config.TracingServiceName, running.TracingServiceName)
+
+	if !maps.Equal(config.collections, running.collections) {
+		changed = append(changed, "COLLECTION_*")
+		config.collections = running.collections
+	}
+
+	return changed
+}
+
+func keep[T comparable](changed *[]string, name string, value *T, running T) {
+	if *value != running {
+		*changed = append(*changed, name)
+		*value = running
+	}
+}
+
+// parsed RSA keys by their base64-encoded PEM, so each key is only decoded once
+var parsedKeys sync.Map
+
+// ParsePrivateKey decodes a base64-encoded PEM private key such as ACCESS_TOKEN_PRIVATE_KEY.
+func ParsePrivateKey(encoded string) (*rsa.PrivateKey, error) {
+	if key, ok := parsedKeys.Load("private:" + encoded); ok {
+		return key.(*rsa.PrivateKey), nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %w", err)
+	}
+
+	parsedKeys.Store("private:"+encoded, key)
+	return key, nil
+}
+
+// ParsePublicKey decodes a base64-encoded PEM public key such as ACCESS_TOKEN_PUBLIC_KEY.
+func ParsePublicKey(encoded string) (*rsa.PublicKey, error) {
+	if key, ok := parsedKeys.Load("public:" + encoded); ok {
+		return key.(*rsa.PublicKey), nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPublicKeyFromPEM(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %w", err)
+	}
+
+	parsedKeys.Store("public:"+encoded, key)
+	return key, nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/RadenAbror/UserManagement"
+
+// Tracer creates the spans of the service itself. It produces nothing until SetupTracing has
+// installed an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// TracingEnabled reports whether TRACING_EXPORTER selects an exporter.
+func (config Config) TracingEnabled() bool {
+	exporter := strings.ToLower(config.TracingExporter)
+	return exporter != "" && exporter != "none"
+}
+
+// ServiceName is TRACING_SERVICE_NAME, or user-management when it is not set.
+func (config Config) ServiceName() string {
+	if config.TracingServiceName == "" {
+		return "user-management"
+	}
+	return config.TracingServiceName
+}
+
+// SetupTracing installs the exporter chosen by TRACING_EXPORTER and the W3C trace context
+// propagator. otlp sends spans over HTTP to TRACING_ENDPOINT, or to the standard
+// OTEL_EXPORTER_OTLP_* variables when it is empty; stdout prints them. The returned function
+// flushes pending spans and must be called before exiting.
+func SetupTracing(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !config.TracingEnabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(config.TracingExporter) {
+	case "otlp":
+		var options []otlptracehttp.Option
+		if config.TracingEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.TracingEndpoint))
+		}
+		if config.TracingInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		err = fmt.Errorf("unknown exporter %q", config.TracingExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingSampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.ServiceName()))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+)
+
+func CreateApiKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+		var request models.ApiKeyCreate
+
+		if !bindJSON(c, &request) {
+			return
+		}
+
+		request.Name = helpers.SanitizeText(request.Name)
+		apiKey, err := helpers.CreateApiKey(c.Request.Context(), currentUser.TenantId, currentUser.Id, request)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.RequestResponse{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": apiKey}})
+	}
+}
+
+func DataApiKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		apiKeys, err := helpers.FindApiKeys(c.Request.Context(), currentUser.Id)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": apiKeys}})
+	}
+}
+
+func DeleteApiKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		deleted, err := helpers.DeleteApiKey(c.Request.Context(), currentUser.Id, c.Param("keyId"))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if !deleted {
+			helpers.Fail(c, apperrors.New(apperrors.ApiKeyNotFound))
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "API key successfully revoked!"}})
+	}
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenApiSpec serves the OpenAPI document. It describes the code, not the configuration, so it
+// is encoded once.
+func OpenApiSpec() gin.HandlerFunc {
+	spec, err := json.Marshal(openapi.Spec())
+
+	return func(c *gin.Context) {
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+	}
+}
+
+// ApiDocs serves the interactive documentation of the OpenAPI document.
+func ApiDocs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", openapi.DocsPolicy)
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+	}
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestApiDocsPinsSwaggerUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/docs", ApiDocs())
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	page := response.Body.String()
+	if strings.Contains(page, "withCredentials") {
+		t.Error("the docs page sends credentials with Swagger UI's requests")
+	}
+
+	policy := response.Header().Get("Content-Security-Policy")
+	for _, asset := range regexp.MustCompile(`(?:src|href)="(https://[^"]+)"`).FindAllStringSubmatch(page, -1) {
+		if !strings.Contains(asset[1], "@5.17.14/") || !strings.Contains(policy, asset[1]) {
+			t.Errorf("%s is not pinned to a release the Content-Security-Policy allows: %q", asset[1], policy)
+		}
+	}
+	if !strings.Contains(policy, "'sha256-") || !strings.Contains(policy, "connect-src 'self'") {
+		t.Errorf("Content-Security-Policy = %q, want the inline script hash and requests kept on this origin", policy)
+	}
+}
@@ -5,94 +5,116 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/RadenAbror/UserManagement/app/apperrors"
 	"github.com/RadenAbror/UserManagement/app/config"
 	"github.com/RadenAbror/UserManagement/app/helpers"
 	"github.com/RadenAbror/UserManagement/app/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/microcosm-cc/bluemonday"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var groupCollection *mongo.Collection = config.GetCollection(config.DB, "user_groups")
+func groupCollection() *mongo.Collection {
+	return config.GetCollection(config.DB, config.GroupsCollection)
+}
 
-func CreateGroup() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+// groupPathFailed answers a parent_id that GroupPath rejected. It reports false when err is nil.
+func groupPathFailed(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case helpers.ErrGroupCycle:
+		helpers.Fail(c, apperrors.New(apperrors.GroupCycle))
+	case helpers.ErrUnknownGroup:
+		helpers.Fail(c, apperrors.New(apperrors.ParentGroupNotFound))
+	default:
+		helpers.Fail(c, err)
+	}
+	return true
+}
 
-		user, err := helpers.FindUserById(currentUser.Id)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(
-					http.StatusInternalServerError,
-					models.RequestResponse{
-						Status:  http.StatusInternalServerError,
-						Message: "error",
-						Data: map[string]interface{}{
-							"data": "Identitas Anda tidak valid!",
-						},
-					},
-				)
-				return
-			}
-		}
+// groupManagersExist reports whether every manager is a user of the organization.
+func groupManagersExist(ctx context.Context, tenantId string, managers []string) (bool, error) {
+	if len(managers) == 0 {
+		return true, nil
+	}
 
-		if user.Level != "63dc85d1-cfed-45cd-a404-51778f377f63" {
-			c.JSON(
-				http.StatusInternalServerError,
-				models.RequestResponse{
-					Status:  http.StatusInternalServerError,
-					Message: "error",
-					Data: map[string]interface{}{
-						"data": "Anda tidak diijinkan mengkases modul ini!",
-					},
-				},
-			)
-			return
-		}
+	unique := map[string]bool{}
+	for _, manager := range managers {
+		unique[manager] = true
+	}
+
+	count, err := userCollection().CountDocuments(ctx, helpers.Scoped(tenantId, bson.M{"id": bson.M{"$in": managers}}))
+	return count == int64(len(unique)), err
+}
+
+// bindGroup reads and validates a group from the request body, checking its managers. It
+// reports false after answering the request.
+func bindGroup(c *gin.Context, ctx context.Context, group *models.Group) bool {
+	if !bindJSON(c, group) {
+		return false
+	}
+
+	exist, err := groupManagersExist(ctx, helpers.TenantId(c), group.Managers)
+	if err != nil {
+		helpers.Fail(c, err)
+		return false
+	}
+	if !exist {
+		helpers.Fail(c, apperrors.New(apperrors.UnknownGroupManager))
+		return false
+	}
+
+	return true
+}
+
+// findGroupParam loads the group named by the groupId route parameter. It reports nil after
+// answering the request when there is no such group.
+func findGroupParam(c *gin.Context) *models.Group {
+	group, err := helpers.FindGroup(c.Request.Context(), helpers.TenantId(c), c.Param("groupId"))
+	if err == mongo.ErrNoDocuments {
+		helpers.Fail(c, apperrors.New(apperrors.GroupNotFound))
+		return nil
+	}
+	if err != nil {
+		helpers.Fail(c, err)
+		return nil
+	}
+	return group
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func CreateGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		var group models.Group
 		defer cancel()
-		p := bluemonday.UGCPolicy()
 
-		// Validate the request body
-		if err := c.BindJSON(&group); err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{
-				Status:  http.StatusBadRequest,
-				Message: "error",
-				Data: map[string]interface{}{
-					"data": err.Error(),
-				},
-			})
+		if !bindGroup(c, ctx, &group) {
 			return
 		}
 
-		if validationErr := validate.Struct(&group); validationErr != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{
-				Status:  http.StatusBadRequest,
-				Message: "error",
-				Data: map[string]interface{}{
-					"data": validationErr.Error(),
-				},
-			})
+		tenantId := helpers.TenantId(c)
+		path, err := helpers.GroupPath(ctx, tenantId, "", group.ParentId)
+		if groupPathFailed(c, err) {
+			return
 		}
 
 		newGroup := models.Group{
-			ID:      uuid.Must(uuid.NewRandom()).String(),
-			Name:    p.Sanitize(group.Name),
-			Acronym: p.Sanitize(group.Acronym),
+			ID:          uuid.Must(uuid.NewRandom()).String(),
+			TenantId:    tenantId,
+			Name:        helpers.SanitizeText(group.Name),
+			Acronym:     helpers.SanitizeText(group.Acronym),
+			ParentId:    group.ParentId,
+			Ancestors:   path,
+			Managers:    group.Managers,
+			Permissions: group.Permissions,
 		}
-		result, err := groupCollection.InsertOne(ctx, newGroup)
+		result, err := groupCollection().InsertOne(ctx, newGroup)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{
-				Status:  http.StatusInternalServerError,
-				Message: "error",
-				Data: map[string]interface{}{
-					"data": err.Error(),
-				},
-			})
+			helpers.Fail(c, err)
+			return
 		}
 
 		c.JSON(http.StatusCreated, models.RequestResponse{
@@ -107,138 +129,343 @@ func CreateGroup() gin.HandlerFunc {
 
 func DataGroup() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		groups := []models.Group{}
+		defer cancel()
+
+		// ?parent_id= lists the root groups, ?parent_id=<id> the direct children of a group
+		filter := bson.M{}
+		if parentId, found := c.GetQuery("parent_id"); found {
+			filter["parent_id"] = bson.M{"$in": bson.A{parentId, nil}}
+			if parentId != "" {
+				filter["parent_id"] = parentId
+			}
+		}
 
+		results, err := groupCollection().Find(ctx, helpers.Scoped(helpers.TenantId(c), filter))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		defer results.Close(ctx)
+		if err := results.All(ctx, &groups); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data": groups,
+			},
+		})
 	}
 }
 
+// ReadGroup returns the group together with the managers and permissions it inherits.
 func ReadGroup() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		group := findGroupParam(c)
+		if group == nil {
+			return
+		}
+
+		access, err := helpers.EffectiveGroupAccess(c.Request.Context(), group)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
 
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data":   group,
+				"access": access,
+			},
+		})
 	}
 }
 
 func UpdateGroup() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		var group models.Group
+		defer cancel()
 
-		user, err := helpers.FindUserById(currentUser.Id)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(
-					http.StatusInternalServerError,
-					models.RequestResponse{
-						Status:  http.StatusInternalServerError,
-						Message: "error",
-						Data: map[string]interface{}{
-							"data": "Identitas Anda tidak valid!",
-						},
-					},
-				)
+		existing := findGroupParam(c)
+		if existing == nil {
+			return
+		}
+
+		if !bindGroup(c, ctx, &group) {
+			return
+		}
+
+		tenantId := helpers.TenantId(c)
+		path, err := helpers.GroupPath(ctx, tenantId, existing.ID, group.ParentId)
+		if groupPathFailed(c, err) {
+			return
+		}
+
+		update := bson.M{
+			"name":        helpers.SanitizeText(group.Name),
+			"acronym":     helpers.SanitizeText(group.Acronym),
+			"parent_id":   group.ParentId,
+			"ancestors":   path,
+			"managers":    group.Managers,
+			"permissions": group.Permissions,
+		}
+		if _, err := groupCollection().UpdateOne(ctx, helpers.Scoped(tenantId, bson.M{"id": existing.ID}), bson.M{"$set": update}); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if group.ParentId != existing.ParentId {
+			if err := helpers.MoveGroupDescendants(ctx, tenantId, existing.ID, path); err != nil {
+				helpers.Fail(c, err)
 				return
 			}
 		}
 
-		if user.Level != "63dc85d1-cfed-45cd-a404-51778f377f63" {
-			c.JSON(
-				http.StatusInternalServerError,
-				models.RequestResponse{
-					Status:  http.StatusInternalServerError,
-					Message: "error",
-					Data: map[string]interface{}{
-						"data": "Anda tidak diijinkan mengkases modul ini!",
-					},
-				},
-			)
+		updatedGroup, err := helpers.FindGroup(ctx, tenantId, existing.ID)
+		if err != nil {
+			helpers.Fail(c, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		var group models.Group
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data": updatedGroup,
+			},
+		})
+	}
+}
+
+// DeleteGroup removes a group without subgroups and takes its users out of it.
+func DeleteGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
-		p := bluemonday.UGCPolicy()
 
-		// Validate the request body
-		if err := c.BindJSON(&group); err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{
-				Status:  http.StatusBadRequest,
-				Message: "error",
-				Data: map[string]interface{}{
-					"data": err.Error(),
-				},
-			})
+		group := findGroupParam(c)
+		if group == nil {
 			return
 		}
 
-		if validationErr := validate.Struct(&group); validationErr != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{
-				Status:  http.StatusBadRequest,
-				Message: "error",
-				Data: map[string]interface{}{
-					"data": validationErr.Error(),
-				},
-			})
+		tenantId := helpers.TenantId(c)
+		children, err := groupCollection().CountDocuments(ctx, helpers.Scoped(tenantId, bson.M{"parent_id": group.ID}))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		if children > 0 {
+			helpers.Fail(c, apperrors.New(apperrors.GroupHasChildren))
+			return
 		}
 
-		newGroup := models.Group{
-			ID:      uuid.Must(uuid.NewRandom()).String(),
-			Name:    p.Sanitize(group.Name),
-			Acronym: p.Sanitize(group.Acronym),
+		if _, err := groupCollection().DeleteOne(ctx, helpers.Scoped(tenantId, bson.M{"id": group.ID})); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if _, err := userCollection().UpdateMany(ctx, helpers.Scoped(tenantId, bson.M{"group": group.ID}), bson.M{"$set": bson.M{"group": "", "updated_at": time.Now()}}); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if err := helpers.DeleteMemberships(ctx, tenantId, bson.M{"group_id": group.ID}); err != nil {
+			helpers.Fail(c, err)
+			return
 		}
-		result, err := groupCollection.InsertOne(ctx, newGroup)
 
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data": "Group successfully deleted!",
+			},
+		})
+	}
+}
+
+// GroupAncestors lists the groups above a group, from the root down to its parent.
+func GroupAncestors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := findGroupParam(c)
+		if group == nil {
+			return
+		}
+
+		ancestors, err := helpers.GroupAncestors(c.Request.Context(), group)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{
-				Status:  http.StatusInternalServerError,
-				Message: "error",
-				Data: map[string]interface{}{
-					"data": err.Error(),
-				},
-			})
+			helpers.Fail(c, err)
+			return
 		}
 
-		c.JSON(http.StatusCreated, models.RequestResponse{
-			Status:  http.StatusCreated,
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
 			Message: "success",
 			Data: map[string]interface{}{
-				"data": result,
+				"data": ancestors,
 			},
 		})
 	}
 }
 
-func DeleteGroup() gin.HandlerFunc {
+// GroupDescendants lists every group below a group.
+func GroupDescendants() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := findGroupParam(c)
+		if group == nil {
+			return
+		}
+
+		descendants, err := helpers.GroupDescendants(c.Request.Context(), group)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data": descendants,
+			},
+		})
+	}
+}
+
+// GroupMembers lists the users of a group, or of its whole subtree with ?subtree=true.
+func GroupMembers() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		users := []models.User{}
+		defer cancel()
+
+		group := findGroupParam(c)
+		if group == nil {
+			return
+		}
+
+		groupIds := []string{group.ID}
+		if c.Query("subtree") == "true" {
+			ids, err := helpers.GroupSubtreeIds(ctx, group)
+			if err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+			groupIds = ids
+		}
+
+		memberships, err := helpers.ActiveGroupMemberships(ctx, helpers.TenantId(c), groupIds)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		memberIds := []string{}
+		for _, membership := range memberships {
+			memberIds = append(memberIds, membership.UserId)
+		}
 
-		user, err := helpers.FindUserById(currentUser.Id)
+		// users whose primary group is in the tree, plus those added through a membership
+		filter := bson.M{"$or": bson.A{bson.M{"group": bson.M{"$in": groupIds}}, bson.M{"id": bson.M{"$in": memberIds}}}}
+		results, err := userCollection().Find(ctx, helpers.Scoped(helpers.TenantId(c), filter))
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(
-					http.StatusInternalServerError,
-					models.RequestResponse{
-						Status:  http.StatusInternalServerError,
-						Message: "error",
-						Data: map[string]interface{}{
-							"data": "Identitas Anda tidak valid!",
-						},
-					},
-				)
+			helpers.Fail(c, err)
+			return
+		}
+
+		defer results.Close(ctx)
+		for results.Next(ctx) {
+			var singleUser models.User
+			if err := results.Decode(&singleUser); err != nil {
+				helpers.Fail(c, err)
 				return
 			}
+			// members are listed, never their password hashes
+			singleUser.Password, singleUser.PasswordConfirm = "", ""
+			users = append(users, singleUser)
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data":        users,
+				"memberships": memberships,
+			},
+		})
+	}
+}
+
+// AddGroupMember adds a user to a group with a role, or changes the role and validity dates of
+// an existing membership.
+func AddGroupMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.GroupMembershipSave
+
+		if !bindJSON(c, &request) {
+			return
+		}
+
+		if request.ValidFrom != nil && request.ValidUntil != nil && !request.ValidUntil.After(*request.ValidFrom) {
+			helpers.Fail(c, apperrors.New(apperrors.InvalidMembershipPeriod))
+			return
+		}
+
+		group := findGroupParam(c)
+		if group == nil {
+			return
+		}
+
+		tenantId := helpers.TenantId(c)
+		if _, err := helpers.FindUserById(c.Request.Context(), tenantId, request.UserId); err != nil {
+			helpers.Fail(c, apperrors.Wrap(apperrors.UserNotFound, err))
+			return
 		}
 
-		if user.Level != "63dc85d1-cfed-45cd-a404-51778f377f63" {
-			c.JSON(
-				http.StatusInternalServerError,
-				models.RequestResponse{
-					Status:  http.StatusInternalServerError,
-					Message: "error",
-					Data: map[string]interface{}{
-						"data": "Anda tidak diijinkan mengkases modul ini!",
-					},
-				},
-			)
+		membership, err := helpers.SaveMembership(c.Request.Context(), tenantId, group.ID, request)
+		if err != nil {
+			helpers.Fail(c, err)
 			return
 		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data": membership,
+			},
+		})
+	}
+}
+
+func RemoveGroupMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deleted, err := helpers.DeleteMembership(c.Request.Context(), helpers.TenantId(c), c.Param("groupId"), c.Param("userId"))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if !deleted {
+			helpers.Fail(c, apperrors.New(apperrors.MembershipNotFound))
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{
+			Status:  http.StatusOK,
+			Message: "success",
+			Data: map[string]interface{}{
+				"data": "Membership successfully deleted!",
+			},
+		})
 	}
 }
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+type healthCheck struct {
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// runHealthCheck times check. The probe is public, so the cause of a failure is only logged.
+func runHealthCheck(c *gin.Context, name string, check func() error) healthCheck {
+	start := time.Now()
+	err := check()
+	result := healthCheck{Status: "ok", DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = "fail"
+		helpers.Logger(c).Warn("readiness check failed", "check", name, "error", err)
+	}
+	return result
+}
+
+// Healthz is the liveness probe. It only shows the process is serving requests, a failing
+// dependency must not get it restarted.
+func Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz is the readiness probe. It fails while MongoDB cannot be pinged, while the token keys
+// cannot be loaded and once shutdown has begun, listing the state of every dependency.
+func Readyz(appConfig config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks := map[string]healthCheck{
+			"mongodb": runHealthCheck(c, "mongodb", func() error {
+				if config.DB == nil {
+					return errors.New("not connected")
+				}
+				ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+				defer cancel()
+				return config.DB.Ping(ctx, nil)
+			}),
+			"keys": runHealthCheck(c, "keys", func() error {
+				return appConfig.CheckKeys()
+			}),
+			"shutdown": runHealthCheck(c, "shutdown", func() error {
+				if helpers.ShuttingDown() {
+					return errors.New("shutting down")
+				}
+				return nil
+			}),
+		}
+
+		status, code := "ok", http.StatusOK
+		for _, check := range checks {
+			if check.Status != "ok" {
+				status, code = "fail", http.StatusServiceUnavailable
+			}
+		}
+
+		c.JSON(code, gin.H{"status": status, "checks": checks})
+	}
+}
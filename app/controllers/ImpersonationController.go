@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func impersonationCollection() *mongo.Collection {
+	return config.GetCollection(config.DB, config.ImpersonationLogsCollection)
+}
+
+// ImpersonateUser issues a short-lived access token for another user. The token's "act" claim
+// names the admin, and every issuance is recorded in impersonation_logs.
+func ImpersonateUser(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		var request models.ImpersonationRequest
+		defer cancel()
+
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		if !bindJSON(c, &request) {
+			return
+		}
+
+		subject, err := helpers.FindUserById(ctx, helpers.TenantId(c), c.Param("userId"))
+		if err != nil {
+			helpers.Fail(c, apperrors.Wrap(apperrors.UserNotFound, err))
+			return
+		}
+
+		if subject.Id == currentUser.Id {
+			helpers.Fail(c, apperrors.New(apperrors.SelfImpersonation))
+			return
+		}
+
+		// an admin must not gain the rights of an equal or a superior through their session
+		if helpers.AdminRank(c, subject) >= helpers.AdminRank(c, currentUser) {
+			helpers.Fail(c, apperrors.New(apperrors.PrivilegedImpersonation))
+			return
+		}
+
+		ttl := config.ImpersonationExpiresIn
+		if ttl <= 0 {
+			ttl = 15 * time.Minute
+		}
+
+		entry := models.ImpersonationLog{
+			ID:           uuid.Must(uuid.NewRandom()).String(),
+			TenantId:     subject.TenantId,
+			ActorId:      currentUser.Id,
+			ActorEmail:   currentUser.Email,
+			SubjectId:    subject.Id,
+			SubjectEmail: subject.Email,
+			Reason:       helpers.SanitizeText(request.Reason),
+			ClientIP:     c.ClientIP(),
+			UserAgent:    c.Request.UserAgent(),
+			CreatedAt:    time.Now(),
+			ExpiresAt:    time.Now().Add(ttl),
+		}
+
+		// record before issuing so no impersonation token exists without its log entry
+		if _, err := impersonationCollection().InsertOne(ctx, entry); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		act := map[string]interface{}{
+			"act":               map[string]interface{}{"sub": currentUser.Id, helpers.TenantClaim: currentUser.TenantId},
+			"imp":               entry.ID,
+			helpers.TenantClaim: subject.TenantId,
+		}
+		access_token, err := helpers.CreateTokenWithClaims(ctx, ttl, subject.Id, act, config.AccessTokenPrivateKey)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.RequestResponse{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": gin.H{
+			"access_token": access_token,
+			"expires_at":   entry.ExpiresAt,
+			"log":          entry,
+		}}})
+	}
+}
+
+func DataImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		filter := helpers.Scoped(helpers.TenantId(c), bson.M{})
+		if subjectId := c.Query("subject_id"); subjectId != "" {
+			filter["subject_id"] = subjectId
+		}
+		if actorId := c.Query("actor_id"); actorId != "" {
+			filter["actor_id"] = actorId
+		}
+
+		results, err := impersonationCollection().Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(200))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		defer results.Close(ctx)
+
+		logs := []models.ImpersonationLog{}
+		if err := results.All(ctx, &logs); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": logs}})
+	}
+}
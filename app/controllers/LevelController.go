@@ -5,79 +5,40 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/RadenAbror/UserManagement/app/apperrors"
 	"github.com/RadenAbror/UserManagement/app/config"
 	"github.com/RadenAbror/UserManagement/app/helpers"
 	"github.com/RadenAbror/UserManagement/app/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/microcosm-cc/bluemonday"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var levelCollection *mongo.Collection = config.GetCollection(config.DB, "user_levels")
+func levelCollection() *mongo.Collection {
+	return config.GetCollection(config.DB, config.LevelsCollection)
+}
 
 func CreateLevel() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		currentUser := c.MustGet("currentUser").(*models.DBResponse)
-
-		user, err := helpers.FindUserById(currentUser.Id)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(
-					http.StatusInternalServerError,
-					models.RequestResponse{
-						Status:  http.StatusInternalServerError,
-						Message: "error",
-						Data: map[string]interface{}{
-							"data": "Identitas Anda tidak valid!",
-						},
-					},
-				)
-				return
-			}
-		}
-
-		if user.Level != "63dc85d1-cfed-45cd-a404-51778f377f63" {
-			c.JSON(
-				http.StatusInternalServerError,
-				models.RequestResponse{
-					Status:  http.StatusInternalServerError,
-					Message: "error",
-					Data: map[string]interface{}{
-						"data": "Anda tidak diijinkan mengkases modul ini!",
-					},
-				},
-			)
-			return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		var level models.Level
 		defer cancel()
-		p := bluemonday.UGCPolicy()
 
-		// validate the request body
-		if err := c.BindJSON(&level); err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		// Use the validator library to validate required fields
-		if validationErr := validate.Struct(&level); validationErr != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}})
+		if !bindJSON(c, &level) {
 			return
 		}
 
 		newLevel := models.Level{
-			ID:      uuid.Must(uuid.NewRandom()).String(),
-			Name:    p.Sanitize(level.Name),
-			Acronym: p.Sanitize(level.Acronym),
+			ID:       uuid.Must(uuid.NewRandom()).String(),
+			TenantId: helpers.TenantId(c),
+			Name:     helpers.SanitizeText(level.Name),
+			Acronym:  helpers.SanitizeText(level.Acronym),
 		}
 
-		result, err := levelCollection.InsertOne(ctx, newLevel)
+		result, err := levelCollection().InsertOne(ctx, newLevel)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
+			helpers.Fail(c, err)
 			return
 		}
 
@@ -87,14 +48,14 @@ func CreateLevel() gin.HandlerFunc {
 
 func DataLevel() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		var levels []models.Level
 		defer cancel()
 
-		results, err := levelCollection.Find(ctx, bson.M{})
+		results, err := levelCollection().Find(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{}))
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
+			helpers.Fail(c, err)
 			return
 		}
 
@@ -103,7 +64,8 @@ func DataLevel() gin.HandlerFunc {
 		for results.Next(ctx) {
 			var singleLevel models.Level
 			if err = results.Decode(&singleLevel); err != nil {
-				c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
+				helpers.Fail(c, err)
+				return
 			}
 
 			levels = append(levels, singleLevel)
@@ -117,16 +79,20 @@ func DataLevel() gin.HandlerFunc {
 
 func ReadLevel() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		levelId := c.Param("levelId")
 		var level models.Level
 		defer cancel()
 
 		objId := levelId
 
-		err := levelCollection.FindOne(ctx, bson.M{"id": objId}).Decode(&level)
+		err := levelCollection().FindOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": objId})).Decode(&level)
+		if err == mongo.ErrNoDocuments {
+			helpers.Fail(c, apperrors.New(apperrors.LevelNotFound))
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
+			helpers.Fail(c, err)
 			return
 		}
 
@@ -136,54 +102,12 @@ func ReadLevel() gin.HandlerFunc {
 
 func UpdateLevel() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		levelId := c.Param("levelId")
 		var level models.Level
 		defer cancel()
-		p := bluemonday.UGCPolicy()
-
-		currentUser := c.MustGet("currentUser").(*models.DBResponse)
-
-		user, err := helpers.FindUserById(currentUser.Id)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(
-					http.StatusInternalServerError,
-					models.RequestResponse{
-						Status:  http.StatusInternalServerError,
-						Message: "error",
-						Data: map[string]interface{}{
-							"data": "Identitas Anda tidak valid!",
-						},
-					},
-				)
-				return
-			}
-		}
-
-		if user.Level != "63dc85d1-cfed-45cd-a404-51778f377f63" {
-			c.JSON(
-				http.StatusInternalServerError,
-				models.RequestResponse{
-					Status:  http.StatusInternalServerError,
-					Message: "error",
-					Data: map[string]interface{}{
-						"data": "Anda tidak diijinkan mengkases modul ini!",
-					},
-				},
-			)
-			return
-		}
-
-		//validate the request body
-		if err := c.BindJSON(&level); err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
 
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&level); validationErr != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}})
+		if !bindJSON(c, &level) {
 			return
 		}
 
@@ -191,19 +115,25 @@ func UpdateLevel() gin.HandlerFunc {
 		// err := userCollection.FindOne(ctx, bson.M{"id": objId, "email": bson.M{"$ne": p.Sanitize(userEdit.Email)}}).Decode(&userEdit)
 
 		update := bson.M{
-			"name":    p.Sanitize(level.Name),
-			"acronym": p.Sanitize(level.Acronym),
+			"name":    helpers.SanitizeText(level.Name),
+			"acronym": helpers.SanitizeText(level.Acronym),
+		}
+		result, err := levelCollection().UpdateOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": objId}), bson.M{"$set": update})
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if result.MatchedCount < 1 {
+			helpers.Fail(c, apperrors.New(apperrors.LevelNotFound))
+			return
 		}
-		result, _ := levelCollection.UpdateOne(ctx, bson.M{"id": objId}, bson.M{"$set": update})
 
-		//get updated user details
+		//get updated level details
 		var updatedLevel models.Level
-		if result.MatchedCount == 1 {
-			err := levelCollection.FindOne(ctx, bson.M{"id": objId}).Decode(&level)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-				return
-			}
+		if err := levelCollection().FindOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": objId})).Decode(&updatedLevel); err != nil {
+			helpers.Fail(c, err)
+			return
 		}
 
 		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": updatedLevel}})
@@ -212,55 +142,20 @@ func UpdateLevel() gin.HandlerFunc {
 
 func DeleteLevel() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		levelId := c.Param("levelId")
 		defer cancel()
 
-		currentUser := c.MustGet("currentUser").(*models.DBResponse)
-
-		user, err := helpers.FindUserById(currentUser.Id)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(
-					http.StatusInternalServerError,
-					models.RequestResponse{
-						Status:  http.StatusInternalServerError,
-						Message: "error",
-						Data: map[string]interface{}{
-							"data": "Identitas Anda tidak valid!",
-						},
-					},
-				)
-				return
-			}
-		}
-
-		if user.Level != "63dc85d1-cfed-45cd-a404-51778f377f63" {
-			c.JSON(
-				http.StatusInternalServerError,
-				models.RequestResponse{
-					Status:  http.StatusInternalServerError,
-					Message: "error",
-					Data: map[string]interface{}{
-						"data": "Anda tidak diijinkan mengkases modul ini!",
-					},
-				},
-			)
-			return
-		}
-
 		objId := levelId
 
-		result, err := levelCollection.DeleteOne(ctx, bson.M{"id": objId})
+		result, err := levelCollection().DeleteOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": objId}))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
+			helpers.Fail(c, err)
 			return
 		}
 
 		if result.DeletedCount < 1 {
-			c.JSON(http.StatusNotFound,
-				models.RequestResponse{Status: http.StatusNotFound, Message: "error", Data: map[string]interface{}{"data": "Level with specified ID not found!"}},
-			)
+			helpers.Fail(c, apperrors.New(apperrors.LevelNotFound))
 			return
 		}
 
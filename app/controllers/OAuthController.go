@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// tokenKeys returns the public keys to try for a token, ordered by the caller's token_type_hint.
+// Hints other than access_token and refresh_token are ignored (RFC 7009 section 2.1).
+func tokenKeys(config config.Config, hint string) [][2]string {
+	access := [2]string{"access_token", config.AccessTokenPublicKey}
+	refresh := [2]string{"refresh_token", config.RefreshTokenPublicKey}
+
+	if hint == "refresh_token" {
+		return [][2]string{refresh, access}
+	}
+	return [][2]string{access, refresh}
+}
+
+// validateAnyToken validates token against the access and refresh keys and reports which one
+// matched. Once a key verifies the signature its error is final, revoked tokens are not retried
+// with the other key.
+func validateAnyToken(ctx context.Context, config config.Config, token string, hint string) (jwt.MapClaims, string, error) {
+	var err error
+	for _, key := range tokenKeys(config, hint) {
+		var claims jwt.MapClaims
+		claims, err = helpers.ValidateTokenClaims(ctx, token, key[1])
+		if err == nil {
+			return claims, key[0], nil
+		}
+		if errors.Is(err, helpers.ErrTokenRevoked) || errors.Is(err, helpers.ErrRevocationCheck) {
+			return nil, key[0], err
+		}
+	}
+	return nil, "", err
+}
+
+func unauthorizedClient(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.OAuthError{Error: "invalid_client", ErrorDescription: "Client authentication failed"})
+}
+
+func IntrospectToken(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.TokenRequest
+
+		clientId, ok := helpers.AuthenticateClient(c)
+		if !ok {
+			unauthorizedClient(c)
+			return
+		}
+
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusBadRequest, models.OAuthError{Error: "invalid_request", ErrorDescription: err.Error()})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+
+		claims, _, err := validateAnyToken(c.Request.Context(), config, request.Token, request.TokenTypeHint)
+		if errors.Is(err, helpers.ErrRevocationCheck) {
+			c.JSON(http.StatusServiceUnavailable, models.OAuthError{Error: "temporarily_unavailable"})
+			return
+		}
+		// registration sessions are not accepted anywhere but by this service
+		if err != nil || helpers.TokenScope(claims) != "" {
+			c.JSON(http.StatusOK, models.IntrospectionResponse{Active: false})
+			return
+		}
+
+		sub := fmt.Sprint(claims["sub"])
+		user, err := helpers.FindUserById(c.Request.Context(), helpers.TokenTenant(config, claims), sub)
+		if err != nil {
+			c.JSON(http.StatusOK, models.IntrospectionResponse{Active: false})
+			return
+		}
+
+		response := models.IntrospectionResponse{
+			Active:    true,
+			ClientID:  clientId,
+			Username:  user.Email,
+			TokenType: "Bearer",
+			Sub:       sub,
+			Tenant:    user.TenantId,
+		}
+		if jti, ok := claims["jti"].(string); ok {
+			response.Jti = jti
+		}
+		if exp, ok := claims["exp"].(float64); ok {
+			response.Exp = int64(exp)
+		}
+		if iat, ok := claims["iat"].(float64); ok {
+			response.Iat = int64(iat)
+		}
+		if nbf, ok := claims["nbf"].(float64); ok {
+			response.Nbf = int64(nbf)
+		}
+		// impersonation tokens name the acting admin (RFC 8693 section 4.1)
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			response.Act = act
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func RevokeToken(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.TokenRequest
+
+		if _, ok := helpers.AuthenticateClient(c); !ok {
+			unauthorizedClient(c)
+			return
+		}
+
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusBadRequest, models.OAuthError{Error: "invalid_request", ErrorDescription: err.Error()})
+			return
+		}
+
+		claims, tokenType, err := validateAnyToken(c.Request.Context(), config, request.Token, request.TokenTypeHint)
+		if errors.Is(err, helpers.ErrRevocationCheck) {
+			c.JSON(http.StatusServiceUnavailable, models.OAuthError{Error: "temporarily_unavailable"})
+			return
+		}
+		// Invalid, unknown and already revoked tokens are not an error for the client (RFC 7009 section 2.2).
+		if err != nil {
+			c.Status(http.StatusOK)
+			return
+		}
+
+		if err := helpers.RevokeToken(c.Request.Context(), request.Token, claims, tokenType); err != nil {
+			c.JSON(http.StatusServiceUnavailable, models.OAuthError{Error: "temporarily_unavailable"})
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
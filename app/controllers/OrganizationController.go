@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func organizationCollection() *mongo.Collection {
+	return config.GetCollection(config.DB, config.OrganizationsCollection)
+}
+
+func CreateOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		var organization models.Organization
+		defer cancel()
+
+		if !bindJSON(c, &organization) {
+			return
+		}
+
+		newOrganization := models.Organization{
+			ID:         uuid.Must(uuid.NewRandom()).String(),
+			Name:       helpers.SanitizeText(organization.Name),
+			Slug:       strings.ToLower(organization.Slug),
+			AdminLevel: organization.AdminLevel,
+			Disabled:   organization.Disabled,
+			CreatedAt:  time.Now(),
+		}
+		newOrganization.UpdatedAt = newOrganization.CreatedAt
+
+		result, err := organizationCollection().InsertOne(ctx, newOrganization)
+		if mongo.IsDuplicateKeyError(err) {
+			helpers.Fail(c, apperrors.New(apperrors.SlugTaken))
+			return
+		}
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.RequestResponse{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": result}})
+	}
+}
+
+func DataOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		organizations := []models.Organization{}
+		defer cancel()
+
+		results, err := organizationCollection().Find(ctx, bson.M{})
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		//reading from the db in an optimal way
+		defer results.Close(ctx)
+		for results.Next(ctx) {
+			var singleOrganization models.Organization
+			if err = results.Decode(&singleOrganization); err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+
+			organizations = append(organizations, singleOrganization)
+		}
+
+		c.JSON(http.StatusOK,
+			models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": organizations}},
+		)
+	}
+}
+
+func ReadOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		organization, err := helpers.FindOrganization(c.Request.Context(), c.Param("organizationId"))
+		if err == mongo.ErrNoDocuments {
+			helpers.Fail(c, apperrors.New(apperrors.OrganizationNotFound))
+			return
+		}
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": organization}})
+	}
+}
+
+func UpdateOrganization(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		organizationId := c.Param("organizationId")
+		var organization models.Organization
+		defer cancel()
+
+		if !bindJSON(c, &organization) {
+			return
+		}
+
+		// the default organization keeps serving requests that name no tenant
+		if organizationId == config.DefaultTenantId() && organization.Disabled {
+			helpers.Fail(c, apperrors.New(apperrors.DefaultOrganization))
+			return
+		}
+
+		update := bson.M{
+			"name":        helpers.SanitizeText(organization.Name),
+			"slug":        strings.ToLower(organization.Slug),
+			"admin_level": organization.AdminLevel,
+			"disabled":    organization.Disabled,
+			"updated_at":  time.Now(),
+		}
+		result, err := organizationCollection().UpdateOne(ctx, bson.M{"id": organizationId}, bson.M{"$set": update})
+		if mongo.IsDuplicateKeyError(err) {
+			helpers.Fail(c, apperrors.New(apperrors.SlugTaken))
+			return
+		}
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if result.MatchedCount < 1 {
+			helpers.Fail(c, apperrors.New(apperrors.OrganizationNotFound))
+			return
+		}
+
+		//get updated organization details
+		var updatedOrganization models.Organization
+		if err := organizationCollection().FindOne(ctx, bson.M{"id": organizationId}).Decode(&updatedOrganization); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": updatedOrganization}})
+	}
+}
+
+// DeleteOrganization removes an empty organization. Organizations that still have users are
+// disabled through UpdateOrganization instead, so their data is not orphaned.
+func DeleteOrganization(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		organizationId := c.Param("organizationId")
+		defer cancel()
+
+		if organizationId == config.DefaultTenantId() {
+			helpers.Fail(c, apperrors.New(apperrors.DefaultOrganization))
+			return
+		}
+
+		count, err := userCollection().CountDocuments(ctx, helpers.Scoped(organizationId, bson.M{}))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		if count > 0 {
+			helpers.Fail(c, apperrors.New(apperrors.OrganizationHasUsers))
+			return
+		}
+
+		result, err := organizationCollection().DeleteOne(ctx, bson.M{"id": organizationId})
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if result.DeletedCount < 1 {
+			helpers.Fail(c, apperrors.New(apperrors.OrganizationNotFound))
+			return
+		}
+
+		// levels and groups only make sense inside their organization
+		for _, collection := range []*mongo.Collection{levelCollection(), groupCollection()} {
+			if _, err := collection.DeleteMany(ctx, helpers.Scoped(organizationId, bson.M{})); err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK,
+			models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "Organization successfully deleted!"}},
+		)
+	}
+}
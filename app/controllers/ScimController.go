@@ -0,0 +1,1126 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+var (
+	scimPathFilter   = regexp.MustCompile(`\[[^\]]*\]`)
+	scimMemberFilter = regexp.MustCompile(`(?i)^members\[value eq "([^"]+)"\]$`)
+)
+
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+func scimBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2"
+}
+
+// scimPaging reads startIndex (1-based) and count from the query string (RFC 7644 section 3.4.2.4).
+func scimPaging(c *gin.Context) (int64, int64) {
+	startIndex, err := strconv.ParseInt(c.DefaultQuery("startIndex", "1"), 10, 64)
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+
+	count, err := strconv.ParseInt(c.DefaultQuery("count", strconv.Itoa(scimDefaultCount)), 10, 64)
+	if err != nil || count < 0 {
+		count = scimDefaultCount
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+
+	return startIndex, count
+}
+
+func scimUser(c *gin.Context, user models.DBResponse) models.ScimUser {
+	active := !user.Disabled
+	created, modified := user.CreatedAt, user.UpdatedAt
+
+	resource := models.ScimUser{
+		Schemas:     []string{models.ScimUserSchema},
+		ID:          user.Id,
+		ExternalID:  user.ExternalId,
+		UserName:    user.Email,
+		Name:        &models.ScimName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []models.ScimMultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &models.ScimMeta{
+			ResourceType: "User",
+			Created:      &created,
+			LastModified: &modified,
+			Location:     scimBaseURL(c) + "/Users/" + user.Id,
+		},
+	}
+
+	if user.Group != "" {
+		resource.Groups = []models.ScimMultiValue{{Value: user.Group, Ref: scimBaseURL(c) + "/Groups/" + user.Group}}
+	}
+
+	return resource
+}
+
+func scimGroup(c *gin.Context, ctx context.Context, group models.Group, withMembers bool) (models.ScimGroup, error) {
+	resource := models.ScimGroup{
+		Schemas:     []string{models.ScimGroupSchema, models.ScimGroupExtension},
+		ID:          group.ID,
+		ExternalID:  group.ExternalId,
+		DisplayName: group.Name,
+		Extension:   &models.ScimGroupExtensionAttributes{Acronym: group.Acronym},
+		Meta: &models.ScimMeta{
+			ResourceType: "Group",
+			Location:     scimBaseURL(c) + "/Groups/" + group.ID,
+		},
+	}
+
+	if !withMembers {
+		return resource, nil
+	}
+
+	results, err := userCollection().Find(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"group": group.ID}), options.Find().SetProjection(bson.M{"id": 1, "name": 1}))
+	if err != nil {
+		return resource, err
+	}
+	defer results.Close(ctx)
+
+	resource.Members = []models.ScimMultiValue{}
+	for results.Next(ctx) {
+		var member models.DBResponse
+		if err := results.Decode(&member); err != nil {
+			return resource, err
+		}
+		resource.Members = append(resource.Members, models.ScimMultiValue{
+			Value:   member.Id,
+			Display: member.Name,
+			Ref:     scimBaseURL(c) + "/Users/" + member.Id,
+		})
+	}
+
+	return resource, results.Err()
+}
+
+// scimUserEmail prefers userName when it is an address, otherwise the primary email.
+func scimUserEmail(user models.ScimUser) string {
+	if strings.Contains(user.UserName, "@") {
+		return strings.ToLower(user.UserName)
+	}
+	for _, email := range user.Emails {
+		if email.Primary {
+			return strings.ToLower(email.Value)
+		}
+	}
+	if len(user.Emails) > 0 {
+		return strings.ToLower(user.Emails[0].Value)
+	}
+	return strings.ToLower(user.UserName)
+}
+
+// scimUserName returns the display name of a SCIM user, stripped of markup like every other name.
+func scimUserName(user models.ScimUser) string {
+	if user.Name != nil {
+		if user.Name.Formatted != "" {
+			return helpers.SanitizeText(user.Name.Formatted)
+		}
+		if full := strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName); full != "" {
+			return helpers.SanitizeText(full)
+		}
+	}
+	if user.DisplayName != "" {
+		return helpers.SanitizeText(user.DisplayName)
+	}
+	return helpers.SanitizeText(user.UserName)
+}
+
+// scimEmailTaken reports whether another user of the organization already owns email.
+func scimEmailTaken(ctx context.Context, tenantId string, email string, exceptId string) (bool, error) {
+	count, err := userCollection().CountDocuments(ctx, helpers.Scoped(tenantId, bson.M{"email": email, "id": bson.M{"$ne": exceptId}}))
+	return count > 0, err
+}
+
+func ScimServiceProviderConfig() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scimJSON(c, http.StatusOK, gin.H{
+			"schemas":          []string{models.ScimServiceProviderConf},
+			"patch":            gin.H{"supported": true},
+			"bulk":             gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+			"filter":           gin.H{"supported": true, "maxResults": scimMaxCount},
+			"changePassword":   gin.H{"supported": true},
+			"sort":             gin.H{"supported": false},
+			"etag":             gin.H{"supported": false},
+			"pagination":       gin.H{"cursor": false, "index": true, "defaultPageSize": scimDefaultCount, "maxPageSize": scimMaxCount},
+			"documentationUri": "",
+			"authenticationSchemes": []gin.H{{
+				"type":        "oauthbearertoken",
+				"name":        "OAuth Bearer Token",
+				"description": "Authentication using the bearer token configured in SCIM_TOKEN",
+				"primary":     true,
+			}},
+			"meta": gin.H{"resourceType": "ServiceProviderConfig", "location": scimBaseURL(c) + "/ServiceProviderConfig"},
+		})
+	}
+}
+
+func ScimResourceTypes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resourceTypes := []gin.H{
+			{
+				"schemas":  []string{models.ScimResourceTypeSchema},
+				"id":       "User",
+				"name":     "User",
+				"endpoint": "/Users",
+				"schema":   models.ScimUserSchema,
+				"meta":     gin.H{"resourceType": "ResourceType", "location": scimBaseURL(c) + "/ResourceTypes/User"},
+			},
+			{
+				"schemas":          []string{models.ScimResourceTypeSchema},
+				"id":               "Group",
+				"name":             "Group",
+				"endpoint":         "/Groups",
+				"schema":           models.ScimGroupSchema,
+				"schemaExtensions": []gin.H{{"schema": models.ScimGroupExtension, "required": false}},
+				"meta":             gin.H{"resourceType": "ResourceType", "location": scimBaseURL(c) + "/ResourceTypes/Group"},
+			},
+		}
+
+		scimJSON(c, http.StatusOK, models.ScimListResponse{
+			Schemas:      []string{models.ScimListResponseSchema},
+			TotalResults: int64(len(resourceTypes)),
+			StartIndex:   1,
+			ItemsPerPage: len(resourceTypes),
+			Resources:    resourceTypes,
+		})
+	}
+}
+
+func ScimListUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		query, err := helpers.ParseScimFilter(c.Query("filter"), helpers.ScimUserAttributes)
+		if err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		query = helpers.Scoped(helpers.TenantId(c), query)
+
+		startIndex, count := scimPaging(c)
+
+		total, err := userCollection().CountDocuments(ctx, query)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		users := []models.ScimUser{}
+		if count > 0 {
+			findOptions := options.Find().SetSort(bson.M{"created_at": 1}).SetSkip(startIndex - 1).SetLimit(count)
+			results, err := userCollection().Find(ctx, query, findOptions)
+			if err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+			defer results.Close(ctx)
+
+			for results.Next(ctx) {
+				var user models.DBResponse
+				if err := results.Decode(&user); err != nil {
+					helpers.ScimFail(c, err)
+					return
+				}
+				users = append(users, scimUser(c, user))
+			}
+		}
+
+		scimJSON(c, http.StatusOK, models.ScimListResponse{
+			Schemas:      []string{models.ScimListResponseSchema},
+			TotalResults: total,
+			StartIndex:   startIndex,
+			ItemsPerPage: len(users),
+			Resources:    users,
+		})
+	}
+}
+
+func ScimGetUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := helpers.FindUserById(c.Request.Context(), helpers.TenantId(c), c.Param("id"))
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				helpers.ScimAbort(c, http.StatusNotFound, "", "User not found")
+				return
+			}
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		scimJSON(c, http.StatusOK, scimUser(c, *user))
+	}
+}
+
+func ScimCreateUser(appConfig config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		var resource models.ScimUser
+		defer cancel()
+
+		if err := c.ShouldBindJSON(&resource); err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+
+		if validationErr := validate.Struct(&resource); validationErr != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", validationErr.Error())
+			return
+		}
+
+		email := scimUserEmail(resource)
+		taken, err := scimEmailTaken(ctx, helpers.TenantId(c), email, "")
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if taken {
+			helpers.ScimAbort(c, http.StatusConflict, "uniqueness", "userName is already in use")
+			return
+		}
+
+		newUser := models.UserSave{
+			Id:         uuid.Must(uuid.NewRandom()).String(),
+			TenantId:   helpers.TenantId(c),
+			Name:       scimUserName(resource),
+			Email:      email,
+			Level:      appConfig.ScimDefaultLevel,
+			ExternalId: resource.ExternalID,
+			Disabled:   resource.Active != nil && !*resource.Active,
+			Provider:   "scim",
+			CreatedAt:  time.Now(),
+		}
+		newUser.UpdatedAt = newUser.CreatedAt
+
+		// a pushed password makes the account usable with the local provider
+		if resource.Password != "" {
+			if !scimCheckPassword(c, ctx, helpers.NewPasswordPolicy(appConfig), resource.Password, nil, email) {
+				return
+			}
+			hashedPassword, err := config.HashPassword(resource.Password)
+			if err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+			newUser.Password = hashedPassword
+			newUser.Provider = ""
+			newUser.PasswordChangedAt = &newUser.CreatedAt
+		}
+
+		if _, err := userCollection().InsertOne(ctx, newUser); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				helpers.ScimAbort(c, http.StatusConflict, "uniqueness", "userName is already in use")
+				return
+			}
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		user, err := helpers.FindUserById(ctx, newUser.TenantId, newUser.Id)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		c.Header("Location", scimBaseURL(c)+"/Users/"+user.Id)
+		scimJSON(c, http.StatusCreated, scimUser(c, *user))
+	}
+}
+
+func ScimReplaceUser(appConfig config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		userId := c.Param("id")
+		var resource models.ScimUser
+		defer cancel()
+
+		if err := c.ShouldBindJSON(&resource); err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+
+		if validationErr := validate.Struct(&resource); validationErr != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", validationErr.Error())
+			return
+		}
+
+		email := scimUserEmail(resource)
+		taken, err := scimEmailTaken(ctx, helpers.TenantId(c), email, userId)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if taken {
+			helpers.ScimAbort(c, http.StatusConflict, "uniqueness", "userName is already in use")
+			return
+		}
+
+		existing, ok := scimFindUser(c, ctx, userId)
+		if !ok {
+			return
+		}
+
+		policy := helpers.NewPasswordPolicy(appConfig)
+		if resource.Password != "" && !scimCheckPassword(c, ctx, policy, resource.Password, existing, email) {
+			return
+		}
+
+		update := bson.M{"$set": bson.M{
+			"name":        scimUserName(resource),
+			"email":       email,
+			"external_id": resource.ExternalID,
+			"disabled":    resource.Active != nil && !*resource.Active,
+			"updated_at":  time.Now(),
+		}}
+		if resource.Password != "" {
+			// the account becomes a local one, LocalProvider refuses users with a provider
+			update["$unset"] = bson.M{"provider": ""}
+		}
+
+		result, err := userCollection().UpdateOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": userId}), update)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if result.MatchedCount == 0 {
+			helpers.ScimAbort(c, http.StatusNotFound, "", "User not found")
+			return
+		}
+
+		if resource.Password != "" {
+			if err := scimSavePassword(ctx, existing, resource.Password, policy); err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+		}
+
+		user, err := helpers.FindUserById(ctx, helpers.TenantId(c), userId)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		scimJSON(c, http.StatusOK, scimUser(c, *user))
+	}
+}
+
+// scimValueError is a patch error caused by the operation's value rather than its path.
+type scimValueError string
+
+func (err scimValueError) Error() string {
+	return string(err)
+}
+
+// scimString returns a patch value that must be a string. Anything else, null included, is
+// refused rather than stored in its printed form.
+func scimString(path string, value interface{}) (string, error) {
+	text, ok := value.(string)
+	if !ok {
+		return "", scimValueError(fmt.Sprintf("%s must be a string", path))
+	}
+	return text, nil
+}
+
+// scimPatchError answers a request whose patch operation could not be applied.
+func scimPatchError(c *gin.Context, err error) {
+	var valueErr scimValueError
+	if errors.As(err, &valueErr) {
+		helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	helpers.ScimAbort(c, http.StatusBadRequest, "invalidPath", err.Error())
+}
+
+// scimUserPatch applies one add/replace operation on a user attribute to the $set document. A
+// new password is handed back through password, it is stored separately with its history.
+func scimUserPatch(path string, value interface{}, set bson.M, password *string) error {
+	path = strings.ToLower(scimPathFilter.ReplaceAllString(path, ""))
+
+	if path == "" {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return scimValueError("operation without path needs an object value")
+		}
+		for key, v := range values {
+			if err := scimUserPatch(key, v, set, password); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch path {
+	case "active":
+		active, ok := value.(bool)
+		if !ok {
+			// some providers send booleans as strings
+			parsed, err := strconv.ParseBool(fmt.Sprint(value))
+			if err != nil {
+				return scimValueError("active must be a boolean")
+			}
+			active = parsed
+		}
+		set["disabled"] = !active
+	case "username", "emails", "emails.value":
+		email := ""
+		switch v := value.(type) {
+		case string:
+			email = v
+		case []interface{}:
+			if len(v) > 0 {
+				if first, ok := v[0].(map[string]interface{}); ok {
+					email, _ = first["value"].(string)
+				}
+			}
+		}
+		if email == "" {
+			return scimValueError(fmt.Sprintf("%s needs an email address", path))
+		}
+		set["email"] = strings.ToLower(email)
+	case "displayname", "name.formatted":
+		name, err := scimString(path, value)
+		if err != nil {
+			return err
+		}
+		set["name"] = helpers.SanitizeText(name)
+	case "name":
+		name, ok := value.(map[string]interface{})
+		if !ok {
+			return scimValueError("name must be an object")
+		}
+		formatted, _ := name["formatted"].(string)
+		if formatted == "" {
+			givenName, _ := name["givenName"].(string)
+			familyName, _ := name["familyName"].(string)
+			formatted = strings.TrimSpace(givenName + " " + familyName)
+		}
+		if formatted == "" {
+			return scimValueError("name needs formatted, givenName or familyName")
+		}
+		set["name"] = helpers.SanitizeText(formatted)
+	case "externalid":
+		externalId, err := scimString(path, value)
+		if err != nil {
+			return err
+		}
+		set["external_id"] = externalId
+	case "password":
+		newPassword, err := scimString(path, value)
+		if err != nil {
+			return err
+		}
+		*password = newPassword
+	case "schemas", "id", "meta":
+		// read-only, ignored
+	default:
+		return fmt.Errorf("unsupported path %q", path)
+	}
+
+	return nil
+}
+
+func ScimPatchUser(appConfig config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		userId := c.Param("id")
+		var patch models.ScimPatchRequest
+		defer cancel()
+
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+
+		if validationErr := validate.Struct(&patch); validationErr != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", validationErr.Error())
+			return
+		}
+
+		set := bson.M{}
+		unset := bson.M{}
+		password := ""
+		for _, operation := range patch.Operations {
+			switch strings.ToLower(operation.Op) {
+			case "add", "replace":
+				if err := scimUserPatch(operation.Path, operation.Value, set, &password); err != nil {
+					scimPatchError(c, err)
+					return
+				}
+			case "remove":
+				if strings.ToLower(operation.Path) != "externalid" {
+					helpers.ScimAbort(c, http.StatusBadRequest, "mutability", fmt.Sprintf("%q cannot be removed", operation.Path))
+					return
+				}
+				unset["external_id"] = ""
+			default:
+				helpers.ScimAbort(c, http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("unsupported op %q", operation.Op))
+				return
+			}
+		}
+
+		if email, ok := set["email"].(string); ok {
+			taken, err := scimEmailTaken(ctx, helpers.TenantId(c), email, userId)
+			if err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+			if taken {
+				helpers.ScimAbort(c, http.StatusConflict, "uniqueness", "userName is already in use")
+				return
+			}
+		}
+
+		existing, ok := scimFindUser(c, ctx, userId)
+		if !ok {
+			return
+		}
+
+		policy := helpers.NewPasswordPolicy(appConfig)
+		if password != "" {
+			email, _ := set["email"].(string)
+			if email == "" {
+				email = existing.Email
+			}
+			if !scimCheckPassword(c, ctx, policy, password, existing, email) {
+				return
+			}
+			// the account becomes a local one, LocalProvider refuses users with a provider
+			unset["provider"] = ""
+		}
+
+		set["updated_at"] = time.Now()
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		result, err := userCollection().UpdateOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": userId}), update)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if result.MatchedCount == 0 {
+			helpers.ScimAbort(c, http.StatusNotFound, "", "User not found")
+			return
+		}
+
+		if password != "" {
+			if err := scimSavePassword(ctx, existing, password, policy); err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+		}
+
+		user, err := helpers.FindUserById(ctx, helpers.TenantId(c), userId)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		scimJSON(c, http.StatusOK, scimUser(c, *user))
+	}
+}
+
+// scimFindUser loads the user a request addresses. It reports false after answering the request.
+func scimFindUser(c *gin.Context, ctx context.Context, userId string) (*models.DBResponse, bool) {
+	user, err := helpers.FindUserById(ctx, helpers.TenantId(c), userId)
+	if err == mongo.ErrNoDocuments {
+		helpers.ScimAbort(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	if err != nil {
+		helpers.ScimFail(c, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// scimCheckPassword holds a password pushed by the identity provider to the same policy as one
+// chosen by the user. user is nil for accounts that do not exist yet. It reports false after
+// answering the request.
+func scimCheckPassword(c *gin.Context, ctx context.Context, policy helpers.PasswordPolicy, password string, user *models.DBResponse, email string) bool {
+	violations := policy.Check(ctx, password, user, email)
+	if len(violations) == 0 {
+		return true
+	}
+
+	rules := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		rules = append(rules, violation.Code)
+	}
+	helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", "password does not meet the password policy: "+strings.Join(rules, ", "))
+	return false
+}
+
+// scimSavePassword stores a password that passed scimCheckPassword, keeping the history and age
+// the policy relies on.
+func scimSavePassword(ctx context.Context, user *models.DBResponse, password string, policy helpers.PasswordPolicy) error {
+	hashedPassword, err := config.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return helpers.SavePassword(ctx, user, hashedPassword, policy.History)
+}
+
+func ScimDeleteUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		result, err := userCollection().DeleteOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": c.Param("id")}))
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if result.DeletedCount == 0 {
+			helpers.ScimAbort(c, http.StatusNotFound, "", "User not found")
+			return
+		}
+
+		if err := helpers.DeleteMemberships(ctx, helpers.TenantId(c), bson.M{"user_id": c.Param("id")}); err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// scimMemberIds extracts user ids from a members value, either a single member object or a list of them.
+func scimMemberIds(value interface{}) []string {
+	var members []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		members = v
+	case map[string]interface{}:
+		if nested, ok := v["members"].([]interface{}); ok {
+			members = nested
+		} else {
+			members = []interface{}{v}
+		}
+	}
+
+	ids := []string{}
+	for _, member := range members {
+		if m, ok := member.(map[string]interface{}); ok {
+			if id, ok := m["value"].(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// setGroupMembers moves the given users into the group. Users belong to a single group, so
+// adding a member takes them out of their previous group. With replace, current members not
+// listed are removed from the group. Only users of the group's organization are touched.
+func setGroupMembers(ctx context.Context, tenantId string, groupId string, userIds []string, replace bool) error {
+	if replace {
+		_, err := userCollection().UpdateMany(ctx,
+			helpers.Scoped(tenantId, bson.M{"group": groupId, "id": bson.M{"$nin": userIds}}),
+			bson.M{"$set": bson.M{"group": "", "updated_at": time.Now()}})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	_, err := userCollection().UpdateMany(ctx,
+		helpers.Scoped(tenantId, bson.M{"id": bson.M{"$in": userIds}}),
+		bson.M{"$set": bson.M{"group": groupId, "updated_at": time.Now()}})
+	return err
+}
+
+func removeGroupMembers(ctx context.Context, tenantId string, groupId string, userIds []string) error {
+	filter := helpers.Scoped(tenantId, bson.M{"group": groupId})
+	if userIds != nil {
+		filter["id"] = bson.M{"$in": userIds}
+	}
+
+	_, err := userCollection().UpdateMany(ctx, filter, bson.M{"$set": bson.M{"group": "", "updated_at": time.Now()}})
+	return err
+}
+
+// groupAcronym derives an acronym from the initials of the group name when the client does not send one.
+func groupAcronym(resource models.ScimGroup) string {
+	if resource.Extension != nil && resource.Extension.Acronym != "" {
+		return helpers.SanitizeText(resource.Extension.Acronym)
+	}
+
+	var acronym strings.Builder
+	for _, word := range strings.Fields(resource.DisplayName) {
+		acronym.WriteString(strings.ToUpper(string([]rune(word)[:1])))
+	}
+	return acronym.String()
+}
+
+func findGroup(ctx context.Context, tenantId string, groupId string) (*models.Group, error) {
+	var group models.Group
+	if err := groupCollection().FindOne(ctx, helpers.Scoped(tenantId, bson.M{"id": groupId})).Decode(&group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func scimRespondGroup(c *gin.Context, ctx context.Context, status int, groupId string) {
+	group, err := findGroup(ctx, helpers.TenantId(c), groupId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			helpers.ScimAbort(c, http.StatusNotFound, "", "Group not found")
+			return
+		}
+		helpers.ScimFail(c, err)
+		return
+	}
+
+	resource, err := scimGroup(c, ctx, *group, true)
+	if err != nil {
+		helpers.ScimFail(c, err)
+		return
+	}
+
+	scimJSON(c, status, resource)
+}
+
+func ScimListGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		query, err := helpers.ParseScimFilter(c.Query("filter"), helpers.ScimGroupAttributes)
+		if err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		query = helpers.Scoped(helpers.TenantId(c), query)
+
+		startIndex, count := scimPaging(c)
+		withMembers := !strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+
+		total, err := groupCollection().CountDocuments(ctx, query)
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		groups := []models.ScimGroup{}
+		if count > 0 {
+			findOptions := options.Find().SetSort(bson.M{"name": 1}).SetSkip(startIndex - 1).SetLimit(count)
+			results, err := groupCollection().Find(ctx, query, findOptions)
+			if err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+			defer results.Close(ctx)
+
+			for results.Next(ctx) {
+				var group models.Group
+				if err := results.Decode(&group); err != nil {
+					helpers.ScimFail(c, err)
+					return
+				}
+				resource, err := scimGroup(c, ctx, group, withMembers)
+				if err != nil {
+					helpers.ScimFail(c, err)
+					return
+				}
+				groups = append(groups, resource)
+			}
+		}
+
+		scimJSON(c, http.StatusOK, models.ScimListResponse{
+			Schemas:      []string{models.ScimListResponseSchema},
+			TotalResults: total,
+			StartIndex:   startIndex,
+			ItemsPerPage: len(groups),
+			Resources:    groups,
+		})
+	}
+}
+
+func ScimGetGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		scimRespondGroup(c, ctx, http.StatusOK, c.Param("id"))
+	}
+}
+
+func ScimCreateGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		var resource models.ScimGroup
+		defer cancel()
+
+		if err := c.ShouldBindJSON(&resource); err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+
+		if validationErr := validate.Struct(&resource); validationErr != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", validationErr.Error())
+			return
+		}
+
+		resource.DisplayName = helpers.SanitizeText(resource.DisplayName)
+		count, err := groupCollection().CountDocuments(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"name": resource.DisplayName}))
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if count > 0 {
+			helpers.ScimAbort(c, http.StatusConflict, "uniqueness", "displayName is already in use")
+			return
+		}
+
+		newGroup := models.Group{
+			ID:         uuid.Must(uuid.NewRandom()).String(),
+			TenantId:   helpers.TenantId(c),
+			Name:       resource.DisplayName,
+			Acronym:    groupAcronym(resource),
+			ExternalId: resource.ExternalID,
+			Ancestors:  []string{},
+		}
+
+		if _, err := groupCollection().InsertOne(ctx, newGroup); err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		if err := setGroupMembers(ctx, helpers.TenantId(c), newGroup.ID, scimMemberIds(resource.Members), false); err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		c.Header("Location", scimBaseURL(c)+"/Groups/"+newGroup.ID)
+		scimRespondGroup(c, ctx, http.StatusCreated, newGroup.ID)
+	}
+}
+
+func ScimReplaceGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		groupId := c.Param("id")
+		var resource models.ScimGroup
+		defer cancel()
+
+		if err := c.ShouldBindJSON(&resource); err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+
+		if validationErr := validate.Struct(&resource); validationErr != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", validationErr.Error())
+			return
+		}
+
+		resource.DisplayName = helpers.SanitizeText(resource.DisplayName)
+		update := bson.M{
+			"name":        resource.DisplayName,
+			"acronym":     groupAcronym(resource),
+			"external_id": resource.ExternalID,
+		}
+		result, err := groupCollection().UpdateOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": groupId}), bson.M{"$set": update})
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if result.MatchedCount == 0 {
+			helpers.ScimAbort(c, http.StatusNotFound, "", "Group not found")
+			return
+		}
+
+		if err := setGroupMembers(ctx, helpers.TenantId(c), groupId, scimMemberIds(resource.Members), true); err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		scimRespondGroup(c, ctx, http.StatusOK, groupId)
+	}
+}
+
+func ScimPatchGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		groupId := c.Param("id")
+		var patch models.ScimPatchRequest
+		defer cancel()
+
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+
+		if validationErr := validate.Struct(&patch); validationErr != nil {
+			helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", validationErr.Error())
+			return
+		}
+
+		if _, err := findGroup(ctx, helpers.TenantId(c), groupId); err != nil {
+			if err == mongo.ErrNoDocuments {
+				helpers.ScimAbort(c, http.StatusNotFound, "", "Group not found")
+				return
+			}
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		set := bson.M{}
+		for _, operation := range patch.Operations {
+			op := strings.ToLower(operation.Op)
+			path := strings.ToLower(operation.Path)
+			var err error
+
+			switch {
+			case op == "remove" && scimMemberFilter.MatchString(operation.Path):
+				err = removeGroupMembers(ctx, helpers.TenantId(c), groupId, []string{scimMemberFilter.FindStringSubmatch(operation.Path)[1]})
+			case op == "remove" && path == "members":
+				var ids []string
+				if operation.Value != nil {
+					ids = scimMemberIds(operation.Value)
+				}
+				err = removeGroupMembers(ctx, helpers.TenantId(c), groupId, ids)
+			case (op == "add" || op == "replace") && path == "members":
+				err = setGroupMembers(ctx, helpers.TenantId(c), groupId, scimMemberIds(operation.Value), op == "replace")
+			case (op == "add" || op == "replace") && path == "displayname":
+				var name string
+				if name, err = scimString(operation.Path, operation.Value); err == nil {
+					set["name"] = helpers.SanitizeText(name)
+				}
+			case (op == "add" || op == "replace") && path == "externalid":
+				var externalId string
+				if externalId, err = scimString(operation.Path, operation.Value); err == nil {
+					set["external_id"] = externalId
+				}
+			case (op == "add" || op == "replace") && path == "":
+				values, ok := operation.Value.(map[string]interface{})
+				if !ok {
+					helpers.ScimAbort(c, http.StatusBadRequest, "invalidValue", "operation without path needs an object value")
+					return
+				}
+				if value, ok := values["displayName"]; ok {
+					var name string
+					if name, err = scimString("displayName", value); err != nil {
+						break
+					}
+					set["name"] = helpers.SanitizeText(name)
+				}
+				if value, ok := values["externalId"]; ok {
+					var externalId string
+					if externalId, err = scimString("externalId", value); err != nil {
+						break
+					}
+					set["external_id"] = externalId
+				}
+				if members, ok := values["members"]; ok {
+					err = setGroupMembers(ctx, helpers.TenantId(c), groupId, scimMemberIds(members), op == "replace")
+				}
+			default:
+				helpers.ScimAbort(c, http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported %s of %q", operation.Op, operation.Path))
+				return
+			}
+
+			var valueErr scimValueError
+			if errors.As(err, &valueErr) {
+				scimPatchError(c, err)
+				return
+			}
+			if err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+		}
+
+		if len(set) > 0 {
+			if _, err := groupCollection().UpdateOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": groupId}), bson.M{"$set": set}); err != nil {
+				helpers.ScimFail(c, err)
+				return
+			}
+		}
+
+		scimRespondGroup(c, ctx, http.StatusOK, groupId)
+	}
+}
+
+func ScimDeleteGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		groupId := c.Param("id")
+		defer cancel()
+
+		// as in DeleteGroup, a group only goes once nothing hangs below it
+		children, err := groupCollection().CountDocuments(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"parent_id": groupId}))
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if children > 0 {
+			helpers.ScimAbort(c, http.StatusConflict, "", "Group has child groups, delete or move them first")
+			return
+		}
+
+		result, err := groupCollection().DeleteOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": groupId}))
+		if err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+		if result.DeletedCount == 0 {
+			helpers.ScimAbort(c, http.StatusNotFound, "", "Group not found")
+			return
+		}
+
+		if err := removeGroupMembers(ctx, helpers.TenantId(c), groupId, nil); err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		if err := helpers.DeleteMemberships(ctx, helpers.TenantId(c), bson.M{"group_id": groupId}); err != nil {
+			helpers.ScimFail(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScimUserPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		value    interface{}
+		want     bson.M
+		password string
+	}{
+		{"display name", "displayName", "Ana <script>alert(1)</script>Lima", bson.M{"name": "Ana Lima"}, ""},
+		{"name parts", "name", map[string]interface{}{"givenName": "Ana", "familyName": "Lima"}, bson.M{"name": "Ana Lima"}, ""},
+		{"name with only a family name", "name", map[string]interface{}{"familyName": "Lima"}, bson.M{"name": "Lima"}, ""},
+		{"external id", "externalId", "A-1", bson.M{"external_id": "A-1"}, ""},
+		{"password", "password", "Correct-Horse-9", bson.M{}, "Correct-Horse-9"},
+		{"email from emails", "emails", []interface{}{map[string]interface{}{"value": "Ana@Example.com"}}, bson.M{"email": "ana@example.com"}, ""},
+		{"active as a string", "active", "False", bson.M{"disabled": true}, ""},
+		{"without path", "", map[string]interface{}{"displayName": "Ana", "externalId": "A-1"}, bson.M{"name": "Ana", "external_id": "A-1"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			set := bson.M{}
+			password := ""
+			if err := scimUserPatch(test.path, test.value, set, &password); err != nil {
+				t.Fatalf("scimUserPatch(%q, %v) failed: %v", test.path, test.value, err)
+			}
+			if !reflect.DeepEqual(set, test.want) || password != test.password {
+				t.Errorf("scimUserPatch(%q, %v) set %v and password %q, want %v and %q", test.path, test.value, set, password, test.want, test.password)
+			}
+		})
+	}
+}
+
+func TestScimUserPatchRejectsValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		value interface{}
+	}{
+		{"null password", "password", nil},
+		{"numeric password", "password", 12345678},
+		{"object password", "password", map[string]interface{}{"value": "secret"}},
+		{"null display name", "displayName", nil},
+		{"numeric external id", "externalId", 42},
+		{"empty name", "name", map[string]interface{}{}},
+		{"name that is not an object", "name", "Ana"},
+		{"email that is not a string", "emails", []interface{}{map[string]interface{}{"value": 1}}},
+		{"active that is not a boolean", "active", "maybe"},
+		{"null inside a pathless operation", "", map[string]interface{}{"password": nil}},
+		{"pathless operation that is not an object", "", "Ana"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			set := bson.M{}
+			password := ""
+			err := scimUserPatch(test.path, test.value, set, &password)
+
+			var valueErr scimValueError
+			if !errors.As(err, &valueErr) {
+				t.Fatalf("scimUserPatch(%q, %v) = %v, want a value error", test.path, test.value, err)
+			}
+			if len(set) != 0 || password != "" {
+				t.Errorf("scimUserPatch(%q, %v) stored %v and password %q", test.path, test.value, set, password)
+			}
+		})
+	}
+
+	var valueErr scimValueError
+	if err := scimUserPatch("nickName", "ana", bson.M{}, new(string)); err == nil || errors.As(err, &valueErr) {
+		t.Errorf("scimUserPatch() = %v, want a path error for an unsupported attribute", err)
+	}
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/oauth2"
+)
+
+const oauthStateCookie = "oauth_state"
+
+var errUnverifiedEmail = errors.New("external email is not verified")
+
+func socialProvider(c *gin.Context, config config.Config) (config.OAuthProvider, bool) {
+	provider, found := config.SocialProviders()[strings.ToLower(c.Param("provider"))]
+	if !found {
+		helpers.Fail(c, apperrors.New(apperrors.UnknownProvider))
+		return provider, false
+	}
+
+	return provider, true
+}
+
+func SocialLogin(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, ok := socialProvider(c, config)
+		if !ok {
+			return
+		}
+
+		state, err := helpers.RandomToken(32)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		verifier, err := helpers.RandomToken(32)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		// state, PKCE verifier and organization travel with the browser for the length of the round trip
+		helpers.SetRedirectCookie(c, config, oauthStateCookie, state+"."+verifier+"."+helpers.TenantId(c), 10*60, "/auth/"+provider.Name)
+
+		url := helpers.OAuth2Config(provider).AuthCodeURL(state,
+			oauth2.SetAuthURLParam("code_challenge", helpers.PKCEChallenge(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+
+		c.Redirect(http.StatusFound, url)
+	}
+}
+
+func SocialCallback(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		provider, ok := socialProvider(c, config)
+		if !ok {
+			return
+		}
+
+		if reason := c.Query("error"); reason != "" {
+			helpers.Fail(c, apperrors.New(apperrors.ExternalLoginFailed).WithDetails(gin.H{"reason": reason}))
+			return
+		}
+
+		cookie, err := c.Cookie(oauthStateCookie)
+		helpers.ClearCookie(c, config, oauthStateCookie, "/auth/"+provider.Name)
+
+		state, rest, found := strings.Cut(cookie, ".")
+		verifier, tenant, _ := strings.Cut(rest, ".")
+		if err != nil || !found || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+			helpers.Fail(c, apperrors.New(apperrors.InvalidLoginSession))
+			return
+		}
+
+		// the provider redirects back without our X-Tenant-ID header
+		if tenant != "" && tenant != helpers.TenantId(c) && !helpers.SetTenant(c, tenant) {
+			return
+		}
+
+		token, err := helpers.OAuth2Config(provider).Exchange(ctx, c.Query("code"), oauth2.SetAuthURLParam("code_verifier", verifier))
+		if err != nil {
+			helpers.Fail(c, apperrors.Wrap(apperrors.ExternalLoginFailed, err))
+			return
+		}
+
+		identity, err := helpers.FetchExternalIdentity(ctx, provider, token)
+		if err != nil {
+			helpers.Fail(c, apperrors.Wrap(apperrors.UpstreamError, err))
+			return
+		}
+
+		user, err := socialUser(ctx, config, helpers.TenantId(c), provider, *identity)
+		if err != nil {
+			switch err {
+			case helpers.ErrUnknownUser:
+				helpers.Fail(c, apperrors.New(apperrors.UnknownEmail))
+			case errUnverifiedEmail:
+				helpers.Fail(c, apperrors.New(apperrors.UnverifiedEmail))
+			default:
+				helpers.Fail(c, err)
+			}
+			return
+		}
+
+		if user.Disabled {
+			helpers.Fail(c, apperrors.New(apperrors.AccountDisabled))
+			return
+		}
+
+		// the external provider only replaces the password, security keys are checked as in AuthUser
+		pending, err := beginSecondFactor(c, config, user)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		if pending {
+			return
+		}
+
+		if helpers.WebAuthnRequired(config, user) {
+			access_token, err := issueRegistrationSession(c, config, user)
+			if err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"status": "webauthn_registration_required", "access_token": access_token})
+			return
+		}
+
+		access_token, err := issueSession(c, config, user)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if config.OAuthLoginRedirect != "" {
+			c.Redirect(http.StatusFound, config.OAuthLoginRedirect)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+	}
+}
+
+// socialUser finds the account for an external identity. Identities already linked win; otherwise a
+// verified email links the identity to the existing user, or provisions a new one when the provider allows it.
+// Lookups stay inside the tenant the login was started for, and users are only provisioned in OAUTH_TENANT.
+func socialUser(ctx context.Context, config config.Config, tenantId string, provider config.OAuthProvider, identity models.ExternalIdentity) (*models.DBResponse, error) {
+	user, err := helpers.FindUserByIdentity(ctx, tenantId, identity.Provider, identity.Subject)
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errUnverifiedEmail
+	}
+
+	user, err = helpers.FindUserByEmail(ctx, tenantId, identity.Email)
+	if err == nil {
+		// a disabled account must not collect new ways in while it is locked
+		if user.Disabled {
+			return user, nil
+		}
+		if err := helpers.LinkIdentity(ctx, user.Id, identity); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	if !provider.AutoProvision || tenantId != config.OAuthTenantId() {
+		return nil, helpers.ErrUnknownUser
+	}
+
+	name := identity.Name
+	if name == "" {
+		name = identity.Email
+	}
+
+	newUser := models.UserSave{
+		Id:       uuid.Must(uuid.NewRandom()).String(),
+		TenantId: tenantId,
+		Name:     name,
+		Email:    identity.Email,
+		Level:    config.OAuthDefaultLevel,
+		Group:    config.OAuthDefaultGroup,
+		Provider: "oauth:" + identity.Provider,
+		Identities: []models.LinkedIdentity{{
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+			LinkedAt: time.Now(),
+		}},
+		CreatedAt: time.Now(),
+	}
+	newUser.UpdatedAt = newUser.CreatedAt
+
+	if _, err := userCollection().InsertOne(ctx, newUser); err != nil {
+		return nil, err
+	}
+
+	return helpers.FindUserById(ctx, tenantId, newUser.Id)
+}
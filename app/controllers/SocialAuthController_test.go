@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// withMockDB points config.DB at a mocked deployment that answers with the queued responses.
+func withMockDB(t *testing.T, appConfig config.Config, test func(mt *mtest.T)) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("mock", func(mt *mtest.T) {
+		config.Set(appConfig)
+		config.DB = mt.Client
+		t.Cleanup(func() { config.DB = nil })
+		test(mt)
+	})
+}
+
+func TestSocialUserProvisionsOnlyInItsTenant(t *testing.T) {
+	appConfig := config.Config{OAuthTenant: "acme", OAuthDefaultLevel: "member"}
+	provider := config.OAuthProvider{Name: "mock", AutoProvision: true}
+	identity := models.ExternalIdentity{Provider: "mock", Subject: "1", Email: "ana@example.com", EmailVerified: true, Name: "Ana"}
+	namespace := "user_management.users"
+	notFound := func() bson.D { return mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch) }
+
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		// neither the identity nor the email are known in the other organization
+		mt.AddMockResponses(notFound(), notFound())
+
+		_, err := socialUser(context.Background(), appConfig, "other", provider, identity)
+		if !errors.Is(err, helpers.ErrUnknownUser) {
+			t.Errorf("socialUser() = %v, want ErrUnknownUser outside OAUTH_TENANT", err)
+		}
+		if started := mt.GetAllStartedEvents(); len(started) != 2 {
+			t.Errorf("%d commands sent, want only the two lookups", len(started))
+		}
+	})
+
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		mt.AddMockResponses(notFound(), notFound(), mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch, bson.D{{Key: "id", Value: "user-1"}, {Key: "tenant_id", Value: "acme"}}))
+
+		user, err := socialUser(context.Background(), appConfig, "acme", provider, identity)
+		if err != nil || user.TenantId != "acme" {
+			t.Fatalf("socialUser() = %+v, %v, want a user provisioned in acme", user, err)
+		}
+		inserts := 0
+		for _, started := range mt.GetAllStartedEvents() {
+			if started.CommandName == "insert" {
+				inserts++
+			}
+		}
+		if inserts != 1 {
+			t.Errorf("%d users inserted, want one", inserts)
+		}
+	})
+}
@@ -1,346 +1,572 @@
-package controllers
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/RadenAbror/UserManagement/app/config"
-	"github.com/RadenAbror/UserManagement/app/helpers"
-	"github.com/RadenAbror/UserManagement/app/models"
-	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
-	"github.com/google/uuid"
-	"github.com/microcosm-cc/bluemonday"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-var userCollection *mongo.Collection = config.GetCollection(config.DB, "users")
-var validate = validator.New()
-
-func CreateUser() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		var user models.User
-		defer cancel()
-		p := bluemonday.UGCPolicy()
-
-		//validate the request body
-		if err := c.BindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&user); validationErr != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}})
-			return
-		}
-
-		if user.Password != user.PasswordConfirm {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": "Konfirmasi Password tidak sama dengan Password"}})
-			return
-		}
-
-		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&user)
-
-		if err != mongo.ErrNoDocuments {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": "Alamat email sudah terdaftar"}})
-			return
-		}
-
-		hashedPassword, _ := config.HashPassword(p.Sanitize(user.Password))
-		user.Password = hashedPassword
-		user.CreatedAt = time.Now()
-		user.UpdatedAt = user.CreatedAt
-		user.Id = uuid.Must(uuid.NewRandom()).String()
-		newUser := models.UserSave{
-			Id:        user.Id,
-			Name:      p.Sanitize(user.Name),
-			Email:     p.Sanitize(user.Email),
-			Level:     user.Level,
-			Group:     user.Group,
-			Password:  user.Password,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		}
-
-		result, err := userCollection.InsertOne(ctx, newUser)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		c.JSON(http.StatusCreated, models.RequestResponse{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": result}})
-	}
-}
-
-func AuthUser() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var userAuth models.UserAuth
-		p := bluemonday.UGCPolicy()
-
-		// validate the request body
-		if err := c.BindJSON(&userAuth); err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		// use the validator library to validate required fields
-		if validationErr := validate.Struct(&userAuth); validationErr != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}})
-			return
-		}
-
-		// check email
-		user, err := helpers.FindUserByEmail(p.Sanitize(userAuth.Email))
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(
-					http.StatusInternalServerError,
-					models.RequestResponse{
-						Status:  http.StatusInternalServerError,
-						Message: "error",
-						Data: map[string]interface{}{
-							"data": "Email tidak terdaftar!",
-						},
-					},
-				)
-				return
-			}
-		}
-
-		// check password
-		if err := config.VerifyPassword(user.Password, p.Sanitize(userAuth.Password)); err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": "Password!"}})
-			return
-		}
-
-		config, _ := config.LoadConfig(".")
-
-		// Generate Tokens
-		access_token, err := helpers.CreateToken(config.AccessTokenExpiresIn, user.Id, config.AccessTokenPrivateKey)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		refresh_token, err := helpers.CreateToken(config.RefreshTokenExpiresIn, user.Id, config.RefreshTokenPrivateKey)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		c.SetCookie("access_token", access_token, config.AccessTokenMaxAge*60, "/", "localhost", false, true)
-		c.SetCookie("refresh_token", refresh_token, config.RefreshTokenMaxAge*60, "/", "localhost", false, true)
-		c.SetCookie("logged_in", "true", config.AccessTokenMaxAge*60, "/", "localhost", false, false)
-
-		c.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
-	}
-}
-
-func GetAUser() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		userId := c.Param("userId")
-		var user models.UserSave
-		defer cancel()
-
-		objId := userId
-
-		err := userCollection.FindOne(ctx, bson.M{"id": objId}).Decode(&user)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": user}})
-	}
-}
-
-func EditAUser() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		userId := c.Param("userId")
-		var userEdit models.UserEdit
-		defer cancel()
-		p := bluemonday.UGCPolicy()
-
-		//validate the request body
-		if err := c.BindJSON(&userEdit); err != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&userEdit); validationErr != nil {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}})
-			return
-		}
-
-		if userEdit.Password != userEdit.PasswordConfirm {
-			c.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": "Konfirmasi Password tidak sama dengan Password"}})
-			return
-		}
-
-		objId := userId
-		// err := userCollection.FindOne(ctx, bson.M{"id": objId, "email": bson.M{"$ne": p.Sanitize(userEdit.Email)}}).Decode(&userEdit)
-		count, err := userCollection.CountDocuments(
-			ctx,
-			bson.D{{Key: "id", Value: objId}, {Key: "email", Value: p.Sanitize(userEdit.Email)}})
-		if err != nil {
-			log.Fatal(err)
-		}
-		if count == 0 {
-			checkEmail := userCollection.FindOne(ctx, bson.M{"email": p.Sanitize(userEdit.Email)}).Decode(&userEdit)
-			if checkEmail != mongo.ErrNoDocuments {
-				c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": "Email sudah digunakan!"}})
-				return
-			}
-		}
-
-		update := bson.M{
-			"name":       p.Sanitize(userEdit.Name),
-			"email":      p.Sanitize(userEdit.Email),
-			"level":      userEdit.Level,
-			"group":      userEdit.Group,
-			"updated_at": time.Now(),
-		}
-		result, err := userCollection.UpdateOne(ctx, bson.M{"id": objId}, bson.M{"$set": update})
-
-		if userEdit.Password != "" {
-			hashedPassword, _ := config.HashPassword(p.Sanitize(userEdit.Password))
-			userEdit.Password = hashedPassword
-			userCollection.UpdateOne(ctx, bson.M{"id": objId}, bson.M{"$set": bson.M{"password": userEdit.Password}})
-		}
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		//get updated user details
-		var updatedUser models.UserSave
-		if result.MatchedCount == 1 {
-			err := userCollection.FindOne(ctx, bson.M{"id": objId}).Decode(&updatedUser)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-				return
-			}
-		}
-
-		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": updatedUser}})
-	}
-}
-
-func GetMe() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		currentUser := c.MustGet("currentUser").(*models.DBResponse)
-		c.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"user": models.FilteredResponse(currentUser)}})
-	}
-}
-
-func DeleteAUser() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		userId := c.Param("userId")
-		defer cancel()
-
-		objId := userId
-
-		result, err := userCollection.DeleteOne(ctx, bson.M{"id": objId})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		if result.DeletedCount < 1 {
-			c.JSON(http.StatusNotFound,
-				models.RequestResponse{Status: http.StatusNotFound, Message: "error", Data: map[string]interface{}{"data": "User with specified ID not found!"}},
-			)
-			return
-		}
-
-		c.JSON(http.StatusOK,
-
-			models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "User successfully deleted!"}},
-		)
-	}
-}
-
-func GetAllUsers() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		var users []models.User
-		defer cancel()
-
-		results, err := userCollection.Find(ctx, bson.M{})
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		//reading from the db in an optimal way
-		defer results.Close(ctx)
-		for results.Next(ctx) {
-			var singleUser models.User
-			if err = results.Decode(&singleUser); err != nil {
-				c.JSON(http.StatusInternalServerError, models.RequestResponse{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			}
-
-			users = append(users, singleUser)
-		}
-
-		c.JSON(http.StatusOK,
-			models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": users}},
-		)
-	}
-
-}
-
-func RefreshAccessToken(ctx *gin.Context) {
-	message := "could not refresh access token"
-
-	cookie, err := ctx.Cookie("refresh_token")
-
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": message})
-		return
-	}
-
-	config, _ := config.LoadConfig(".")
-
-	sub, err := helpers.ValidateToken(cookie, config.RefreshTokenPublicKey)
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
-		return
-	}
-
-	user, err := helpers.FindUserById(fmt.Sprint(sub))
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "the user belonging to this token no logger exists"})
-		return
-	}
-
-	access_token, err := helpers.CreateToken(config.AccessTokenExpiresIn, user.ID, config.AccessTokenPrivateKey)
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
-		return
-	}
-
-	ctx.SetCookie("access_token", access_token, config.AccessTokenMaxAge*60, "/", "localhost", false, true)
-	ctx.SetCookie("logged_in", "true", config.AccessTokenMaxAge*60, "/", "localhost", false, false)
-
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
-}
-
-func LogoutUser(ctx *gin.Context) {
-	ctx.SetCookie("access_token", "", -1, "/", "localhost", false, true)
-	ctx.SetCookie("refresh_token", "", -1, "/", "localhost", false, true)
-	ctx.SetCookie("logged_in", "", -1, "/", "localhost", false, true)
-	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
-}
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/i18n"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func userCollection() *mongo.Collection {
+	return config.GetCollection(config.DB, config.UsersCollection)
+}
+
+var validate = validator.New()
+
+func init() {
+	// report invalid fields under the names clients send them with
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	if err := i18n.RegisterValidator(validate); err != nil {
+		panic(err)
+	}
+}
+
+// bindJSON decodes the request body into request and validates it. It reports false after
+// answering the request.
+func bindJSON(c *gin.Context, request interface{}) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		helpers.Fail(c, apperrors.Binding(err))
+		return false
+	}
+
+	if err := validate.Struct(request); err != nil {
+		helpers.Fail(c, apperrors.Validation(err))
+		return false
+	}
+
+	return true
+}
+
+func CreateUser(appConfig config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		var user models.User
+		defer cancel()
+
+		if !bindJSON(c, &user) {
+			return
+		}
+
+		if user.Password != user.PasswordConfirm {
+			helpers.Fail(c, apperrors.New(apperrors.PasswordMismatch))
+			return
+		}
+
+		user.Email = helpers.NormalizeEmail(user.Email)
+
+		// anyone can register, so the level and group are never taken from the request
+		user.Level = appConfig.RegisterDefaultLevel
+		user.Group = appConfig.RegisterDefaultGroup
+		if violations := helpers.NewPasswordPolicy(appConfig).Check(ctx, user.Password, nil, user.Email); len(violations) > 0 {
+			passwordPolicyFailed(c, violations)
+			return
+		}
+
+		tenantId := helpers.TenantId(c)
+		err := userCollection().FindOne(ctx, helpers.Scoped(tenantId, bson.M{"email": user.Email})).Decode(&user)
+		if err == nil {
+			helpers.Fail(c, apperrors.New(apperrors.EmailTaken))
+			return
+		}
+		if err != mongo.ErrNoDocuments {
+			helpers.Fail(c, err)
+			return
+		}
+
+		hashedPassword, err := config.HashPassword(user.Password)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		user.Password = hashedPassword
+		user.CreatedAt = time.Now()
+		user.UpdatedAt = user.CreatedAt
+		user.Id = uuid.Must(uuid.NewRandom()).String()
+		newUser := models.UserSave{
+			Id:                user.Id,
+			TenantId:          tenantId,
+			Name:              helpers.SanitizeText(user.Name),
+			Email:             user.Email,
+			Level:             user.Level,
+			Group:             user.Group,
+			Locale:            user.Locale,
+			Password:          user.Password,
+			PasswordChangedAt: &user.CreatedAt,
+			CreatedAt:         user.CreatedAt,
+			UpdatedAt:         user.UpdatedAt,
+		}
+
+		result, err := userCollection().InsertOne(ctx, newUser)
+		if mongo.IsDuplicateKeyError(err) {
+			// another request registered the same address since the check above
+			helpers.Fail(c, apperrors.New(apperrors.EmailTaken))
+			return
+		}
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.RequestResponse{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": result}})
+	}
+}
+
+func AuthUser(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userAuth models.UserAuth
+
+		if !bindJSON(c, &userAuth) {
+			return
+		}
+
+		// check email and password against the configured providers
+		user, err := helpers.AuthenticateUser(c.Request.Context(), helpers.AuthProviders(config), helpers.TenantId(c), helpers.NormalizeEmail(userAuth.Email), userAuth.Password)
+		if err != nil {
+			switch err {
+			case helpers.ErrUnknownUser:
+				helpers.RecordLogin(helpers.LoginUnknownUser)
+				helpers.Fail(c, apperrors.New(apperrors.UnknownEmail))
+			case helpers.ErrInvalidCredentials:
+				helpers.RecordLogin(helpers.LoginInvalidCredentials)
+				helpers.Fail(c, apperrors.New(apperrors.InvalidPassword))
+			case helpers.ErrAccountDisabled:
+				helpers.RecordLogin(helpers.LoginDisabled)
+				helpers.Fail(c, apperrors.New(apperrors.AccountDisabled))
+			default:
+				helpers.RecordLogin(helpers.LoginError)
+				helpers.Fail(c, err)
+			}
+			return
+		}
+
+		// move hashes made with an outdated algorithm or cost to the current one while we know the password
+		if err := helpers.UpgradePasswordHash(c.Request.Context(), user, userAuth.Password); err != nil {
+			helpers.Logger(c).Warn("could not upgrade password hash", "error", err)
+		}
+
+		// an expired password has to be replaced through /user/password/change before a session is issued
+		if helpers.NewPasswordPolicy(config).Expired(user) {
+			helpers.RecordLogin(helpers.LoginPasswordExpired)
+			helpers.Fail(c, apperrors.New(apperrors.PasswordExpired))
+			return
+		}
+
+		// users with a registered security key must complete the WebAuthn assertion first
+		pending, err := beginSecondFactor(c, config, user)
+		if err != nil {
+			helpers.RecordLogin(helpers.LoginError)
+			helpers.Fail(c, err)
+			return
+		}
+		if pending {
+			helpers.RecordLogin(helpers.LoginSecondFactor)
+			return
+		}
+
+		// a level that requires a security key gets nothing but the means to register one
+		if helpers.WebAuthnRequired(config, user) {
+			access_token, err := issueRegistrationSession(c, config, user)
+			if err != nil {
+				helpers.RecordLogin(helpers.LoginError)
+				helpers.Fail(c, err)
+				return
+			}
+
+			helpers.RecordLogin(helpers.LoginKeyRegistration)
+			c.JSON(http.StatusOK, gin.H{"status": "webauthn_registration_required", "access_token": access_token})
+			return
+		}
+
+		access_token, err := issueSession(c, config, user)
+		if err != nil {
+			helpers.RecordLogin(helpers.LoginError)
+			helpers.Fail(c, err)
+			return
+		}
+
+		helpers.RecordLogin(helpers.LoginSuccess)
+
+		c.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+	}
+}
+
+// issueSession creates the access and refresh tokens for user and sets the session cookies.
+func issueSession(c *gin.Context, config config.Config, user *models.DBResponse) (string, error) {
+	// Generate Tokens
+	tenant := map[string]interface{}{helpers.TenantClaim: user.TenantId}
+	access_token, err := helpers.CreateTokenWithClaims(c.Request.Context(), config.AccessTokenExpiresIn, user.Id, tenant, config.AccessTokenPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	refresh_token, err := helpers.CreateTokenWithClaims(c.Request.Context(), config.RefreshTokenExpiresIn, user.Id, tenant, config.RefreshTokenPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	helpers.SetCookie(c, config, "access_token", access_token, config.AccessTokenMaxAge*60, "/", true)
+	helpers.SetCookie(c, config, "refresh_token", refresh_token, config.RefreshTokenMaxAge*60, "/", true)
+	helpers.SetCookie(c, config, "logged_in", "true", config.AccessTokenMaxAge*60, "/", false)
+
+	return access_token, nil
+}
+
+// issueRegistrationSession creates an access token that only reaches the security key
+// registration routes. There is no refresh token: once a key is registered the user logs in
+// again with it.
+func issueRegistrationSession(c *gin.Context, config config.Config, user *models.DBResponse) (string, error) {
+	claims := map[string]interface{}{helpers.TenantClaim: user.TenantId, helpers.ScopeClaim: helpers.RegistrationScope}
+	access_token, err := helpers.CreateTokenWithClaims(c.Request.Context(), config.AccessTokenExpiresIn, user.Id, claims, config.AccessTokenPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	helpers.SetCookie(c, config, "access_token", access_token, config.AccessTokenMaxAge*60, "/", true)
+
+	return access_token, nil
+}
+
+func GetAUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		userId := c.Param("userId")
+		var user models.UserSave
+		defer cancel()
+
+		objId := userId
+
+		err := userCollection().FindOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": objId})).Decode(&user)
+		if err == mongo.ErrNoDocuments {
+			helpers.Fail(c, apperrors.New(apperrors.UserNotFound))
+			return
+		}
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": user}})
+	}
+}
+
+func EditAUser(appConfig config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		userId := c.Param("userId")
+		var userEdit models.UserEdit
+		defer cancel()
+
+		if !bindJSON(c, &userEdit) {
+			return
+		}
+
+		userEdit.Email = helpers.NormalizeEmail(userEdit.Email)
+
+		if userEdit.Password != userEdit.PasswordConfirm {
+			helpers.Fail(c, apperrors.New(apperrors.PasswordMismatch))
+			return
+		}
+
+		objId := userId
+		tenantId := helpers.TenantId(c)
+
+		existingUser, err := helpers.FindUserById(ctx, tenantId, objId)
+		if err != nil {
+			helpers.Fail(c, apperrors.Wrap(apperrors.UserNotFound, err))
+			return
+		}
+
+		// users edit their own name, email and password; other accounts and the level and
+		// group that grant rights are left to admins
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+		changesRights := (userEdit.Level != "" && userEdit.Level != existingUser.Level) ||
+			(userEdit.Group != "" && userEdit.Group != existingUser.Group)
+		if (existingUser.Id != currentUser.Id || changesRights) && !helpers.IsTenantAdmin(c, currentUser) {
+			helpers.Fail(c, apperrors.New(apperrors.Forbidden))
+			return
+		}
+
+		var policy helpers.PasswordPolicy
+		hashedPassword := ""
+		if userEdit.Password != "" {
+			policy = helpers.NewPasswordPolicy(appConfig)
+			if violations := policy.Check(ctx, userEdit.Password, existingUser, userEdit.Email); len(violations) > 0 {
+				passwordPolicyFailed(c, violations)
+				return
+			}
+
+			// hashed before anything is stored, so a password the algorithm refuses changes nothing
+			if hashedPassword, err = config.HashPassword(userEdit.Password); err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+		}
+
+		// err := userCollection.FindOne(ctx, bson.M{"id": objId, "email": bson.M{"$ne": p.Sanitize(userEdit.Email)}}).Decode(&userEdit)
+		count, err := userCollection().CountDocuments(
+			ctx,
+			bson.D{{Key: "tenant_id", Value: tenantId}, {Key: "id", Value: objId}, {Key: "email", Value: userEdit.Email}})
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		if count == 0 {
+			checkEmail := userCollection().FindOne(ctx, helpers.Scoped(tenantId, bson.M{"email": userEdit.Email})).Decode(&userEdit)
+			if checkEmail == nil {
+				helpers.Fail(c, apperrors.New(apperrors.EmailTaken))
+				return
+			}
+			if checkEmail != mongo.ErrNoDocuments {
+				helpers.Fail(c, checkEmail)
+				return
+			}
+		}
+
+		update := bson.M{
+			"name":       helpers.SanitizeText(userEdit.Name),
+			"email":      userEdit.Email,
+			"updated_at": time.Now(),
+		}
+		if userEdit.Level != "" {
+			update["level"] = userEdit.Level
+		}
+		if userEdit.Group != "" {
+			update["group"] = userEdit.Group
+		}
+		if userEdit.Locale != "" {
+			update["locale"] = userEdit.Locale
+		}
+		result, err := userCollection().UpdateOne(ctx, helpers.Scoped(tenantId, bson.M{"id": objId}), bson.M{"$set": update})
+		if mongo.IsDuplicateKeyError(err) {
+			helpers.Fail(c, apperrors.New(apperrors.EmailTaken))
+			return
+		}
+
+		if err == nil && hashedPassword != "" {
+			err = helpers.SavePassword(ctx, existingUser, hashedPassword, policy.History)
+		}
+
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		//get updated user details
+		var updatedUser models.UserSave
+		if result.MatchedCount == 1 {
+			err := userCollection().FindOne(ctx, helpers.Scoped(tenantId, bson.M{"id": objId})).Decode(&updatedUser)
+			if err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": updatedUser}})
+	}
+}
+
+// ChangePassword replaces the password of a local account after checking the current one. It is
+// also the way out for users whose password has expired, so it does not require a session.
+func ChangePassword(appConfig config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var change models.PasswordChange
+
+		if !bindJSON(c, &change) {
+			return
+		}
+
+		if change.NewPassword != change.NewPasswordConfirm {
+			helpers.Fail(c, apperrors.New(apperrors.PasswordMismatch))
+			return
+		}
+
+		user, err := helpers.LocalProvider{}.Authenticate(c.Request.Context(), helpers.TenantId(c), helpers.NormalizeEmail(change.Email), change.OldPassword)
+		if err != nil {
+			helpers.Fail(c, apperrors.Wrap(apperrors.InvalidCredentials, err))
+			return
+		}
+		if user.Disabled {
+			helpers.Fail(c, apperrors.New(apperrors.AccountDisabled))
+			return
+		}
+
+		policy := helpers.NewPasswordPolicy(appConfig)
+		if violations := policy.Check(c.Request.Context(), change.NewPassword, user, user.Email); len(violations) > 0 {
+			passwordPolicyFailed(c, violations)
+			return
+		}
+
+		hashedPassword, err := config.HashPassword(change.NewPassword)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		if err := helpers.SavePassword(c.Request.Context(), user, hashedPassword, policy.History); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "Password berhasil diubah"}})
+	}
+}
+
+func passwordPolicyFailed(c *gin.Context, violations []models.PasswordViolation) {
+	helpers.Fail(c, apperrors.New(apperrors.PasswordPolicy).WithDetails(violations))
+}
+
+func GetMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		// permissions include those inherited from the groups above the user's group
+		access, err := helpers.UserGroupAccess(c.Request.Context(), currentUser)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if impersonator, exists := c.Get("impersonator"); exists {
+			c.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{
+				"user":         models.FilteredResponse(currentUser),
+				"impersonator": models.FilteredResponse(impersonator.(*models.DBResponse)),
+				"permissions":  access.Permissions,
+				"memberships":  currentUser.Memberships,
+			}})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"user": models.FilteredResponse(currentUser), "permissions": access.Permissions, "memberships": currentUser.Memberships}})
+	}
+}
+
+func DeleteAUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		userId := c.Param("userId")
+		defer cancel()
+
+		objId := userId
+
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+		if objId != currentUser.Id && !helpers.IsTenantAdmin(c, currentUser) {
+			helpers.Fail(c, apperrors.New(apperrors.Forbidden))
+			return
+		}
+
+		result, err := userCollection().DeleteOne(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{"id": objId}))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if result.DeletedCount < 1 {
+			helpers.Fail(c, apperrors.New(apperrors.UserNotFound))
+			return
+		}
+
+		if err := helpers.DeleteMemberships(ctx, helpers.TenantId(c), bson.M{"user_id": objId}); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK,
+
+			models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "User successfully deleted!"}},
+		)
+	}
+}
+
+func GetAllUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		var users []models.User
+		defer cancel()
+
+		results, err := userCollection().Find(ctx, helpers.Scoped(helpers.TenantId(c), bson.M{}))
+
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		//reading from the db in an optimal way
+		defer results.Close(ctx)
+		for results.Next(ctx) {
+			var singleUser models.User
+			if err = results.Decode(&singleUser); err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+
+			users = append(users, singleUser)
+		}
+
+		c.JSON(http.StatusOK,
+			models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": users}},
+		)
+	}
+
+}
+
+func RefreshAccessToken(ctx *gin.Context) {
+	cookie, err := ctx.Cookie("refresh_token")
+
+	if err != nil {
+		helpers.Fail(ctx, apperrors.New(apperrors.Unauthenticated))
+		return
+	}
+
+	config := helpers.AppConfig(ctx)
+
+	claims, err := helpers.ValidateTokenClaims(ctx.Request.Context(), cookie, config.RefreshTokenPublicKey)
+	if err != nil {
+		helpers.Fail(ctx, apperrors.Wrap(apperrors.InvalidToken, err))
+		return
+	}
+
+	tenantId := helpers.TokenTenant(config, claims)
+	user, err := helpers.FindUserById(ctx.Request.Context(), tenantId, fmt.Sprint(claims["sub"]))
+	if err != nil {
+		helpers.Fail(ctx, apperrors.Wrap(apperrors.SessionUserNotFound, err))
+		return
+	}
+
+	access_token, err := helpers.CreateTokenWithClaims(ctx.Request.Context(), config.AccessTokenExpiresIn, user.Id, map[string]interface{}{helpers.TenantClaim: tenantId}, config.AccessTokenPrivateKey)
+	if err != nil {
+		helpers.Fail(ctx, err)
+		return
+	}
+
+	helpers.SetCookie(ctx, config, "access_token", access_token, config.AccessTokenMaxAge*60, "/", true)
+	helpers.SetCookie(ctx, config, "logged_in", "true", config.AccessTokenMaxAge*60, "/", false)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+}
+
+func LogoutUser(ctx *gin.Context) {
+	config := helpers.AppConfig(ctx)
+
+	helpers.ClearCookie(ctx, config, "access_token", "/")
+	helpers.ClearCookie(ctx, config, "refresh_token", "/")
+	helpers.ClearCookie(ctx, config, "logged_in", "/")
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const webAuthnSessionCookie = "webauthn_session"
+
+func setWebAuthnSession(c *gin.Context, config config.Config, sessionId string) {
+	helpers.SetCookie(c, config, webAuthnSessionCookie, sessionId, 5*60, "/", true)
+}
+
+func takeWebAuthnSession(c *gin.Context, config config.Config, purposes ...string) (*models.WebAuthnSession, bool) {
+	sessionId, err := c.Cookie(webAuthnSessionCookie)
+	helpers.ClearCookie(c, config, webAuthnSessionCookie, "/")
+	if err != nil {
+		helpers.Fail(c, apperrors.Wrap(apperrors.InvalidWebAuthnSession, err))
+		return nil, false
+	}
+
+	session, err := helpers.TakeWebAuthnSession(c.Request.Context(), sessionId, purposes...)
+	if err != nil {
+		helpers.Fail(c, apperrors.Wrap(apperrors.InvalidWebAuthnSession, err))
+		return nil, false
+	}
+
+	return session, true
+}
+
+// beginSecondFactor starts an assertion ceremony when the user has registered credentials. It reports
+// whether a response has been written, in which case the caller must not issue a session yet.
+func beginSecondFactor(c *gin.Context, config config.Config, user *models.DBResponse) (bool, error) {
+	webAuthnUser, err := helpers.FindWebAuthnUser(c.Request.Context(), user)
+	if err != nil {
+		return false, err
+	}
+	if len(webAuthnUser.Credentials) == 0 {
+		return false, nil
+	}
+
+	web, err := helpers.NewWebAuthn(config)
+	if err != nil {
+		return false, err
+	}
+
+	assertion, session, err := web.BeginLogin(webAuthnUser)
+	if err != nil {
+		return false, err
+	}
+
+	sessionId, err := helpers.SaveWebAuthnSession(c.Request.Context(), user.Id, helpers.WebAuthnSecondFactor, session)
+	if err != nil {
+		return false, err
+	}
+
+	setWebAuthnSession(c, config, sessionId)
+	c.JSON(http.StatusOK, gin.H{"status": "mfa_required", "data": assertion})
+	return true, nil
+}
+
+func BeginWebAuthnRegistration(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		web, err := helpers.NewWebAuthn(config)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		webAuthnUser, err := helpers.FindWebAuthnUser(c.Request.Context(), currentUser)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		exclusions := make([]protocol.CredentialDescriptor, 0, len(webAuthnUser.Credentials))
+		for _, credential := range webAuthnUser.WebAuthnCredentials() {
+			exclusions = append(exclusions, credential.Descriptor())
+		}
+
+		// a resident key lets the credential also be used for discoverable (passkey) login
+		creation, session, err := web.BeginRegistration(webAuthnUser,
+			webauthn.WithExclusions(exclusions),
+			webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+		)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		sessionId, err := helpers.SaveWebAuthnSession(c.Request.Context(), currentUser.Id, helpers.WebAuthnRegistration, session)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		setWebAuthnSession(c, config, sessionId)
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": creation}})
+	}
+}
+
+func FinishWebAuthnRegistration(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		session, ok := takeWebAuthnSession(c, config, helpers.WebAuthnRegistration)
+		if !ok {
+			return
+		}
+		if session.UserId != currentUser.Id {
+			helpers.Fail(c, apperrors.New(apperrors.InvalidWebAuthnSession))
+			return
+		}
+
+		web, err := helpers.NewWebAuthn(config)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		webAuthnUser, err := helpers.FindWebAuthnUser(c.Request.Context(), currentUser)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		credential, err := web.FinishRegistration(webAuthnUser, session.Data, c.Request)
+		if err != nil {
+			helpers.Fail(c, apperrors.Wrap(apperrors.SecurityKeyRejected, err))
+			return
+		}
+
+		name := c.DefaultQuery("name", "Security key")
+		stored, err := helpers.SaveWebAuthnCredential(c.Request.Context(), currentUser.Id, name, credential)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.RequestResponse{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": stored}})
+	}
+}
+
+func ListWebAuthnCredentials() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		webAuthnUser, err := helpers.FindWebAuthnUser(c.Request.Context(), currentUser)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": webAuthnUser.Credentials}})
+	}
+}
+
+func DeleteWebAuthnCredential() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("currentUser").(*models.DBResponse)
+
+		deleted, err := helpers.DeleteWebAuthnCredential(c.Request.Context(), currentUser.Id, c.Param("credentialId"))
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+		if !deleted {
+			helpers.Fail(c, apperrors.New(apperrors.CredentialNotFound))
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "Credential successfully deleted!"}})
+	}
+}
+
+// BeginWebAuthnLogin starts a passwordless login, scoped to the user's credentials when an email is
+// given and as a discoverable (passkey) login otherwise.
+func BeginWebAuthnLogin(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var login models.WebAuthnLogin
+
+		if err := c.ShouldBindJSON(&login); err != nil && c.Request.ContentLength > 0 {
+			helpers.Fail(c, apperrors.Binding(err))
+			return
+		}
+
+		web, err := helpers.NewWebAuthn(config)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		var assertion interface{}
+		var session *webauthn.SessionData
+		userId := ""
+
+		if login.Email != "" {
+			user, err := helpers.FindUserByEmail(c.Request.Context(), helpers.TenantId(c), login.Email)
+			if err != nil {
+				helpers.Fail(c, apperrors.Wrap(apperrors.UnknownEmail, err))
+				return
+			}
+
+			webAuthnUser, err := helpers.FindWebAuthnUser(c.Request.Context(), user)
+			if err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+			if len(webAuthnUser.Credentials) == 0 {
+				helpers.Fail(c, apperrors.New(apperrors.NoSecurityKey))
+				return
+			}
+
+			assertion, session, err = web.BeginLogin(webAuthnUser)
+			if err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+			userId = user.Id
+		} else {
+			assertion, session, err = web.BeginDiscoverableLogin()
+			if err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+		}
+
+		sessionId, err := helpers.SaveWebAuthnSession(c.Request.Context(), userId, helpers.WebAuthnLogin, session)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		setWebAuthnSession(c, config, sessionId)
+		c.JSON(http.StatusOK, models.RequestResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": assertion}})
+	}
+}
+
+// FinishWebAuthnLogin completes either a passwordless login or the second factor started by AuthUser,
+// and issues the session cookies.
+func FinishWebAuthnLogin(config config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := takeWebAuthnSession(c, config, helpers.WebAuthnLogin, helpers.WebAuthnSecondFactor)
+		if !ok {
+			return
+		}
+
+		web, err := helpers.NewWebAuthn(config)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		var webAuthnUser *helpers.WebAuthnUser
+		var credential *webauthn.Credential
+
+		if session.UserId != "" {
+			user, err := helpers.FindUserById(c.Request.Context(), helpers.TenantId(c), session.UserId)
+			if err != nil {
+				helpers.Fail(c, apperrors.Wrap(apperrors.SessionUserNotFound, err))
+				return
+			}
+
+			webAuthnUser, err = helpers.FindWebAuthnUser(c.Request.Context(), user)
+			if err != nil {
+				helpers.Fail(c, err)
+				return
+			}
+
+			credential, err = web.FinishLogin(webAuthnUser, session.Data, c.Request)
+			if err != nil {
+				helpers.Fail(c, apperrors.Wrap(apperrors.WebAuthnFailed, err))
+				return
+			}
+		} else {
+			parsed, err := protocol.ParseCredentialRequestResponse(c.Request)
+			if err != nil {
+				helpers.Fail(c, apperrors.Binding(err))
+				return
+			}
+
+			credential, err = web.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+				user, err := helpers.FindUserById(c.Request.Context(), helpers.TenantId(c), string(userHandle))
+				if err != nil {
+					return nil, err
+				}
+				webAuthnUser, err = helpers.FindWebAuthnUser(c.Request.Context(), user)
+				return webAuthnUser, err
+			}, session.Data, parsed)
+			if err != nil {
+				helpers.Fail(c, apperrors.Wrap(apperrors.WebAuthnFailed, err))
+				return
+			}
+		}
+
+		if credential.Authenticator.CloneWarning {
+			helpers.Fail(c, apperrors.New(apperrors.ClonedSecurityKey))
+			return
+		}
+
+		if err := helpers.TouchWebAuthnCredential(c.Request.Context(), credential); err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		if webAuthnUser.User.Disabled {
+			helpers.Fail(c, apperrors.New(apperrors.AccountDisabled))
+			return
+		}
+
+		access_token, err := issueSession(c, config, webAuthnUser.User)
+		if err != nil {
+			helpers.Fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+	}
+}
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/RadenAbror/UserManagement/app/helpers/webauthntest"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+const testOrigin = "https://id.example.com"
+
+// webAuthnConfig is a configuration able to sign sessions and run ceremonies for testOrigin.
+func webAuthnConfig(t *testing.T) config.Config {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(kind string, der []byte) string {
+		return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}))
+	}
+	privateKey, publicKey := encode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), encode("PUBLIC KEY", public)
+
+	return config.Config{
+		AccessTokenPrivateKey:  privateKey,
+		AccessTokenPublicKey:   publicKey,
+		AccessTokenExpiresIn:   15 * time.Minute,
+		AccessTokenMaxAge:      15,
+		RefreshTokenPrivateKey: privateKey,
+		RefreshTokenPublicKey:  publicKey,
+		RefreshTokenExpiresIn:  time.Hour,
+		RefreshTokenMaxAge:     60,
+		WebAuthnRPID:           "id.example.com",
+		WebAuthnRPName:         "Example",
+		WebAuthnRPOrigins:      testOrigin,
+	}
+}
+
+// serve sends one request through handlers, in the organization "acme".
+func serve(appConfig config.Config, request *http.Request, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(helpers.UseConfig(appConfig), func(c *gin.Context) {
+		c.Set("tenant", &models.Organization{ID: "acme"})
+	})
+	router.Handle(request.Method, request.URL.Path, handlers...)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func jsonRequest(t *testing.T, method string, path string, body interface{}) *http.Request {
+	raw, ok := body.([]byte)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	request := httptest.NewRequest(method, path, bytes.NewReader(raw))
+	request.Header.Set("Content-Type", "application/json")
+	return request
+}
+
+func decodeResponse(t *testing.T, recorder *httptest.ResponseRecorder) map[string]interface{} {
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response %q is not JSON: %v", recorder.Body.String(), err)
+	}
+	return body
+}
+
+// errorCode returns the code of an error written by helpers.Fail.
+func errorCode(t *testing.T, recorder *httptest.ResponseRecorder) apperrors.Code {
+	data, _ := decodeResponse(t, recorder)["data"].(map[string]interface{})
+	code, _ := data["code"].(string)
+	return apperrors.Code(code)
+}
+
+func responseCookie(recorder *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == name && cookie.MaxAge >= 0 {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// document converts a model to the form the mocked deployment answers with.
+func document(t *testing.T, model interface{}) bson.D {
+	raw, err := bson.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func found(collection string, docs ...bson.D) bson.D {
+	return mtest.CreateCursorResponse(0, "user_management."+collection, mtest.FirstBatch, docs...)
+}
+
+func takenSession(doc bson.D) bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: doc}}
+}
+
+// registeredCredential registers the authenticator for user the way FinishWebAuthnRegistration does.
+func registeredCredential(t *testing.T, appConfig config.Config, authenticator *webauthntest.Authenticator, user *models.DBResponse) models.WebAuthnCredential {
+	web, err := helpers.NewWebAuthn(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webAuthnUser := &helpers.WebAuthnUser{User: user}
+	creation, session, err := web.BeginRegistration(webAuthnUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(authenticator.Create(t, creation)))
+	credential, err := web.FinishRegistration(webAuthnUser, *session, request)
+	if err != nil {
+		t.Fatalf("FinishRegistration() failed: %v", err)
+	}
+	return models.WebAuthnCredential{ID: "credential-1", UserId: user.Id, Name: "Security key", CredentialId: helpers.EncodeCredentialId(credential.ID), Credential: *credential}
+}
+
+func passwordUser(t *testing.T, level string) *models.DBResponse {
+	hashed, err := config.HashPassword("Correct-Horse-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.DBResponse{Id: "user-1", TenantId: "acme", Email: "ana@example.com", Name: "Ana", Password: hashed, Level: level, CreatedAt: time.Now()}
+}
+
+func TestAuthUserRequiresSecondFactor(t *testing.T) {
+	appConfig := webAuthnConfig(t)
+	user := passwordUser(t, "member")
+	credential := registeredCredential(t, appConfig, webauthntest.New(t, appConfig.WebAuthnRPID, testOrigin), user)
+
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		mt.AddMockResponses(
+			found(config.UsersCollection, document(t, user)),
+			found(config.WebAuthnCredentialsCollection, document(t, credential)),
+			mtest.CreateSuccessResponse(),
+		)
+
+		recorder := serve(appConfig, jsonRequest(t, http.MethodPost, "/user/auth", models.UserAuth{Email: user.Email, Password: "Correct-Horse-9"}), AuthUser(appConfig))
+		body := decodeResponse(t, recorder)
+		if recorder.Code != http.StatusOK || body["status"] != "mfa_required" {
+			t.Fatalf("AuthUser() = %d %v, want the second factor requested", recorder.Code, body)
+		}
+		if _, issued := body["access_token"]; issued || responseCookie(recorder, "access_token") != nil {
+			t.Error("AuthUser() issued a session before the second factor")
+		}
+		if responseCookie(recorder, webAuthnSessionCookie) == nil {
+			t.Error("AuthUser() did not set the ceremony cookie")
+		}
+
+		var purpose string
+		for _, started := range mt.GetAllStartedEvents() {
+			if started.CommandName == "insert" {
+				purpose, _ = started.Command.Lookup("documents", "0", "purpose").StringValueOK()
+			}
+		}
+		if purpose != helpers.WebAuthnSecondFactor {
+			t.Errorf("ceremony session purpose = %q, want %q", purpose, helpers.WebAuthnSecondFactor)
+		}
+	})
+}
+
+func TestAuthUserRequiresSecurityKeyRegistration(t *testing.T) {
+	appConfig := webAuthnConfig(t)
+	appConfig.WebAuthnRequiredLevels = "admin, auditor"
+	user := passwordUser(t, "admin")
+
+	var accessToken string
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		mt.AddMockResponses(found(config.UsersCollection, document(t, user)), found(config.WebAuthnCredentialsCollection))
+
+		recorder := serve(appConfig, jsonRequest(t, http.MethodPost, "/user/auth", models.UserAuth{Email: user.Email, Password: "Correct-Horse-9"}), AuthUser(appConfig))
+		body := decodeResponse(t, recorder)
+		if recorder.Code != http.StatusOK || body["status"] != "webauthn_registration_required" {
+			t.Fatalf("AuthUser() = %d %v, want only a registration session", recorder.Code, body)
+		}
+		if responseCookie(recorder, "refresh_token") != nil {
+			t.Error("AuthUser() issued a refresh token with the registration session")
+		}
+		accessToken, _ = body["access_token"].(string)
+	})
+
+	authorized := func(authorize gin.HandlerFunc) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/user/me", nil)
+		request.Header.Set("Authorization", "Bearer "+accessToken)
+		return serve(appConfig, request, authorize, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	}
+
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		mt.AddMockResponses(found(config.RevokedTokensCollection))
+
+		if recorder := authorized(helpers.AuthorizationUser(appConfig)); errorCode(t, recorder) != apperrors.SecurityKeyRequired {
+			t.Errorf("AuthorizationUser() = %d %s, want the registration session refused", recorder.Code, recorder.Body)
+		}
+	})
+
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		mt.AddMockResponses(found(config.RevokedTokensCollection), found(config.UsersCollection, document(t, user)), found(config.GroupMembershipsCollection))
+
+		if recorder := authorized(helpers.AuthorizationRegistration(appConfig)); recorder.Code != http.StatusNoContent {
+			t.Errorf("AuthorizationRegistration() = %d %s, want the registration session accepted", recorder.Code, recorder.Body)
+		}
+	})
+}
+
+func TestFinishWebAuthnLoginChecksSession(t *testing.T) {
+	appConfig := webAuthnConfig(t)
+
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		recorder := serve(appConfig, jsonRequest(t, http.MethodPost, "/webauthn/login/finish", []byte("{}")), FinishWebAuthnLogin(appConfig))
+		if code := errorCode(t, recorder); code != apperrors.InvalidWebAuthnSession {
+			t.Errorf("FinishWebAuthnLogin() without a ceremony cookie = %s, want %s", code, apperrors.InvalidWebAuthnSession)
+		}
+	})
+
+	withMockDB(t, appConfig, func(mt *mtest.T) {
+		// a registration session, an expired one or one already answered is not found
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		request := jsonRequest(t, http.MethodPost, "/webauthn/login/finish", []byte("{}"))
+		request.AddCookie(&http.Cookie{Name: webAuthnSessionCookie, Value: "session-1"})
+		recorder := serve(appConfig, request, FinishWebAuthnLogin(appConfig))
+		if code := errorCode(t, recorder); code != apperrors.InvalidWebAuthnSession {
+			t.Errorf("FinishWebAuthnLogin() without a stored session = %s, want %s", code, apperrors.InvalidWebAuthnSession)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "findAndModify" {
+			t.Fatalf("command = %+v, want the session taken", started)
+		}
+		var purposes []string
+		if err := started.Command.Lookup("query", "purpose", "$in").Unmarshal(&purposes); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{helpers.WebAuthnLogin, helpers.WebAuthnSecondFactor}; !reflect.DeepEqual(purposes, want) {
+			t.Errorf("session purposes = %v, want %v", purposes, want)
+		}
+	})
+}
+
+func TestFinishWebAuthnLoginChecksAccount(t *testing.T) {
+	appConfig := webAuthnConfig(t)
+	web, err := helpers.NewWebAuthn(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, disabled := range []bool{false, true} {
+		user := passwordUser(t, "member")
+		user.Disabled = disabled
+		authenticator := webauthntest.New(t, appConfig.WebAuthnRPID, testOrigin)
+		credential := registeredCredential(t, appConfig, authenticator, user)
+
+		assertion, data, err := web.BeginLogin(&helpers.WebAuthnUser{User: user, Credentials: []models.WebAuthnCredential{credential}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		session := models.WebAuthnSession{ID: "session-1", UserId: user.Id, Purpose: helpers.WebAuthnSecondFactor, Data: *data, ExpiresAt: time.Now().Add(time.Minute)}
+
+		withMockDB(t, appConfig, func(mt *mtest.T) {
+			mt.AddMockResponses(
+				takenSession(document(t, session)),
+				found(config.UsersCollection, document(t, user)),
+				found(config.WebAuthnCredentialsCollection, document(t, credential)),
+				bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			)
+
+			request := jsonRequest(t, http.MethodPost, "/webauthn/login/finish", authenticator.Get(t, assertion))
+			request.AddCookie(&http.Cookie{Name: webAuthnSessionCookie, Value: session.ID})
+			recorder := serve(appConfig, request, FinishWebAuthnLogin(appConfig))
+
+			if disabled {
+				if code := errorCode(t, recorder); code != apperrors.AccountDisabled {
+					t.Errorf("FinishWebAuthnLogin() for a disabled account = %d %s, want %s", recorder.Code, recorder.Body, apperrors.AccountDisabled)
+				}
+				if responseCookie(recorder, "access_token") != nil || responseCookie(recorder, "refresh_token") != nil {
+					t.Error("FinishWebAuthnLogin() issued a session to a disabled account")
+				}
+				return
+			}
+			if recorder.Code != http.StatusOK || responseCookie(recorder, "refresh_token") == nil {
+				t.Errorf("FinishWebAuthnLogin() = %d %s, want a session", recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
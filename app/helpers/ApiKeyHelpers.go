@@ -0,0 +1,215 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// API keys look like umk_<prefix>_<secret>. The prefix is stored in clear to find the key,
+// the secret only as a SHA-256 hash.
+const apiKeyPrefix = "umk_"
+
+var ErrInvalidApiKey = errors.New("invalid api key")
+
+func hashApiKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func CreateApiKey(ctx context.Context, tenantId string, userId string, request models.ApiKeyCreate) (*models.ApiKeyCreated, error) {
+	var apiKeyCollection *mongo.Collection = config.GetCollection(config.DB, config.ApiKeysCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// hex keeps the prefix free of the "_" separator
+	prefixBytes := make([]byte, 6)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+
+	secret, err := RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := models.ApiKey{
+		ID:        uuid.Must(uuid.NewRandom()).String(),
+		UserId:    userId,
+		TenantId:  tenantId,
+		Name:      request.Name,
+		Prefix:    prefix,
+		Hash:      hashApiKeySecret(secret),
+		Scopes:    request.Scopes,
+		CreatedAt: time.Now(),
+	}
+	days := request.ExpiresInDays
+	if days <= 0 {
+		days = models.ApiKeyDefaultExpiryDays
+	}
+	expiresAt := apiKey.CreatedAt.AddDate(0, 0, days)
+	apiKey.ExpiresAt = &expiresAt
+
+	if _, err := apiKeyCollection.InsertOne(ctx, apiKey); err != nil {
+		return nil, err
+	}
+
+	return &models.ApiKeyCreated{ApiKey: apiKey, Key: apiKeyPrefix + prefix + "_" + secret}, nil
+}
+
+func FindApiKeys(ctx context.Context, userId string) ([]models.ApiKey, error) {
+	var apiKeyCollection *mongo.Collection = config.GetCollection(config.DB, config.ApiKeysCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	results, err := apiKeyCollection.Find(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	apiKeys := []models.ApiKey{}
+	if err := results.All(ctx, &apiKeys); err != nil {
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+func DeleteApiKey(ctx context.Context, userId string, keyId string) (bool, error) {
+	var apiKeyCollection *mongo.Collection = config.GetCollection(config.DB, config.ApiKeysCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := apiKeyCollection.DeleteOne(ctx, bson.M{"id": keyId, "user_id": userId})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ValidateApiKey checks a presented key against its stored hash and expiry and records its use.
+func ValidateApiKey(ctx context.Context, key string) (*models.ApiKey, error) {
+	var apiKeyCollection *mongo.Collection = config.GetCollection(config.DB, config.ApiKeysCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	prefix, secret, found := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), "_")
+	if !strings.HasPrefix(key, apiKeyPrefix) || !found {
+		return nil, ErrInvalidApiKey
+	}
+
+	var apiKey models.ApiKey
+	if err := apiKeyCollection.FindOne(ctx, bson.M{"prefix": prefix}).Decode(&apiKey); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvalidApiKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.Hash), []byte(hashApiKeySecret(secret))) != 1 {
+		return nil, ErrInvalidApiKey
+	}
+
+	now := time.Now()
+	// keys from before every key had an expiry are given one by a migration
+	if apiKey.ExpiresAt == nil || apiKey.ExpiresAt.Before(now) {
+		return nil, ErrInvalidApiKey
+	}
+
+	if _, err := apiKeyCollection.UpdateOne(ctx, bson.M{"id": apiKey.ID}, bson.M{"$set": bson.M{"last_used_at": now}}); err != nil {
+		return nil, err
+	}
+	apiKey.LastUsedAt = &now
+
+	return &apiKey, nil
+}
+
+// apiKeyFromRequest reads a key from the X-API-Key header or an "ApiKey" authorization scheme.
+func apiKeyFromRequest(ctx *gin.Context) string {
+	if key := ctx.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+
+	fields := strings.Fields(ctx.Request.Header.Get("Authorization"))
+	if len(fields) == 2 && fields[0] == "ApiKey" {
+		return fields[1]
+	}
+
+	return ""
+}
+
+// apiKeyAllows maps the request method to the scope it needs; write implies read.
+func apiKeyAllows(apiKey *models.ApiKey, method string) bool {
+	needed := models.ApiKeyScopeWrite
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+		needed = models.ApiKeyScopeRead
+	}
+
+	for _, scope := range apiKey.Scopes {
+		if scope == needed || scope == models.ApiKeyScopeWrite {
+			return true
+		}
+	}
+	return false
+}
+
+func authorizeApiKey(ctx *gin.Context, key string) {
+	apiKey, err := ValidateApiKey(ctx.Request.Context(), key)
+	if err != nil {
+		Fail(ctx, apperrors.Wrap(apperrors.InvalidApiKey, err))
+		return
+	}
+
+	if !apiKeyAllows(apiKey, ctx.Request.Method) {
+		Fail(ctx, apperrors.New(apperrors.ApiKeyScope))
+		return
+	}
+
+	user, err := FindUserById(ctx.Request.Context(), apiKey.TenantId, apiKey.UserId)
+	if err != nil {
+		Fail(ctx, apperrors.Wrap(apperrors.SessionUserNotFound, err))
+		return
+	}
+
+	if user.Disabled {
+		Fail(ctx, apperrors.New(apperrors.AccountDisabled))
+		return
+	}
+
+	if !bindTenant(ctx, user) {
+		return
+	}
+
+	if !setCurrentUser(ctx, user) {
+		return
+	}
+	ctx.Set("currentApiKey", apiKey)
+	ctx.Next()
+}
+
+// DenyApiKey rejects requests authenticated with an API key, for routes that need an interactive session.
+func DenyApiKey() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, exists := ctx.Get("currentApiKey"); exists {
+			Fail(ctx, apperrors.New(apperrors.ApiKeyNotAllowed))
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrUnknownUser means the provider does not manage this account and the next provider should be asked.
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrInvalidCredentials means the provider manages this account but the password is wrong.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrAccountDisabled means the credentials are valid but the account has been deactivated.
+	ErrAccountDisabled = errors.New("account disabled")
+)
+
+// AuthProvider verifies a user's credentials against one backend and returns the matching users document.
+type AuthProvider interface {
+	Name() string
+	Authenticate(ctx context.Context, tenantId string, email string, password string) (*models.DBResponse, error)
+}
+
+// LocalProvider checks the password hash stored in the users collection.
+type LocalProvider struct{}
+
+func (LocalProvider) Name() string {
+	return "local"
+}
+
+func (LocalProvider) Authenticate(ctx context.Context, tenantId string, email string, password string) (*models.DBResponse, error) {
+	user, err := FindUserByEmail(ctx, tenantId, email)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUnknownUser
+		}
+		return nil, err
+	}
+
+	// accounts provisioned by an external provider have no local password
+	if user.Provider != "" && user.Provider != "local" {
+		return nil, ErrUnknownUser
+	}
+
+	if err := config.VerifyPassword(ctx, user.Password, password); err != nil {
+		// older releases hashed the password after running it through bluemonday, so accept that
+		// form once for the hashes they wrote and store the hash of what the user actually typed
+		legacy := legacySanitizedPassword(password)
+		if !user.PasswordLegacySanitized || legacy == password || config.VerifyPassword(ctx, user.Password, legacy) != nil {
+			return nil, ErrInvalidCredentials
+		}
+		if err := replacePasswordHash(ctx, user, password); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// AuthProviders builds the provider chain listed in AUTH_PROVIDERS, defaulting to local only.
+func AuthProviders(config config.Config) []AuthProvider {
+	var providers []AuthProvider
+
+	for _, name := range strings.Split(config.AuthProviders, ",") {
+		switch strings.TrimSpace(name) {
+		case "local":
+			providers = append(providers, LocalProvider{})
+		case "ldap":
+			providers = append(providers, NewLDAPProvider(config))
+		}
+	}
+
+	if len(providers) == 0 {
+		providers = append(providers, LocalProvider{})
+	}
+
+	return providers
+}
+
+// AuthenticateUser asks each provider in turn until one of them recognises the account.
+func AuthenticateUser(ctx context.Context, providers []AuthProvider, tenantId string, email string, password string) (*models.DBResponse, error) {
+	for _, provider := range providers {
+		user, err := provider.Authenticate(ctx, tenantId, email, password)
+		if err == ErrUnknownUser {
+			continue
+		}
+		if err == nil && user.Disabled {
+			return nil, ErrAccountDisabled
+		}
+		return user, err
+	}
+
+	return nil, ErrUnknownUser
+}
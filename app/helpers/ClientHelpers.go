@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseClients reads OAUTH_CLIENTS, a comma separated list of client_id:client_secret pairs.
+func parseClients(raw string) map[string]string {
+	clients := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		id, secret, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" || secret == "" {
+			continue
+		}
+		clients[id] = secret
+	}
+	return clients
+}
+
+// AuthenticateClient authenticates the calling client with HTTP Basic credentials
+// or, failing that, client_id and client_secret form parameters (RFC 6749 section 2.3.1).
+func AuthenticateClient(ctx *gin.Context) (string, bool) {
+	clientId, clientSecret, ok := ctx.Request.BasicAuth()
+	if !ok {
+		clientId = ctx.PostForm("client_id")
+		clientSecret = ctx.PostForm("client_secret")
+	}
+
+	if clientId == "" || clientSecret == "" {
+		return "", false
+	}
+
+	secret, found := parseClients(AppConfig(ctx).OAuthClients)[clientId]
+	if !found || subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) != 1 {
+		return "", false
+	}
+
+	return clientId, true
+}
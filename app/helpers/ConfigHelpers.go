@@ -0,0 +1,20 @@
+package helpers
+
+import (
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/gin-gonic/gin"
+)
+
+// UseConfig hands the configuration the router was built with to every request, for the helpers
+// that only receive the request. It runs before any other middleware.
+func UseConfig(config config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set("config", config)
+		ctx.Next()
+	}
+}
+
+// AppConfig returns the configuration set by UseConfig.
+func AppConfig(ctx *gin.Context) config.Config {
+	return ctx.MustGet("config").(config.Config)
+}
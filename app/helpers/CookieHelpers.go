@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"net/http"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/gin-gonic/gin"
+)
+
+// SetCookie sets a cookie with the domain, Secure flag and SameSite mode from the configuration.
+func SetCookie(ctx *gin.Context, config config.Config, name string, value string, maxAge int, path string, httpOnly bool) {
+	ctx.SetSameSite(config.SameSite())
+	ctx.SetCookie(name, value, maxAge, path, config.CookieDomain, config.CookieSecure, httpOnly)
+}
+
+// SetRedirectCookie is SetCookie for state that must survive a redirect back from another site,
+// such as an OAuth2 callback, which SameSite=Strict cookies would not.
+func SetRedirectCookie(ctx *gin.Context, config config.Config, name string, value string, maxAge int, path string) {
+	sameSite := config.SameSite()
+	if sameSite == http.SameSiteStrictMode {
+		sameSite = http.SameSiteLaxMode
+	}
+
+	ctx.SetSameSite(sameSite)
+	ctx.SetCookie(name, value, maxAge, path, config.CookieDomain, config.CookieSecure, true)
+}
+
+// ClearCookie expires a cookie set by SetCookie or SetRedirectCookie.
+func ClearCookie(ctx *gin.Context, config config.Config, name string, path string) {
+	ctx.SetSameSite(config.SameSite())
+	ctx.SetCookie(name, "", -1, path, config.CookieDomain, config.CookieSecure, true)
+}
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/i18n"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Locale is the language of the response: the locale chosen by the logged-in user, else the
+// best match for Accept-Language, else DEFAULT_LOCALE.
+func Locale(ctx *gin.Context) i18n.Locale {
+	if user, exists := ctx.Get("currentUser"); exists {
+		if locale, found := i18n.Parse(user.(*models.DBResponse).Locale); found {
+			return locale
+		}
+	}
+
+	fallback, found := i18n.Parse(AppConfig(ctx).DefaultLocale)
+	if !found {
+		fallback = i18n.Indonesian
+	}
+	return i18n.Match(ctx.GetHeader("Accept-Language"), fallback)
+}
+
+// Fail aborts the request with err, its message in the locale of the request. Errors that are
+// not an *apperrors.Error become internal_error, so database and library messages never reach
+// the client; the cause is kept in ctx.Errors for the access log.
+func Fail(ctx *gin.Context, err error) {
+	appErr := apperrors.From(err)
+	ctx.Error(appErr)
+
+	locale := Locale(ctx)
+	data := map[string]interface{}{
+		"data":       i18n.Message(locale, appErr.Code),
+		"code":       appErr.Code,
+		"request_id": RequestId(ctx),
+	}
+	if appErr.Details != nil {
+		data["details"] = i18n.Details(locale, appErr)
+	}
+
+	ctx.Header("Content-Language", string(locale))
+	ctx.AbortWithStatusJSON(appErr.Status, models.RequestResponse{Status: appErr.Status, Message: "error", Data: data})
+}
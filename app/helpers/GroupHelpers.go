@@ -0,0 +1,271 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrGroupCycle   = errors.New("a group cannot be moved below itself or one of its descendants")
+	ErrUnknownGroup = errors.New("unknown group")
+)
+
+func FindGroup(ctx context.Context, tenantId string, groupId string) (*models.Group, error) {
+	var groupCollection *mongo.Collection = config.GetCollection(config.DB, config.GroupsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var group *models.Group
+	if err := groupCollection.FindOne(ctx, Scoped(tenantId, bson.M{"id": groupId})).Decode(&group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func findGroups(ctx context.Context, tenantId string, filter bson.M) ([]models.Group, error) {
+	var groupCollection *mongo.Collection = config.GetCollection(config.DB, config.GroupsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	results, err := groupCollection.Find(ctx, Scoped(tenantId, filter))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	groups := []models.Group{}
+	if err := results.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GroupAncestors returns the ancestors of group ordered from the root down to its parent.
+func GroupAncestors(ctx context.Context, group *models.Group) ([]models.Group, error) {
+	if len(group.Ancestors) == 0 {
+		return []models.Group{}, nil
+	}
+
+	found, err := findGroups(ctx, group.TenantId, bson.M{"id": bson.M{"$in": group.Ancestors}})
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]models.Group, len(found))
+	for _, ancestor := range found {
+		byId[ancestor.ID] = ancestor
+	}
+
+	ancestors := make([]models.Group, 0, len(group.Ancestors))
+	for _, id := range group.Ancestors {
+		if ancestor, ok := byId[id]; ok {
+			ancestors = append(ancestors, ancestor)
+		}
+	}
+	return ancestors, nil
+}
+
+// GroupDescendants returns every group below group, at any depth.
+func GroupDescendants(ctx context.Context, group *models.Group) ([]models.Group, error) {
+	return findGroups(ctx, group.TenantId, bson.M{"ancestors": group.ID})
+}
+
+// GroupSubtreeIds returns the id of group followed by the ids of all its descendants.
+func GroupSubtreeIds(ctx context.Context, group *models.Group) ([]string, error) {
+	descendants, err := GroupDescendants(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{group.ID}
+	for _, descendant := range descendants {
+		ids = append(ids, descendant.ID)
+	}
+	return ids, nil
+}
+
+// GroupPath returns the ancestors a group gets when placed below parentId. An empty parentId
+// makes it a root group. With groupId set, placing the group below itself or one of its own
+// descendants fails with ErrGroupCycle.
+func GroupPath(ctx context.Context, tenantId string, groupId string, parentId string) ([]string, error) {
+	if parentId == "" {
+		return []string{}, nil
+	}
+	if parentId == groupId {
+		return nil, ErrGroupCycle
+	}
+
+	parent, err := FindGroup(ctx, tenantId, parentId)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrUnknownGroup
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ancestor := range parent.Ancestors {
+		if groupId != "" && ancestor == groupId {
+			return nil, ErrGroupCycle
+		}
+	}
+
+	return append(append([]string{}, parent.Ancestors...), parent.ID), nil
+}
+
+// MoveGroupDescendants rewrites the ancestors of every group below groupId after the group
+// itself was given the ancestors in path.
+func MoveGroupDescendants(ctx context.Context, tenantId string, groupId string, path []string) error {
+	var groupCollection *mongo.Collection = config.GetCollection(config.DB, config.GroupsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// keep everything from groupId down and put the new path in front of it
+	_, err := groupCollection.UpdateMany(ctx, Scoped(tenantId, bson.M{"ancestors": groupId}), mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"ancestors": bson.M{"$concatArrays": bson.A{
+			path,
+			bson.M{"$slice": bson.A{"$ancestors", bson.M{"$indexOfArray": bson.A{"$ancestors", groupId}}, bson.M{"$size": "$ancestors"}}},
+		}}}}},
+	})
+	return err
+}
+
+// EffectiveGroupAccess merges the managers and permissions of group with those assigned on
+// its ancestors, which apply to the whole subtree below them.
+func EffectiveGroupAccess(ctx context.Context, group *models.Group) (models.GroupAccess, error) {
+	ancestors, err := GroupAncestors(ctx, group)
+	if err != nil {
+		return models.GroupAccess{Managers: []string{}, Permissions: []string{}}, err
+	}
+
+	return mergeGroupAccess(append(ancestors, *group)), nil
+}
+
+// mergeGroupAccess collects the managers and permissions of groups, each once.
+func mergeGroupAccess(groups []models.Group) models.GroupAccess {
+	access := models.GroupAccess{Managers: []string{}, Permissions: []string{}}
+
+	managers, permissions := map[string]bool{}, map[string]bool{}
+	for _, g := range groups {
+		for _, manager := range g.Managers {
+			if !managers[manager] {
+				managers[manager] = true
+				access.Managers = append(access.Managers, manager)
+			}
+		}
+		for _, permission := range g.Permissions {
+			if !permissions[permission] {
+				permissions[permission] = true
+				access.Permissions = append(access.Permissions, permission)
+			}
+		}
+	}
+	return access
+}
+
+// IsGroupManager reports whether user manages group directly or through one of its ancestors,
+// either as one of the group's managers or through a membership with the manager role.
+func IsGroupManager(ctx context.Context, user *models.DBResponse, group *models.Group) (bool, error) {
+	if HasGroupRole(user, append(append([]string{}, group.Ancestors...), group.ID), GroupRoleManager) {
+		return true, nil
+	}
+
+	access, err := EffectiveGroupAccess(ctx, group)
+	if err != nil {
+		return false, err
+	}
+
+	for _, manager := range access.Managers {
+		if manager == user.Id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UserGroupAccess returns what the primary group of user and the groups of their active
+// memberships grant, including what those inherit from the groups above them. Groups that no
+// longer exist grant nothing.
+func UserGroupAccess(ctx context.Context, user *models.DBResponse) (models.GroupAccess, error) {
+	memberships := user.Memberships
+	if memberships == nil {
+		var err error
+		if memberships, err = ActiveMemberships(ctx, user); err != nil {
+			return models.GroupAccess{}, err
+		}
+	}
+
+	groupIds := []string{}
+	if user.Group != "" {
+		groupIds = append(groupIds, user.Group)
+	}
+	for _, membership := range memberships {
+		groupIds = append(groupIds, membership.GroupId)
+	}
+
+	var groups []models.Group
+	seen := map[string]bool{}
+	for _, groupId := range groupIds {
+		if seen[groupId] {
+			continue
+		}
+		seen[groupId] = true
+
+		group, err := FindGroup(ctx, user.TenantId, groupId)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return models.GroupAccess{}, err
+		}
+
+		ancestors, err := GroupAncestors(ctx, group)
+		if err != nil {
+			return models.GroupAccess{}, err
+		}
+		groups = append(append(groups, ancestors...), *group)
+	}
+
+	return mergeGroupAccess(groups), nil
+}
+
+// RequireGroupManager lets through admins of the organization and managers of the group named
+// by the route parameter param, including managers of any group above it. It runs after
+// AuthorizationUser.
+func RequireGroupManager(param string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := ctx.MustGet("currentUser").(*models.DBResponse)
+		if IsTenantAdmin(ctx, user) {
+			ctx.Next()
+			return
+		}
+
+		group, err := FindGroup(ctx.Request.Context(), TenantId(ctx), ctx.Param(param))
+		if err == mongo.ErrNoDocuments {
+			Fail(ctx, apperrors.New(apperrors.GroupNotFound))
+			return
+		}
+		if err != nil {
+			Fail(ctx, err)
+			return
+		}
+
+		manager, err := IsGroupManager(ctx.Request.Context(), user, group)
+		if err != nil {
+			Fail(ctx, err)
+			return
+		}
+		if !manager {
+			Fail(ctx, apperrors.New(apperrors.Forbidden))
+			return
+		}
+		ctx.Next()
+	}
+}
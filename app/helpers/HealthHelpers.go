@@ -0,0 +1,16 @@
+package helpers
+
+import "sync/atomic"
+
+var shuttingDown atomic.Bool
+
+// BeginShutdown makes the readiness probe fail, so load balancers stop sending traffic while
+// in-flight requests drain.
+func BeginShutdown() {
+	shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether BeginShutdown was called.
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
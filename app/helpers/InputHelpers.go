@@ -0,0 +1,30 @@
+package helpers
+
+import (
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Request fields fall into three groups: display text such as names, which is stripped of markup
+// before it is stored; email addresses, which are trimmed and lower-cased; and secrets such as
+// passwords, which are never altered and go straight to config.HashPassword.
+
+var textPolicy = bluemonday.UGCPolicy()
+
+// SanitizeText removes unsafe markup from free text that will be displayed to other users.
+func SanitizeText(value string) string {
+	return textPolicy.Sanitize(strings.TrimSpace(value))
+}
+
+// NormalizeEmail returns the form under which email addresses are stored and looked up.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// legacySanitizedPassword is what older releases hashed instead of the password the user typed.
+// It is only tried against hashes marked password_legacy_sanitized by migration 9, so those users can
+// still log in; see LocalProvider.Authenticate.
+func legacySanitizedPassword(password string) string {
+	return textPolicy.Sanitize(password)
+}
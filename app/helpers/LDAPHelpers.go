@@ -0,0 +1,219 @@
+package helpers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LDAPConn is the subset of *ldap.Conn used by LDAPProvider, so a stub directory can stand in for a real server.
+type LDAPConn interface {
+	Bind(username string, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+type LDAPDialer func(config config.Config) (LDAPConn, error)
+
+// DialLDAP connects to LDAP_URL, upgrading the connection with StartTLS when LDAP_START_TLS is set.
+func DialLDAP(config config.Config) (LDAPConn, error) {
+	conn, err := ldap.DialURL(config.LDAPUrl)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: dial: %w", err)
+	}
+
+	if config.LDAPStartTLS {
+		u, err := url.Parse(config.LDAPUrl)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: parse url: %w", err)
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: start tls: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// LDAPUserStore is where LDAPProvider finds and provisions users, so a stub can stand in for MongoDB.
+type LDAPUserStore interface {
+	FindUserByEmail(ctx context.Context, tenantId string, email string) (*models.DBResponse, error)
+	InsertUser(ctx context.Context, user models.UserSave) error
+	UpdateUser(ctx context.Context, id string, update bson.M) error
+}
+
+type mongoUserStore struct{}
+
+func (mongoUserStore) FindUserByEmail(ctx context.Context, tenantId string, email string) (*models.DBResponse, error) {
+	return FindUserByEmail(ctx, tenantId, email)
+}
+
+func (mongoUserStore) InsertUser(ctx context.Context, user models.UserSave) error {
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+	_, err := userCollection.InsertOne(ctx, user)
+	return err
+}
+
+func (mongoUserStore) UpdateUser(ctx context.Context, id string, update bson.M) error {
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+	_, err := userCollection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": update})
+	return err
+}
+
+// LDAPProvider authenticates against a directory with a search-then-bind and provisions the
+// matching users document on first login.
+type LDAPProvider struct {
+	Config config.Config
+	Dial   LDAPDialer
+	Users  LDAPUserStore
+}
+
+func NewLDAPProvider(config config.Config) *LDAPProvider {
+	return &LDAPProvider{Config: config, Dial: DialLDAP, Users: mongoUserStore{}}
+}
+
+func (p *LDAPProvider) Name() string {
+	return "ldap"
+}
+
+func (p *LDAPProvider) Authenticate(ctx context.Context, tenantId string, email string, password string) (*models.DBResponse, error) {
+	// the directory and its group levels belong to one organization
+	if tenantId != p.Config.LDAPTenantId() {
+		return nil, ErrUnknownUser
+	}
+
+	// an empty password would be an unauthenticated bind, which most servers accept
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := p.Dial(p.Config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.Bind(p.Config.LDAPBindDN, p.Config.LDAPBindPassword); err != nil {
+		return nil, fmt.Errorf("ldap: service bind: %w", err)
+	}
+
+	filter := p.Config.LDAPUserFilter
+	if filter == "" {
+		filter = "(&(objectClass=person)(mail=%s))"
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		p.Config.LDAPBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(filter, ldap.EscapeFilter(email)),
+		[]string{"mail", "cn", "displayName", "memberOf"},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrUnknownUser
+		}
+		return nil, fmt.Errorf("ldap: search: %w", err)
+	}
+
+	if len(result.Entries) == 0 {
+		return nil, ErrUnknownUser
+	}
+	if len(result.Entries) > 1 {
+		return nil, fmt.Errorf("ldap: %d entries match %s", len(result.Entries), email)
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: user bind: %w", err)
+	}
+
+	return p.provision(ctx, tenantId, email, entry)
+}
+
+// levelFor maps the entry's memberOf groups to a level using LDAP_GROUP_LEVELS, a semicolon
+// separated list of groupDN:levelId pairs checked in order.
+func (p *LDAPProvider) levelFor(entry *ldap.Entry) string {
+	memberOf := map[string]bool{}
+	for _, group := range entry.GetAttributeValues("memberOf") {
+		memberOf[strings.ToLower(group)] = true
+	}
+
+	for _, mapping := range strings.Split(p.Config.LDAPGroupLevels, ";") {
+		i := strings.LastIndex(mapping, ":")
+		if i <= 0 {
+			continue
+		}
+		if memberOf[strings.ToLower(strings.TrimSpace(mapping[:i]))] {
+			return strings.TrimSpace(mapping[i+1:])
+		}
+	}
+
+	return p.Config.LDAPDefaultLevel
+}
+
+// provision creates the users document for a directory account on first login and keeps
+// its name and level in sync with the directory afterwards.
+func (p *LDAPProvider) provision(ctx context.Context, tenantId string, email string, entry *ldap.Entry) (*models.DBResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	name := SanitizeText(entry.GetAttributeValue("displayName"))
+	if name == "" {
+		name = SanitizeText(entry.GetAttributeValue("cn"))
+	}
+	level := p.levelFor(entry)
+
+	user, err := p.Users.FindUserByEmail(ctx, tenantId, email)
+	if err == mongo.ErrNoDocuments {
+		newUser := models.UserSave{
+			Id:        uuid.Must(uuid.NewRandom()).String(),
+			TenantId:  tenantId,
+			Name:      name,
+			Email:     strings.ToLower(email),
+			Level:     level,
+			Group:     p.Config.LDAPDefaultGroup,
+			Provider:  p.Name(),
+			CreatedAt: time.Now(),
+		}
+		newUser.UpdatedAt = newUser.CreatedAt
+
+		if err := p.Users.InsertUser(ctx, newUser); err != nil {
+			return nil, fmt.Errorf("ldap: provision user: %w", err)
+		}
+		return p.Users.FindUserByEmail(ctx, tenantId, email)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Provider != p.Name() {
+		return nil, ErrUnknownUser
+	}
+
+	if user.Name != name || user.Level != level {
+		update := bson.M{"name": name, "level": level, "updated_at": time.Now()}
+		if err := p.Users.UpdateUser(ctx, user.Id, update); err != nil {
+			return nil, fmt.Errorf("ldap: sync user: %w", err)
+		}
+		user.Name = name
+		user.Level = level
+	}
+
+	return user, nil
+}
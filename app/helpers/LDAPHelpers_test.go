@@ -0,0 +1,316 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/go-ldap/ldap/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// stubDirectory is an in-memory LDAPConn holding entries and their passwords.
+type stubDirectory struct {
+	passwords map[string]string
+	entries   []*ldap.Entry
+	searchErr error
+
+	binds   []string
+	filters []string
+	closed  bool
+}
+
+func (d *stubDirectory) Bind(username string, password string) error {
+	d.binds = append(d.binds, username)
+	if want, found := d.passwords[username]; !found || want != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	return nil
+}
+
+func (d *stubDirectory) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	d.filters = append(d.filters, request.Filter)
+	if d.searchErr != nil {
+		return nil, d.searchErr
+	}
+	return &ldap.SearchResult{Entries: d.entries}, nil
+}
+
+func (d *stubDirectory) Close() {
+	d.closed = true
+}
+
+func newStubDirectory() *stubDirectory {
+	return &stubDirectory{
+		passwords: map[string]string{
+			"cn=service,dc=example,dc=com": "service-secret",
+			"uid=ana,dc=example,dc=com":    "ana-secret",
+		},
+		entries: []*ldap.Entry{
+			ldap.NewEntry("uid=ana,dc=example,dc=com", map[string][]string{"mail": {"ana@example.com"}}),
+		},
+	}
+}
+
+func stubLDAPProvider(directory *stubDirectory) *LDAPProvider {
+	return &LDAPProvider{
+		Config: config.Config{
+			LDAPBindDN:       "cn=service,dc=example,dc=com",
+			LDAPBindPassword: "service-secret",
+			LDAPBaseDN:       "dc=example,dc=com",
+			LDAPTenant:       "tenant",
+		},
+		Dial: func(config.Config) (LDAPConn, error) {
+			return directory, nil
+		},
+		Users: &stubUserStore{},
+	}
+}
+
+// stubUserStore keeps users in memory and counts the writes made to them.
+type stubUserStore struct {
+	users   []models.DBResponse
+	inserts int
+	updates int
+}
+
+func (s *stubUserStore) FindUserByEmail(ctx context.Context, tenantId string, email string) (*models.DBResponse, error) {
+	for i := range s.users {
+		if s.users[i].TenantId == tenantId && s.users[i].Email == strings.ToLower(email) {
+			user := s.users[i]
+			return &user, nil
+		}
+	}
+	return &models.DBResponse{}, mongo.ErrNoDocuments
+}
+
+func (s *stubUserStore) InsertUser(ctx context.Context, user models.UserSave) error {
+	s.inserts++
+	s.users = append(s.users, models.DBResponse{
+		Id: user.Id, TenantId: user.TenantId, Name: user.Name, Email: user.Email,
+		Level: user.Level, Group: user.Group, Provider: user.Provider,
+	})
+	return nil
+}
+
+func (s *stubUserStore) UpdateUser(ctx context.Context, id string, update bson.M) error {
+	s.updates++
+	for i := range s.users {
+		if s.users[i].Id == id {
+			s.users[i].Name, _ = update["name"].(string)
+			s.users[i].Level, _ = update["level"].(string)
+		}
+	}
+	return nil
+}
+
+func TestLDAPProviderRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(p *LDAPProvider, d *stubDirectory)
+		email    string
+		password string
+		want     error
+	}{
+		{"empty password", nil, "ana@example.com", "", ErrInvalidCredentials},
+		{"wrong password", nil, "ana@example.com", "wrong", ErrInvalidCredentials},
+		{"unknown user", func(p *LDAPProvider, d *stubDirectory) { d.entries = nil }, "bob@example.com", "x", ErrUnknownUser},
+		{"missing base", func(p *LDAPProvider, d *stubDirectory) {
+			d.searchErr = ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+		}, "ana@example.com", "ana-secret", ErrUnknownUser},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directory := newStubDirectory()
+			provider := stubLDAPProvider(directory)
+			if test.setup != nil {
+				test.setup(provider, directory)
+			}
+
+			user, err := provider.Authenticate(context.Background(), "tenant", test.email, test.password)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("Authenticate() = %v, %v, want %v", user, err, test.want)
+			}
+			if test.password != "" && !directory.closed {
+				t.Error("connection was not closed")
+			}
+		})
+	}
+}
+
+func TestLDAPProviderErrors(t *testing.T) {
+	t.Run("service bind", func(t *testing.T) {
+		directory := newStubDirectory()
+		provider := stubLDAPProvider(directory)
+		provider.Config.LDAPBindPassword = "wrong"
+
+		_, err := provider.Authenticate(context.Background(), "tenant", "ana@example.com", "ana-secret")
+		if err == nil || errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate() = %v, a broken service account must not look like a wrong password", err)
+		}
+	})
+
+	t.Run("ambiguous entries", func(t *testing.T) {
+		directory := newStubDirectory()
+		directory.entries = append(directory.entries, ldap.NewEntry("uid=ana2,dc=example,dc=com", nil))
+
+		_, err := stubLDAPProvider(directory).Authenticate(context.Background(), "tenant", "ana@example.com", "ana-secret")
+		if err == nil || errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrUnknownUser) {
+			t.Errorf("Authenticate() = %v, want an ambiguity error", err)
+		}
+		if len(directory.binds) != 1 {
+			t.Errorf("binds = %v, no user bind may happen for an ambiguous search", directory.binds)
+		}
+	})
+
+	t.Run("dial", func(t *testing.T) {
+		provider := stubLDAPProvider(nil)
+		provider.Dial = func(config.Config) (LDAPConn, error) { return nil, errors.New("connection refused") }
+
+		if _, err := provider.Authenticate(context.Background(), "tenant", "ana@example.com", "ana-secret"); err == nil {
+			t.Error("Authenticate() succeeded without a directory")
+		}
+	})
+}
+
+func TestLDAPProviderSearch(t *testing.T) {
+	directory := newStubDirectory()
+	provider := stubLDAPProvider(directory)
+
+	if _, err := provider.Authenticate(context.Background(), "tenant", "*)(uid=*", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate() = %v", err)
+	}
+
+	if want := `(&(objectClass=person)(mail=\2a\29\28uid=\2a))`; len(directory.filters) != 1 || directory.filters[0] != want {
+		t.Errorf("filters = %v, want the email escaped as %s", directory.filters, want)
+	}
+	if want := []string{"cn=service,dc=example,dc=com", "uid=ana,dc=example,dc=com"}; len(directory.binds) != 2 || directory.binds[0] != want[0] || directory.binds[1] != want[1] {
+		t.Errorf("binds = %v, want the service account and then %v", directory.binds, want[1])
+	}
+}
+
+func TestLDAPProviderLevelFor(t *testing.T) {
+	provider := &LDAPProvider{Config: config.Config{
+		LDAPGroupLevels:  "cn=admins,ou=groups,dc=example,dc=com:admin; cn=staff,ou=groups,dc=example,dc=com:staff",
+		LDAPDefaultLevel: "member",
+	}}
+
+	tests := []struct {
+		name     string
+		memberOf []string
+		want     string
+	}{
+		{"no groups", nil, "member"},
+		{"unmapped group", []string{"cn=other,ou=groups,dc=example,dc=com"}, "member"},
+		{"mapped group", []string{"cn=staff,ou=groups,dc=example,dc=com"}, "staff"},
+		{"group names ignore case", []string{"CN=Staff,OU=Groups,DC=example,DC=com"}, "staff"},
+		{"first mapping wins", []string{"cn=staff,ou=groups,dc=example,dc=com", "cn=admins,ou=groups,dc=example,dc=com"}, "admin"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := ldap.NewEntry("uid=ana,dc=example,dc=com", map[string][]string{"memberOf": test.memberOf})
+			if got := provider.levelFor(entry); got != test.want {
+				t.Errorf("levelFor(%v) = %q, want %q", test.memberOf, got, test.want)
+			}
+		})
+	}
+}
+
+// directoryAna returns a directory holding ana as a member of the staff group.
+func directoryAna(displayName string) *stubDirectory {
+	directory := newStubDirectory()
+	directory.entries = []*ldap.Entry{ldap.NewEntry("uid=ana,dc=example,dc=com", map[string][]string{
+		"mail":        {"ana@example.com"},
+		"cn":          {"ana"},
+		"displayName": {displayName},
+		"memberOf":    {"cn=staff,ou=groups,dc=example,dc=com"},
+	})}
+	return directory
+}
+
+func staffLDAPProvider(directory *stubDirectory, users *stubUserStore) *LDAPProvider {
+	provider := stubLDAPProvider(directory)
+	provider.Config.LDAPGroupLevels = "cn=staff,ou=groups,dc=example,dc=com:staff"
+	provider.Config.LDAPDefaultLevel = "member"
+	provider.Config.LDAPDefaultGroup = "employees"
+	provider.Users = users
+	return provider
+}
+
+func TestLDAPProviderProvisionsOnFirstLogin(t *testing.T) {
+	users := &stubUserStore{}
+	provider := staffLDAPProvider(directoryAna(`Ana <img src=x onerror="alert(1)">Lima`), users)
+
+	user, err := provider.Authenticate(context.Background(), "tenant", "Ana@Example.com", "ana-secret")
+	if err != nil {
+		t.Fatalf("Authenticate() failed: %v", err)
+	}
+
+	if users.inserts != 1 || user.Id == "" {
+		t.Fatalf("inserts = %d, user = %+v, want one provisioned user", users.inserts, user)
+	}
+	want := models.DBResponse{Id: user.Id, TenantId: "tenant", Name: `Ana <img src="x">Lima`, Email: "ana@example.com", Level: "staff", Group: "employees", Provider: "ldap"}
+	if !reflect.DeepEqual(*user, want) {
+		t.Errorf("user = %+v, want %+v", *user, want)
+	}
+
+	if _, err := provider.Authenticate(context.Background(), "tenant", "ana@example.com", "ana-secret"); err != nil || users.inserts != 1 || users.updates != 0 {
+		t.Errorf("second login: err = %v, inserts = %d, updates = %d, want the user reused unchanged", err, users.inserts, users.updates)
+	}
+}
+
+func TestLDAPProviderSyncsExistingUser(t *testing.T) {
+	users := &stubUserStore{users: []models.DBResponse{
+		{Id: "user-1", TenantId: "tenant", Name: "Ana", Email: "ana@example.com", Level: "member", Provider: "ldap"},
+	}}
+	provider := staffLDAPProvider(directoryAna("Ana Lima"), users)
+
+	user, err := provider.Authenticate(context.Background(), "tenant", "ana@example.com", "ana-secret")
+	if err != nil {
+		t.Fatalf("Authenticate() failed: %v", err)
+	}
+
+	if user.Id != "user-1" || user.Name != "Ana Lima" || user.Level != "staff" || users.updates != 1 {
+		t.Errorf("user = %+v, updates = %d, want user-1 renamed and moved to staff", user, users.updates)
+	}
+	if stored := users.users[0]; stored.Name != "Ana Lima" || stored.Level != "staff" || users.inserts != 0 {
+		t.Errorf("stored = %+v, inserts = %d, want the existing document updated", stored, users.inserts)
+	}
+}
+
+func TestLDAPProviderLeavesLocalAccountsAlone(t *testing.T) {
+	for _, provider := range []string{"", "local", "google"} {
+		t.Run("provider "+provider, func(t *testing.T) {
+			local := models.DBResponse{Id: "user-1", TenantId: "tenant", Name: "Ana", Email: "ana@example.com", Level: "member", Provider: provider}
+			users := &stubUserStore{users: []models.DBResponse{local}}
+
+			_, err := staffLDAPProvider(directoryAna("Ana Lima"), users).Authenticate(context.Background(), "tenant", "ana@example.com", "ana-secret")
+			if !errors.Is(err, ErrUnknownUser) {
+				t.Errorf("Authenticate() = %v, want ErrUnknownUser", err)
+			}
+			if users.updates != 0 || users.inserts != 0 || !reflect.DeepEqual(users.users[0], local) {
+				t.Errorf("the directory changed an account it does not manage: %+v", users.users[0])
+			}
+		})
+	}
+}
+
+func TestLDAPProviderStaysInItsTenant(t *testing.T) {
+	directory := directoryAna("Ana Lima")
+	users := &stubUserStore{}
+
+	_, err := staffLDAPProvider(directory, users).Authenticate(context.Background(), "other", "ana@example.com", "ana-secret")
+	if !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Authenticate() = %v, want ErrUnknownUser outside LDAP_TENANT", err)
+	}
+	if len(directory.binds) != 0 || users.inserts != 0 {
+		t.Errorf("binds = %v, inserts = %d, the directory must not be asked about another organization", directory.binds, users.inserts)
+	}
+}
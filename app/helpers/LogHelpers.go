@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIdHeader carries the correlation id of a request. Incoming ids are kept so a request
+// can be followed across services; otherwise one is generated.
+const RequestIdHeader = "X-Request-ID"
+
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// logLevel is shared by every logger from NewLogger so LOG_LEVEL can change on config reload.
+var logLevel = new(slog.LevelVar)
+
+// redactedKeys are attribute names whose values never reach the logs.
+var redactedKeys = map[string]bool{
+	"password":             true,
+	"passwordconfirm":      true,
+	"password_confirm":     true,
+	"old_password":         true,
+	"new_password":         true,
+	"new_password_confirm": true,
+	"token":                true,
+	"access_token":         true,
+	"refresh_token":        true,
+	"authorization":        true,
+	"cookie":               true,
+	"set-cookie":           true,
+	"secret":               true,
+	"client_secret":        true,
+	"api_key":              true,
+	"x-api-key":            true,
+}
+
+// redact hides the values of sensitive attributes, whatever group they are nested in.
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, "[REDACTED]")
+	}
+	return attr
+}
+
+// NewLogger writes structured logs to stdout, as JSON unless format is "text".
+func NewLogger(level slog.Level, format string) *slog.Logger {
+	logLevel.Set(level)
+
+	options := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redact}
+	if strings.EqualFold(format, "text") {
+		return slog.New(slog.NewTextHandler(os.Stdout, options))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, options))
+}
+
+// SetLogLevel changes the level of every logger made by NewLogger.
+func SetLogLevel(level slog.Level) {
+	logLevel.Set(level)
+}
+
+// Logger returns the logger of the request, which carries its request id and, once
+// AuthorizationUser has run, the user and tenant.
+func Logger(ctx *gin.Context) *slog.Logger {
+	if logger, ok := ctx.Get("logger"); ok {
+		return logger.(*slog.Logger)
+	}
+	return slog.Default()
+}
+
+// addLogFields adds attributes to the request logger for everything logged after this point.
+func addLogFields(ctx *gin.Context, args ...any) {
+	ctx.Set("logger", Logger(ctx).With(args...))
+}
+
+// RequestId returns the correlation id set by RequestID.
+func RequestId(ctx *gin.Context) string {
+	return ctx.GetString("requestId")
+}
+
+// RequestID assigns every request a correlation id, returns it in the X-Request-ID response
+// header and attaches it, and the trace id when the request is traced, to the request logger.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestId := ctx.GetHeader(RequestIdHeader)
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = uuid.Must(uuid.NewRandom()).String()
+		}
+
+		logger := slog.Default().With("request_id", requestId)
+		if span := trace.SpanContextFromContext(ctx.Request.Context()); span.IsValid() {
+			logger = logger.With("trace_id", span.TraceID().String())
+		}
+
+		ctx.Set("requestId", requestId)
+		ctx.Header(RequestIdHeader, requestId)
+		ctx.Set("logger", logger)
+		ctx.Next()
+	}
+}
+
+// AccessLog logs one line per request. Only the path is logged, query strings may hold OAuth
+// codes or tokens.
+func AccessLog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"method", ctx.Request.Method,
+			"route", ctx.FullPath(),
+			"path", ctx.Request.URL.Path,
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", ctx.Writer.Size(),
+			"client_ip", ctx.ClientIP(),
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, "errors", ctx.Errors.String())
+		}
+
+		Logger(ctx).Log(ctx.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panicking handler into a 500 response instead of a crashed process, and
+// logs the panic with its stack.
+func Recovery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+
+			Logger(ctx).Error("handler panicked", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			Fail(ctx, fmt.Errorf("panic: %v", recovered))
+		}()
+		ctx.Next()
+	}
+}
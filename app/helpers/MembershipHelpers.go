@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Roles a membership can have. Other role names may be used freely, these are the ones the
+// service itself understands.
+const (
+	GroupRoleMember  = "member"
+	GroupRoleManager = "manager"
+)
+
+// activeAt matches memberships in force at t.
+func activeAt(t time.Time) bson.M {
+	return bson.M{"$and": bson.A{
+		bson.M{"$or": bson.A{bson.M{"valid_from": bson.M{"$exists": false}}, bson.M{"valid_from": bson.M{"$lte": t}}}},
+		bson.M{"$or": bson.A{bson.M{"valid_until": bson.M{"$exists": false}}, bson.M{"valid_until": bson.M{"$gt": t}}}},
+	}}
+}
+
+func FindMemberships(ctx context.Context, tenantId string, filter bson.M) ([]models.GroupMembership, error) {
+	var membershipCollection *mongo.Collection = config.GetCollection(config.DB, config.GroupMembershipsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	results, err := membershipCollection.Find(ctx, Scoped(tenantId, filter))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	memberships := []models.GroupMembership{}
+	if err := results.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// ActiveMemberships returns the memberships of user that are in force now.
+func ActiveMemberships(ctx context.Context, user *models.DBResponse) ([]models.GroupMembership, error) {
+	filter := activeAt(time.Now())
+	filter["user_id"] = user.Id
+	return FindMemberships(ctx, user.TenantId, filter)
+}
+
+// ActiveGroupMemberships returns the memberships in force now in any of groupIds.
+func ActiveGroupMemberships(ctx context.Context, tenantId string, groupIds []string) ([]models.GroupMembership, error) {
+	filter := activeAt(time.Now())
+	filter["group_id"] = bson.M{"$in": groupIds}
+	return FindMemberships(ctx, tenantId, filter)
+}
+
+// SaveMembership adds userId to the group, or replaces the role and validity of an existing
+// membership.
+func SaveMembership(ctx context.Context, tenantId string, groupId string, request models.GroupMembershipSave) (*models.GroupMembership, error) {
+	var membershipCollection *mongo.Collection = config.GetCollection(config.DB, config.GroupMembershipsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	role := request.Role
+	if role == "" {
+		role = GroupRoleMember
+	}
+
+	now := time.Now()
+	set := bson.M{"role": role, "updated_at": now}
+	unset := bson.M{}
+	for field, value := range map[string]*time.Time{"valid_from": request.ValidFrom, "valid_until": request.ValidUntil} {
+		if value != nil {
+			set[field] = *value
+		} else {
+			unset[field] = ""
+		}
+	}
+
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"id": uuid.Must(uuid.NewRandom()).String(), "created_at": now},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var membership *models.GroupMembership
+	err := membershipCollection.FindOneAndUpdate(ctx,
+		Scoped(tenantId, bson.M{"group_id": groupId, "user_id": request.UserId}),
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&membership)
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+func DeleteMembership(ctx context.Context, tenantId string, groupId string, userId string) (bool, error) {
+	var membershipCollection *mongo.Collection = config.GetCollection(config.DB, config.GroupMembershipsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := membershipCollection.DeleteOne(ctx, Scoped(tenantId, bson.M{"group_id": groupId, "user_id": userId}))
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// setCurrentUser loads the active memberships of user and stores it as currentUser. It reports
+// false after aborting the request.
+func setCurrentUser(ctx *gin.Context, user *models.DBResponse) bool {
+	memberships, err := ActiveMemberships(ctx.Request.Context(), user)
+	if err != nil {
+		Fail(ctx, err)
+		return false
+	}
+
+	user.Memberships = memberships
+	ctx.Set("currentUser", user)
+	addLogFields(ctx, "user_id", user.Id, "tenant_id", user.TenantId)
+	return true
+}
+
+// HasGroupRole reports whether user holds one of roles in any of groupIds through an active
+// membership.
+func HasGroupRole(user *models.DBResponse, groupIds []string, roles ...string) bool {
+	for _, membership := range user.Memberships {
+		for _, groupId := range groupIds {
+			if membership.GroupId != groupId {
+				continue
+			}
+			for _, role := range roles {
+				if membership.Role == role {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// DeleteMemberships removes the memberships matching filter, e.g. those of a deleted user or group.
+func DeleteMemberships(ctx context.Context, tenantId string, filter bson.M) error {
+	var membershipCollection *mongo.Collection = config.GetCollection(config.DB, config.GroupMembershipsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := membershipCollection.DeleteMany(ctx, Scoped(tenantId, filter))
+	return err
+}
@@ -0,0 +1,172 @@
+package helpers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const metricsNamespace = "user_management"
+
+// Results recorded by RecordLogin.
+const (
+	LoginSuccess            = "success"
+	LoginUnknownUser        = "unknown_user"
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginDisabled           = "disabled"
+	LoginPasswordExpired    = "password_expired"
+	LoginSecondFactor       = "second_factor"
+	LoginKeyRegistration    = "key_registration"
+	LoginError              = "error"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "logins_total",
+		Help:      "Password logins by result.",
+	}, []string{"result"})
+
+	tokenIssueFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_issue_failures_total",
+		Help:      "Tokens that could not be signed.",
+	})
+
+	tokenValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_validation_failures_total",
+		Help:      "Tokens rejected by ValidateToken, by reason.",
+	}, []string{"reason"})
+
+	mongoCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "mongodb_command_duration_seconds",
+		Help:      "MongoDB command latency by command name and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"command", "outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(userCollector{
+		users: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "users"),
+			"Users by organization and state (active or disabled).",
+			[]string{"tenant_id", "state"}, nil,
+		),
+	})
+}
+
+// Metrics records the count and latency of every request under its route template, so
+// /user/read/:userId is one series rather than one per user. Unmatched paths share one label.
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		httpRequests.WithLabelValues(ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(ctx.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsHandler serves the collected metrics in the Prometheus exposition format.
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// RecordLogin counts a password login attempt with one of the Login* results.
+func RecordLogin(result string) {
+	logins.WithLabelValues(result).Inc()
+}
+
+// MongoMonitor times every MongoDB command. Pass it to the client options when connecting.
+func MongoMonitor() *event.CommandMonitor {
+	observe := func(command string, outcome string, duration int64) {
+		mongoCommandDuration.WithLabelValues(command, outcome).Observe(time.Duration(duration).Seconds())
+	}
+
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			observe(evt.CommandName, "success", evt.DurationNanos)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			observe(evt.CommandName, "failure", evt.DurationNanos)
+		},
+	}
+}
+
+// userCollector counts users when Prometheus scrapes, so the numbers never drift from the
+// database. Nothing is reported while MongoDB is unavailable.
+type userCollector struct {
+	users *prometheus.Desc
+}
+
+func (collector userCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.users
+}
+
+func (collector userCollector) Collect(metrics chan<- prometheus.Metric) {
+	if config.DB == nil {
+		return
+	}
+
+	var userCollection = config.GetCollection(config.DB, config.UsersCollection)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results, err := userCollection.Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"tenant_id": "$tenant_id", "disabled": bson.M{"$ifNull": bson.A{"$disabled", false}}},
+			"count": bson.M{"$sum": 1},
+		}},
+	})
+	if err != nil {
+		return
+	}
+	defer results.Close(ctx)
+
+	var counts []struct {
+		Id struct {
+			TenantId string `bson:"tenant_id"`
+			Disabled bool   `bson:"disabled"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := results.All(ctx, &counts); err != nil {
+		return
+	}
+
+	for _, count := range counts {
+		state := "active"
+		if count.Id.Disabled {
+			state = "disabled"
+		}
+		metrics <- prometheus.MustNewConstMetric(collector.users, prometheus.GaugeValue, float64(count.Count), count.Id.TenantId, state)
+	}
+}
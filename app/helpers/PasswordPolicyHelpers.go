@@ -0,0 +1,294 @@
+package helpers
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PasswordPolicy struct {
+	MinLength       int
+	RequireUpper    bool
+	RequireLower    bool
+	RequireDigit    bool
+	RequireSymbol   bool
+	BannedWordsFile string
+	BreachedFile    string
+	History         int
+	MaxAge          time.Duration
+}
+
+func NewPasswordPolicy(config config.Config) PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:       config.PasswordMinLength,
+		RequireUpper:    config.PasswordRequireUpper,
+		RequireLower:    config.PasswordRequireLower,
+		RequireDigit:    config.PasswordRequireDigit,
+		RequireSymbol:   config.PasswordRequireSymbol,
+		BannedWordsFile: config.PasswordBannedWordsFile,
+		BreachedFile:    config.PasswordBreachedFile,
+		History:         config.PasswordHistory,
+		MaxAge:          config.PasswordMaxAge,
+	}
+	if policy.MinLength <= 0 {
+		policy.MinLength = 8
+	}
+	return policy
+}
+
+// Check returns every rule the candidate password breaks, the messages are added in the locale of
+// the response. user is nil for accounts that do not exist yet.
+func (policy PasswordPolicy) Check(ctx context.Context, password string, user *models.DBResponse, email string) []models.PasswordViolation {
+	violations := []models.PasswordViolation{}
+	add := func(code string, args ...interface{}) {
+		violations = append(violations, models.PasswordViolation{Code: code, Args: args})
+	}
+
+	if len([]rune(password)) < policy.MinLength {
+		add("too_short", policy.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if policy.RequireUpper && !upper {
+		add("missing_uppercase")
+	}
+	if policy.RequireLower && !lower {
+		add("missing_lowercase")
+	}
+	if policy.RequireDigit && !digit {
+		add("missing_digit")
+	}
+	if policy.RequireSymbol && !symbol {
+		add("missing_symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if local, _, _ := strings.Cut(strings.ToLower(email), "@"); len(local) >= 4 && strings.Contains(lowered, local) {
+		add("contains_personal_info")
+	}
+
+	// a list that cannot be read rejects the password rather than letting it through unchecked
+	word, bannedErr := policy.bannedWord(lowered)
+	if word != "" {
+		add("banned_word", word)
+	}
+
+	breached, breachedErr := policy.breached(password)
+	if breached {
+		add("breached")
+	}
+
+	if bannedErr != nil || breachedErr != nil {
+		add("check_unavailable")
+	}
+
+	if user != nil && policy.reused(ctx, password, user) {
+		add("reused", policy.History+1)
+	}
+
+	return violations
+}
+
+// Expired reports whether the user's password is older than the configured maximum age.
+func (policy PasswordPolicy) Expired(user *models.DBResponse) bool {
+	if policy.MaxAge <= 0 || user.Password == "" || (user.Provider != "" && user.Provider != "local") {
+		return false
+	}
+
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+
+	return time.Since(changedAt) > policy.MaxAge
+}
+
+func (policy PasswordPolicy) reused(ctx context.Context, password string, user *models.DBResponse) bool {
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > policy.History+1 {
+		hashes = hashes[:policy.History+1]
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && config.VerifyPassword(ctx, hash, password) == nil {
+			return true
+		}
+	}
+
+	// a current hash written by an older release was made from the sanitised password
+	if legacy := legacySanitizedPassword(password); user.PasswordLegacySanitized && legacy != password {
+		return user.Password != "" && config.VerifyPassword(ctx, user.Password, legacy) == nil
+	}
+	return false
+}
+
+var bannedWords = struct {
+	sync.Mutex
+	path  string
+	words []string
+}{}
+
+func (policy PasswordPolicy) bannedWord(password string) (string, error) {
+	if policy.BannedWordsFile == "" {
+		return "", nil
+	}
+
+	bannedWords.Lock()
+	defer bannedWords.Unlock()
+
+	if bannedWords.path != policy.BannedWordsFile {
+		words, err := readLines(policy.BannedWordsFile)
+		if err != nil {
+			return "", err
+		}
+		bannedWords.path, bannedWords.words = policy.BannedWordsFile, words
+	}
+
+	for _, word := range bannedWords.words {
+		if word = strings.ToLower(word); len(word) >= 3 && strings.Contains(password, word) {
+			return word, nil
+		}
+	}
+	return "", nil
+}
+
+// breached looks the password's SHA-1 up in a local copy of a breached-password list. A directory
+// is read k-anonymity style: the file named after the first five hex digits lists the remaining
+// suffixes, as served by the Pwned Passwords range API. A plain file lists full hashes.
+func (policy PasswordPolicy) breached(password string) (bool, error) {
+	if policy.BreachedFile == "" {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	info, err := os.Stat(policy.BreachedFile)
+	if err != nil {
+		return false, err
+	}
+
+	path, want := policy.BreachedFile, hash
+	if info.IsDir() {
+		path, want = filepath.Join(policy.BreachedFile, prefix), suffix
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(entry, want) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// SavePassword stores a new password hash, keeping the previous ones for the reuse check.
+func SavePassword(ctx context.Context, user *models.DBResponse, hashedPassword string, history int) error {
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	previous := []string{}
+	if history > 0 {
+		if user.Password != "" {
+			previous = append(previous, user.Password)
+		}
+		previous = append(previous, user.PasswordHistory...)
+		if len(previous) > history {
+			previous = previous[:history]
+		}
+	}
+
+	now := time.Now()
+	update := bson.M{
+		"password":            hashedPassword,
+		"password_history":    previous,
+		"password_changed_at": now,
+		"updated_at":          now,
+	}
+
+	_, err := userCollection.UpdateOne(ctx, bson.M{"id": user.Id}, bson.M{"$set": update, "$unset": bson.M{"password_legacy_sanitized": ""}})
+	return err
+}
+
+// UpgradePasswordHash re-hashes a local password that has just been verified when its stored hash
+// uses an outdated algorithm or cost. It leaves the password history and age untouched.
+func UpgradePasswordHash(ctx context.Context, user *models.DBResponse, password string) error {
+	if user.Password == "" || (user.Provider != "" && user.Provider != "local") || !config.NeedsRehash(user.Password) {
+		return nil
+	}
+
+	return replacePasswordHash(ctx, user, password)
+}
+
+func replacePasswordHash(ctx context.Context, user *models.DBResponse, password string) error {
+	hashedPassword, err := config.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err = userCollection.UpdateOne(ctx, bson.M{"id": user.Id, "password": user.Password},
+		bson.M{"$set": bson.M{"password": hashedPassword}, "$unset": bson.M{"password_legacy_sanitized": ""}})
+	if err != nil {
+		return err
+	}
+
+	user.Password, user.PasswordLegacySanitized = hashedPassword, false
+	return nil
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+)
+
+func violationCodes(violations []models.PasswordViolation) []string {
+	codes := []string{}
+	for _, violation := range violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	dir := t.TempDir()
+	banned := filepath.Join(dir, "banned.txt")
+	if err := os.WriteFile(banned, []byte("# company words\nacme\nab\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// SHA-1 of "password1"
+	breached := filepath.Join(dir, "breached.txt")
+	if err := os.WriteFile(breached, []byte("E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D:2413945\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		email    string
+		want     []string
+	}{
+		{"valid", strict, "Correct-Horse-9", "", []string{}},
+		{"too short", PasswordPolicy{MinLength: 8}, "short", "", []string{"too_short"}},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "ñçåé", "", []string{}},
+		{"missing classes", strict, "aaaaaaaaaa", "", []string{"missing_uppercase", "missing_digit", "missing_symbol"}},
+		{"only symbols", strict, "!!!!!!!!!!", "", []string{"missing_uppercase", "missing_lowercase", "missing_digit"}},
+		{"contains the email", PasswordPolicy{MinLength: 8}, "xxJohnDoe99", "johndoe@example.com", []string{"contains_personal_info"}},
+		{"short email local parts are ignored", PasswordPolicy{MinLength: 8}, "xxjoe12345", "joe@example.com", []string{}},
+		{"banned word", PasswordPolicy{MinLength: 8, BannedWordsFile: banned}, "I-love-ACME-2024", "", []string{"banned_word"}},
+		{"short banned words are ignored", PasswordPolicy{MinLength: 8, BannedWordsFile: banned}, "abababab", "", []string{}},
+		{"breached", PasswordPolicy{MinLength: 8, BreachedFile: breached}, "password1", "", []string{"breached"}},
+		{"not breached", PasswordPolicy{MinLength: 8, BreachedFile: breached}, "password2", "", []string{}},
+		{"missing breached file", PasswordPolicy{MinLength: 8, BreachedFile: filepath.Join(dir, "none")}, "Unique-Pass-77", "", []string{"check_unavailable"}},
+		{"missing banned words file", PasswordPolicy{MinLength: 8, BannedWordsFile: filepath.Join(dir, "none")}, "Unique-Pass-77", "", []string{"check_unavailable"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := violationCodes(test.policy.Check(context.Background(), test.password, nil, test.email))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Check(%q) = %v, want %v", test.password, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyBreachedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// range file for the SHA-1 of "password1", as served by the Pwned Passwords API
+	if err := os.WriteFile(filepath.Join(dir, "E38AD"), []byte("0000000000000000000000000000000000A:1\r\n214943DAAD1D64C102FAEC29DE4AFE9DA3D:2413945\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := PasswordPolicy{MinLength: 8, BreachedFile: dir}
+	for password, want := range map[string]bool{"password1": true, "password2": false} {
+		if got, err := policy.breached(password); err != nil || got != want {
+			t.Errorf("breached(%q) = %v, %v, want %v", password, got, err, want)
+		}
+	}
+}
+
+func TestPasswordPolicyReuse(t *testing.T) {
+	hash := func(password string) string {
+		hashed, err := config.HashPassword(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hashed
+	}
+
+	user := &models.DBResponse{
+		Password:        hash("current-password"),
+		PasswordHistory: []string{hash("previous-password"), hash("oldest-password")},
+	}
+
+	tests := []struct {
+		name     string
+		history  int
+		password string
+		reused   bool
+	}{
+		{"current password without history", 0, "current-password", true},
+		{"previous password without history", 0, "previous-password", false},
+		{"previous password", 1, "previous-password", true},
+		{"password older than the history", 1, "oldest-password", false},
+		{"whole history", 2, "oldest-password", true},
+		{"new password", 2, "brand-new-password", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := PasswordPolicy{MinLength: 8, History: test.history}
+			codes := violationCodes(policy.Check(context.Background(), test.password, user, ""))
+			if got := reflect.DeepEqual(codes, []string{"reused"}); got != test.reused {
+				t.Errorf("Check(%q) = %v, reused want %v", test.password, codes, test.reused)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyReuseArgs(t *testing.T) {
+	hashed, err := config.HashPassword("current-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := PasswordPolicy{MinLength: 8, History: 3}
+	violations := policy.Check(context.Background(), "current-password", &models.DBResponse{Password: hashed}, "")
+	if len(violations) != 1 || !reflect.DeepEqual(violations[0].Args, []interface{}{4}) {
+		t.Errorf("Check() = %+v, want one reuse violation naming the last 4 passwords", violations)
+	}
+}
+
+func TestPasswordPolicyReuseLegacyHash(t *testing.T) {
+	password := "a<b>&c-password"
+	hashed, err := config.HashPassword(legacySanitizedPassword(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := PasswordPolicy{MinLength: 8}
+	for marked, want := range map[bool][]string{true: {"reused"}, false: {}} {
+		user := &models.DBResponse{Password: hashed, PasswordLegacySanitized: marked}
+		if got := violationCodes(policy.Check(context.Background(), password, user, "")); !reflect.DeepEqual(got, want) {
+			t.Errorf("Check() with the hash marked %v = %v, want %v", marked, got, want)
+		}
+	}
+}
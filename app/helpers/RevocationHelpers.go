@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tokenRevocationKey identifies a token in the revocation list. Tokens carry a
+// jti since revocation was introduced; older tokens fall back to a hash of the
+// raw token so they can still be revoked.
+func tokenRevocationKey(token string, claims jwt.MapClaims) string {
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		return "jti:" + jti
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func RevokeToken(ctx context.Context, token string, claims jwt.MapClaims, tokenType string) error {
+	var revokedCollection *mongo.Collection = config.GetCollection(config.DB, config.RevokedTokensCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	expiresAt := time.Now()
+	if exp, ok := claims["exp"].(float64); ok {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+
+	key := tokenRevocationKey(token, claims)
+	revoked := models.RevokedToken{
+		Key:       key,
+		Subject:   fmt.Sprint(claims["sub"]),
+		TokenType: tokenType,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}
+
+	_, err := revokedCollection.UpdateOne(
+		ctx,
+		bson.M{"key": key},
+		bson.M{"$setOnInsert": revoked},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func IsTokenRevoked(ctx context.Context, token string, claims jwt.MapClaims) (bool, error) {
+	var revokedCollection *mongo.Collection = config.GetCollection(config.DB, config.RevokedTokensCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	count, err := revokedCollection.CountDocuments(ctx, bson.M{"key": tokenRevocationKey(token, claims)})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,363 @@
+package helpers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ScimAttribute maps a SCIM attribute path onto a field of the stored document.
+type ScimAttribute struct {
+	Field     string
+	CaseExact bool
+	// Inverted is set for boolean SCIM attributes stored as their negation, e.g. active vs disabled.
+	Inverted bool
+	Date     bool
+}
+
+var ScimUserAttributes = map[string]ScimAttribute{
+	"id":                {Field: "id", CaseExact: true},
+	"externalid":        {Field: "external_id", CaseExact: true},
+	"username":          {Field: "email"},
+	"emails":            {Field: "email"},
+	"emails.value":      {Field: "email"},
+	"displayname":       {Field: "name"},
+	"name.formatted":    {Field: "name"},
+	"active":            {Field: "disabled", Inverted: true},
+	"groups.value":      {Field: "group", CaseExact: true},
+	"meta.created":      {Field: "created_at", CaseExact: true, Date: true},
+	"meta.lastmodified": {Field: "updated_at", CaseExact: true, Date: true},
+}
+
+var ScimGroupAttributes = map[string]ScimAttribute{
+	"id":          {Field: "id", CaseExact: true},
+	"externalid":  {Field: "external_id", CaseExact: true},
+	"displayname": {Field: "name"},
+}
+
+// ScimAuthorization checks the bearer token of the identity provider and binds the request to
+// the organization the token belongs to. Naming another organization is refused, so a token
+// only ever provisions its own.
+func ScimAuthorization(config config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tenant := ""
+		fields := strings.Fields(ctx.Request.Header.Get("Authorization"))
+		if len(fields) == 2 && fields[0] == "Bearer" {
+			tenant = scimTokenTenant(config, fields[1])
+		}
+		if tenant == "" {
+			ctx.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			ScimAbort(ctx, http.StatusUnauthorized, "", "Authorization failure")
+			return
+		}
+
+		current := CurrentTenant(ctx)
+		if current.ID != tenant && current.Slug != strings.ToLower(tenant) {
+			if ctx.GetBool("tenantRequested") {
+				ScimAbort(ctx, http.StatusForbidden, "", "The token is not valid for this organization")
+				return
+			}
+
+			organization, err := FindOrganization(ctx.Request.Context(), tenant)
+			if err != nil {
+				ScimFail(ctx, fmt.Errorf("organization %q of SCIM token: %w", tenant, err))
+				return
+			}
+			if organization.Disabled {
+				ScimAbort(ctx, http.StatusForbidden, "", "The organization is disabled")
+				return
+			}
+			ctx.Set("tenant", organization)
+		}
+
+		ctx.Next()
+	}
+}
+
+// scimTokenTenant returns the organization token belongs to, or "" when it is not configured.
+// Every configured token is compared, in constant time, so the answer does not reveal which
+// organizations have one.
+func scimTokenTenant(config config.Config, token string) string {
+	tenant := ""
+	for owner, expected := range config.ScimTenantTokens() {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			tenant = owner
+		}
+	}
+	return tenant
+}
+
+func ScimAbort(ctx *gin.Context, status int, scimType string, detail string) {
+	ctx.Header("Content-Type", "application/scim+json")
+	ctx.AbortWithStatusJSON(status, models.ScimError{
+		Schemas:  []string{models.ScimErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// ScimFail answers an unexpected error with a SCIM 500. The cause is kept in ctx.Errors for the
+// access log instead of being sent.
+func ScimFail(ctx *gin.Context, err error) {
+	ctx.Error(err)
+	ScimAbort(ctx, http.StatusInternalServerError, "", "Internal server error")
+}
+
+type filterToken struct {
+	value  string
+	quoted bool
+}
+
+func tokenizeScimFilter(filter string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(filter)
+
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, filterToken{value: string(r)})
+			i++
+		case r == '"':
+			var value strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, filterToken{value: value.String(), quoted: true})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			tokens = append(tokens, filterToken{value: string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+type scimFilterParser struct {
+	tokens     []filterToken
+	pos        int
+	attributes map[string]ScimAttribute
+}
+
+func (p *scimFilterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].value, keyword)
+}
+
+func (p *scimFilterParser) next() (filterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, fmt.Errorf("unexpected end of filter")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, nil
+}
+
+func (p *scimFilterParser) parseOr() (bson.M, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []bson.M{left}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return bson.M{"$or": terms}, nil
+}
+
+func (p *scimFilterParser) parseAnd() (bson.M, error) {
+	left, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []bson.M{left}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return bson.M{"$and": terms}, nil
+}
+
+func (p *scimFilterParser) parseAtom() (bson.M, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		inner, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$nor": []bson.M{inner}}, nil
+	}
+
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.quoted && token.value == "(" {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, err := p.next(); err != nil || closing.value != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return inner, nil
+	}
+
+	attribute, found := p.attributes[strings.ToLower(token.value)]
+	if token.quoted || !found {
+		return nil, fmt.Errorf("unsupported attribute %q", token.value)
+	}
+
+	operator, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(operator.value)
+
+	if op == "pr" {
+		if attribute.Inverted {
+			return bson.M{}, nil
+		}
+		return bson.M{attribute.Field: bson.M{"$exists": true, "$nin": bson.A{"", nil}}}, nil
+	}
+
+	valueToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return scimComparison(attribute, op, valueToken)
+}
+
+func scimComparison(attribute ScimAttribute, op string, token filterToken) (bson.M, error) {
+	if attribute.Inverted {
+		value, err := strconv.ParseBool(token.value)
+		if err != nil || (op != "eq" && op != "ne") {
+			return nil, fmt.Errorf("boolean attributes only support eq and ne")
+		}
+		if op == "ne" {
+			value = !value
+		}
+		if value {
+			return bson.M{attribute.Field: bson.M{"$ne": true}}, nil
+		}
+		return bson.M{attribute.Field: true}, nil
+	}
+
+	var value interface{} = token.value
+	if !token.quoted {
+		return nil, fmt.Errorf("comparison value must be a string")
+	}
+	if attribute.Date {
+		t, err := time.Parse(time.RFC3339, token.value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an RFC 3339 date", token.value)
+		}
+		value = t
+	}
+
+	options := "i"
+	if attribute.CaseExact {
+		options = ""
+	}
+	quoted := regexp.QuoteMeta(token.value)
+
+	switch op {
+	case "eq":
+		if attribute.CaseExact {
+			return bson.M{attribute.Field: value}, nil
+		}
+		return bson.M{attribute.Field: bson.M{"$regex": "^" + quoted + "$", "$options": options}}, nil
+	case "ne":
+		if attribute.CaseExact {
+			return bson.M{attribute.Field: bson.M{"$ne": value}}, nil
+		}
+		return bson.M{attribute.Field: bson.M{"$not": bson.M{"$regex": "^" + quoted + "$", "$options": options}}}, nil
+	case "co":
+		if attribute.Date {
+			break
+		}
+		return bson.M{attribute.Field: bson.M{"$regex": quoted, "$options": options}}, nil
+	case "sw":
+		if attribute.Date {
+			break
+		}
+		return bson.M{attribute.Field: bson.M{"$regex": "^" + quoted, "$options": options}}, nil
+	case "ew":
+		if attribute.Date {
+			break
+		}
+		return bson.M{attribute.Field: bson.M{"$regex": quoted + "$", "$options": options}}, nil
+	case "gt", "ge", "lt", "le":
+		mongoOp := map[string]string{"gt": "$gt", "ge": "$gte", "lt": "$lt", "le": "$lte"}[op]
+		return bson.M{attribute.Field: bson.M{mongoOp: value}}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+// ParseScimFilter translates a SCIM filter expression (RFC 7644 section 3.4.2.2) into a
+// MongoDB query over the given attribute mapping.
+func ParseScimFilter(filter string, attributes map[string]ScimAttribute) (bson.M, error) {
+	if strings.TrimSpace(filter) == "" {
+		return bson.M{}, nil
+	}
+
+	tokens, err := tokenizeScimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &scimFilterParser{tokens: tokens, attributes: attributes}
+	query, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos != len(tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", tokens[parser.pos].value)
+	}
+
+	return query, nil
+}
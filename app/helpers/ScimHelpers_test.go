@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseScimFilter(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter string
+		want   bson.M
+	}{
+		{"empty", "  ", bson.M{}},
+		{"case insensitive eq", `userName eq "Ana@Example.com"`,
+			bson.M{"email": bson.M{"$regex": `^Ana@Example\.com$`, "$options": "i"}}},
+		{"case exact eq", `externalId eq "A-1"`, bson.M{"external_id": "A-1"}},
+		{"case exact ne", `id ne "x"`, bson.M{"id": bson.M{"$ne": "x"}}},
+		{"ne", `displayName ne "ana"`,
+			bson.M{"name": bson.M{"$not": bson.M{"$regex": "^ana$", "$options": "i"}}}},
+		{"co quotes regex characters", `displayName co "a.b*"`,
+			bson.M{"name": bson.M{"$regex": `a\.b\*`, "$options": "i"}}},
+		{"sw", `userName sw "ana"`, bson.M{"email": bson.M{"$regex": "^ana", "$options": "i"}}},
+		{"ew", `userName ew "@example.com"`, bson.M{"email": bson.M{"$regex": `@example\.com$`, "$options": "i"}}},
+		{"pr", "externalId pr", bson.M{"external_id": bson.M{"$exists": true, "$nin": bson.A{"", nil}}}},
+		{"pr on an inverted attribute", "active pr", bson.M{}},
+		{"active true", "active eq true", bson.M{"disabled": bson.M{"$ne": true}}},
+		{"active false", "active eq false", bson.M{"disabled": true}},
+		{"active ne true", "active ne true", bson.M{"disabled": true}},
+		{"date", `meta.created gt "2024-01-02T03:04:05Z"`, bson.M{"created_at": bson.M{"$gt": created}}},
+		{"keywords and attributes ignore case", `USERNAME EQ "a" AND Active Eq True`, bson.M{"$and": []bson.M{
+			{"email": bson.M{"$regex": "^a$", "$options": "i"}},
+			{"disabled": bson.M{"$ne": true}},
+		}}},
+		{"and binds tighter than or", `id eq "1" or id eq "2" and id eq "3"`, bson.M{"$or": []bson.M{
+			{"id": "1"},
+			{"$and": []bson.M{{"id": "2"}, {"id": "3"}}},
+		}}},
+		{"parentheses", `(id eq "1" or id eq "2") and id eq "3"`, bson.M{"$and": []bson.M{
+			{"$or": []bson.M{{"id": "1"}, {"id": "2"}}},
+			{"id": "3"},
+		}}},
+		{"not", `not (id eq "1")`, bson.M{"$nor": []bson.M{{"id": "1"}}}},
+		{"escaped quote", `displayName eq "say \"hi\""`,
+			bson.M{"name": bson.M{"$regex": `^say "hi"$`, "$options": "i"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseScimFilter(test.filter, ScimUserAttributes)
+			if err != nil {
+				t.Fatalf("ParseScimFilter(%q) failed: %v", test.filter, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseScimFilter(%q) = %v, want %v", test.filter, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseScimFilterRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{"unknown attribute", `password eq "x"`},
+		{"quoted attribute", `"userName" eq "x"`},
+		{"unknown operator", `userName like "x"`},
+		{"unquoted value", `userName eq ana`},
+		{"missing value", `userName eq`},
+		{"unterminated string", `userName eq "ana`},
+		{"missing closing parenthesis", `(id eq "1"`},
+		{"trailing token", `id eq "1" )`},
+		{"dangling and", `id eq "1" and`},
+		{"boolean with gt", `active gt true`},
+		{"boolean that is not one", `active eq "yes"`},
+		{"invalid date", `meta.created gt "yesterday"`},
+		{"co on a date", `meta.created co "2024"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, err := ParseScimFilter(test.filter, ScimUserAttributes); err == nil {
+				t.Errorf("ParseScimFilter(%q) = %v, want an error", test.filter, got)
+			}
+		})
+	}
+}
+
+func TestParseScimFilterGroupAttributes(t *testing.T) {
+	if _, err := ParseScimFilter(`userName eq "x"`, ScimGroupAttributes); err == nil {
+		t.Error("user attributes must not be accepted in group filters")
+	}
+
+	got, err := ParseScimFilter(`displayName eq "Finance"`, ScimGroupAttributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.M{"name": bson.M{"$regex": "^Finance$", "$options": "i"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/oauth2"
+)
+
+// RandomToken returns n random bytes encoded for use in URLs and cookies.
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// PKCEChallenge derives the S256 code challenge for verifier (RFC 7636 section 4.2).
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func OAuth2Config(provider config.OAuthProvider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  provider.RedirectURL,
+		Scopes:       provider.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.AuthURL,
+			TokenURL: provider.TokenURL,
+		},
+	}
+}
+
+// FetchExternalIdentity calls the provider's userinfo endpoint and maps the configured claims.
+func FetchExternalIdentity(ctx context.Context, provider config.OAuthProvider, token *oauth2.Token) (*models.ExternalIdentity, error) {
+	client := OAuth2Config(provider).Client(ctx, token)
+
+	response, err := client.Get(provider.UserInfoURL)
+	if err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userinfo: unexpected status %d", response.StatusCode)
+	}
+
+	// numbers are kept as json.Number so numeric ids (GitHub style) are not rendered as floats
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+
+	identity := &models.ExternalIdentity{Provider: provider.Name}
+	if subject, ok := claims[provider.SubjectClaim]; ok && subject != nil {
+		identity.Subject = fmt.Sprint(subject)
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("userinfo: missing %s claim", provider.SubjectClaim)
+	}
+
+	identity.Email, _ = claims[provider.EmailClaim].(string)
+	identity.Email = strings.ToLower(identity.Email)
+	name, _ := claims[provider.NameClaim].(string)
+	identity.Name = SanitizeText(name)
+
+	switch verified := claims[provider.EmailVerifiedClaim].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	if provider.TrustEmail && identity.Email != "" {
+		identity.EmailVerified = true
+	}
+
+	return identity, nil
+}
+
+func FindUserByIdentity(ctx context.Context, tenantId string, provider string, subject string) (*models.DBResponse, error) {
+	var user *models.DBResponse
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	query := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}, "tenant_id": tenantId}
+	err := userCollection.FindOne(ctx, query).Decode(&user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func LinkIdentity(ctx context.Context, userId string, identity models.ExternalIdentity) error {
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	linked := models.LinkedIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now(),
+	}
+
+	_, err := userCollection.UpdateOne(ctx,
+		bson.M{"id": userId},
+		bson.M{"$push": bson.M{"identities": linked}, "$set": bson.M{"updated_at": time.Now()}})
+	return err
+}
@@ -0,0 +1,209 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"golang.org/x/oauth2"
+)
+
+// mockOAuthProvider serves the authorize, token and userinfo endpoints of an authorization server
+// that issues one code, bound to the PKCE challenge it was requested with.
+type mockOAuthProvider struct {
+	*httptest.Server
+	challenge string
+	claims    map[string]interface{}
+}
+
+const (
+	mockClientID     = "client"
+	mockClientSecret = "secret"
+	mockCode         = "code-123"
+	mockAccessToken  = "access-123"
+)
+
+func newMockOAuthProvider(t *testing.T, claims map[string]interface{}) (*mockOAuthProvider, config.OAuthProvider) {
+	mock := &mockOAuthProvider{claims: claims}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("client_id") != mockClientID || query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return
+		}
+		mock.challenge = query.Get("code_challenge")
+
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {mockCode}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		}
+		if clientID != mockClientID || clientSecret != mockClientSecret {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.PostFormValue("code") != mockCode || PKCEChallenge(r.PostFormValue("code_verifier")) != mock.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": mockAccessToken, "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+mockAccessToken {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mock.claims)
+	})
+
+	mock.Server = httptest.NewServer(mux)
+	t.Cleanup(mock.Close)
+
+	provider := config.OAuthProvider{
+		Name:               "mock",
+		ClientID:           mockClientID,
+		ClientSecret:       mockClientSecret,
+		AuthURL:            mock.URL + "/authorize",
+		TokenURL:           mock.URL + "/token",
+		UserInfoURL:        mock.URL + "/userinfo",
+		RedirectURL:        "http://localhost/auth/mock/callback",
+		Scopes:             []string{"openid", "email"},
+		SubjectClaim:       "sub",
+		EmailClaim:         "email",
+		EmailVerifiedClaim: "email_verified",
+		NameClaim:          "name",
+	}
+	return mock, provider
+}
+
+// authorize follows the provider's redirect the way a browser would and returns the code and state
+// handed to the callback.
+func (mock *mockOAuthProvider) authorize(t *testing.T, provider config.OAuthProvider, state string, verifier string) (string, string) {
+	authURL := OAuth2Config(provider).AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", PKCEChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	response, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	location, err := response.Location()
+	if err != nil {
+		t.Fatalf("authorize: %d without a redirect", response.StatusCode)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestSocialLoginFlow(t *testing.T) {
+	mock, provider := newMockOAuthProvider(t, map[string]interface{}{
+		"sub":            "12345",
+		"email":          "Ana@Example.com",
+		"email_verified": true,
+		"name":           "Ana",
+	})
+
+	verifier, err := RandomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := mock.authorize(t, provider, "state-abc", verifier)
+	if state != "state-abc" {
+		t.Fatalf("state = %q, want it echoed back", state)
+	}
+
+	ctx := context.Background()
+	token, err := OAuth2Config(provider).Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+
+	identity, err := FetchExternalIdentity(ctx, provider, token)
+	if err != nil {
+		t.Fatalf("FetchExternalIdentity() failed: %v", err)
+	}
+	want := models.ExternalIdentity{Provider: "mock", Subject: "12345", Email: "ana@example.com", EmailVerified: true, Name: "Ana"}
+	if *identity != want {
+		t.Errorf("FetchExternalIdentity() = %+v, want %+v", *identity, want)
+	}
+
+	if _, err := OAuth2Config(provider).Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", "another-verifier")); err == nil {
+		t.Error("Exchange() accepted a code with the wrong PKCE verifier")
+	}
+}
+
+func TestFetchExternalIdentityClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		trust  bool
+		want   models.ExternalIdentity
+	}{
+		{"numeric subject", map[string]interface{}{"sub": 583231, "email": "a@example.com"}, false,
+			models.ExternalIdentity{Provider: "mock", Subject: "583231", Email: "a@example.com"}},
+		{"large numeric subject", map[string]interface{}{"sub": json.Number("9007199254740993")}, false,
+			models.ExternalIdentity{Provider: "mock", Subject: "9007199254740993"}},
+		{"verified as a string", map[string]interface{}{"sub": "1", "email": "a@example.com", "email_verified": "true"}, false,
+			models.ExternalIdentity{Provider: "mock", Subject: "1", Email: "a@example.com", EmailVerified: true}},
+		{"unverified", map[string]interface{}{"sub": "1", "email": "a@example.com", "email_verified": false}, false,
+			models.ExternalIdentity{Provider: "mock", Subject: "1", Email: "a@example.com"}},
+		{"trusted provider", map[string]interface{}{"sub": "1", "email": "a@example.com"}, true,
+			models.ExternalIdentity{Provider: "mock", Subject: "1", Email: "a@example.com", EmailVerified: true}},
+		{"markup in the name", map[string]interface{}{"sub": "1", "name": `Ana <script>alert(1)</script>Lima`}, false,
+			models.ExternalIdentity{Provider: "mock", Subject: "1", Name: "Ana Lima"}},
+		{"name that is not a string", map[string]interface{}{"sub": "1", "name": map[string]interface{}{"given": "Ana"}}, false,
+			models.ExternalIdentity{Provider: "mock", Subject: "1"}},
+		{"trusted provider without an email", map[string]interface{}{"sub": "1"}, true,
+			models.ExternalIdentity{Provider: "mock", Subject: "1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, provider := newMockOAuthProvider(t, test.claims)
+			provider.TrustEmail = test.trust
+
+			identity, err := FetchExternalIdentity(context.Background(), provider, &oauth2.Token{AccessToken: mockAccessToken})
+			if err != nil {
+				t.Fatalf("FetchExternalIdentity() failed: %v", err)
+			}
+			if *identity != test.want {
+				t.Errorf("FetchExternalIdentity() = %+v, want %+v", *identity, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchExternalIdentityRejects(t *testing.T) {
+	t.Run("missing subject", func(t *testing.T) {
+		_, provider := newMockOAuthProvider(t, map[string]interface{}{"email": "a@example.com"})
+		if _, err := FetchExternalIdentity(context.Background(), provider, &oauth2.Token{AccessToken: mockAccessToken}); err == nil {
+			t.Error("FetchExternalIdentity() accepted an identity without a subject")
+		}
+	})
+
+	t.Run("rejected token", func(t *testing.T) {
+		_, provider := newMockOAuthProvider(t, map[string]interface{}{"sub": "1"})
+		if _, err := FetchExternalIdentity(context.Background(), provider, &oauth2.Token{AccessToken: "revoked"}); err == nil {
+			t.Error("FetchExternalIdentity() accepted a userinfo error")
+		}
+	})
+}
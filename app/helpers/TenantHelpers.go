@@ -0,0 +1,198 @@
+package helpers
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TenantHeader names the organization, by id or slug, for clients that cannot use a subdomain.
+const TenantHeader = "X-Tenant-ID"
+
+// TenantClaim carries the user's organization in access and refresh tokens.
+const TenantClaim = "tid"
+
+// FindOrganization looks an organization up by id or slug.
+func FindOrganization(ctx context.Context, idOrSlug string) (*models.Organization, error) {
+	var organizationCollection *mongo.Collection = config.GetCollection(config.DB, config.OrganizationsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var organization *models.Organization
+	query := bson.M{"$or": []bson.M{{"id": idOrSlug}, {"slug": strings.ToLower(idOrSlug)}}}
+	if err := organizationCollection.FindOne(ctx, query).Decode(&organization); err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+// Scoped restricts a query to one organization.
+func Scoped(tenantId string, filter bson.M) bson.M {
+	filter["tenant_id"] = tenantId
+	return filter
+}
+
+// requestedTenant returns the organization named by the X-Tenant-ID header or, when
+// TENANT_BASE_DOMAIN is set, by the first label of the request host.
+func requestedTenant(ctx *gin.Context) string {
+	if tenant := strings.TrimSpace(ctx.GetHeader(TenantHeader)); tenant != "" {
+		return tenant
+	}
+
+	baseDomain := strings.ToLower(AppConfig(ctx).TenantBaseDomain)
+	if baseDomain == "" {
+		return ""
+	}
+
+	host := strings.ToLower(ctx.Request.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.HasSuffix(host, "."+baseDomain) {
+		if sub := strings.TrimSuffix(host, "."+baseDomain); !strings.Contains(sub, ".") {
+			return sub
+		}
+	}
+	return ""
+}
+
+// SetTenant switches the request to another organization, by id or slug. It reports false
+// after aborting the request when the organization is unknown or disabled.
+func SetTenant(ctx *gin.Context, idOrSlug string) bool {
+	organization, err := FindOrganization(ctx.Request.Context(), idOrSlug)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			Fail(ctx, apperrors.New(apperrors.OrganizationNotFound))
+			return false
+		}
+		Fail(ctx, err)
+		return false
+	}
+
+	if organization.Disabled {
+		Fail(ctx, apperrors.New(apperrors.OrganizationDisabled))
+		return false
+	}
+
+	ctx.Set("tenant", organization)
+	return true
+}
+
+// ResolveTenant selects the organization a request works in: the X-Tenant-ID header, the
+// subdomain, or the default organization. AuthorizationUser later replaces the default with
+// the organization in the caller's token.
+func ResolveTenant(config config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tenant := requestedTenant(ctx)
+		ctx.Set("tenantRequested", tenant != "")
+		if tenant == "" {
+			tenant = config.DefaultTenantId()
+		}
+
+		if !SetTenant(ctx, tenant) {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// CurrentTenant returns the organization chosen by ResolveTenant and AuthorizationUser.
+func CurrentTenant(ctx *gin.Context) *models.Organization {
+	return ctx.MustGet("tenant").(*models.Organization)
+}
+
+// TenantId is CurrentTenant(ctx).ID.
+func TenantId(ctx *gin.Context) string {
+	return CurrentTenant(ctx).ID
+}
+
+// TokenTenant returns the organization a token was issued for. Tokens from before
+// multi-tenancy have no claim and belong to the default organization.
+func TokenTenant(config config.Config, claims jwt.MapClaims) string {
+	if tenant, ok := claims[TenantClaim].(string); ok && tenant != "" {
+		return tenant
+	}
+	return config.DefaultTenantId()
+}
+
+// bindTenant checks that an authenticated user may work in the request's organization. Without
+// an explicit X-Tenant-ID or subdomain, the user's own organization is used. Only super admins
+// may act in other organizations. It reports false after aborting the request.
+func bindTenant(ctx *gin.Context, user *models.DBResponse) bool {
+	if !ctx.GetBool("tenantRequested") && TenantId(ctx) != user.TenantId {
+		if !SetTenant(ctx, user.TenantId) {
+			return false
+		}
+	}
+
+	if TenantId(ctx) != user.TenantId && !IsSuperAdmin(ctx, user) {
+		Fail(ctx, apperrors.New(apperrors.WrongOrganization))
+		return false
+	}
+	return true
+}
+
+// IsSuperAdmin reports whether user may manage every organization.
+func IsSuperAdmin(ctx *gin.Context, user *models.DBResponse) bool {
+	return AppConfig(ctx).IsSuperAdmin(user.Id)
+}
+
+// IsTenantAdmin reports whether user administers the request's organization, either through
+// the organization's admin level or as a super admin.
+func IsTenantAdmin(ctx *gin.Context, user *models.DBResponse) bool {
+	if IsSuperAdmin(ctx, user) {
+		return true
+	}
+
+	tenant := CurrentTenant(ctx)
+	adminLevel := tenant.AdminLevel
+	if adminLevel == "" {
+		adminLevel = AppConfig(ctx).DefaultAdminLevel()
+	}
+	return user.TenantId == tenant.ID && user.Level == adminLevel
+}
+
+// AdminRank orders users by their rights in the request's organization: 2 for super admins,
+// 1 for admins of the organization and 0 for everyone else.
+func AdminRank(ctx *gin.Context, user *models.DBResponse) int {
+	switch {
+	case IsSuperAdmin(ctx, user):
+		return 2
+	case IsTenantAdmin(ctx, user):
+		return 1
+	}
+	return 0
+}
+
+// RequireAdmin lets only admins of the request's organization through. It runs after
+// AuthorizationUser.
+func RequireAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !IsTenantAdmin(ctx, ctx.MustGet("currentUser").(*models.DBResponse)) {
+			Fail(ctx, apperrors.New(apperrors.Forbidden))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequireSuperAdmin lets only the users in SUPER_ADMIN_IDS through. It runs after AuthorizationUser.
+func RequireSuperAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !IsSuperAdmin(ctx, ctx.MustGet("currentUser").(*models.DBResponse)) {
+			Fail(ctx, apperrors.New(apperrors.Forbidden))
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -1,68 +1,118 @@
-package helpers
-
-import (
-	"encoding/base64"
-	"fmt"
-	"time"
-
-	"github.com/golang-jwt/jwt"
-)
-
-func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (string, error) {
-	decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
-	if err != nil {
-		return "", fmt.Errorf("could not decode key: %w", err)
-	}
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(decodedPrivateKey)
-
-	if err != nil {
-		return "", fmt.Errorf("create: parse key: %w", err)
-	}
-
-	now := time.Now().UTC()
-
-	claims := make(jwt.MapClaims)
-	claims["sub"] = payload
-	claims["exp"] = now.Add(ttl).Unix()
-	claims["iat"] = now.Unix()
-	claims["nbf"] = now.Unix()
-
-	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
-
-	if err != nil {
-		return "", fmt.Errorf("create: sign token: %w", err)
-	}
-
-	return token, nil
-}
-
-func ValidateToken(token string, publicKey string) (interface{}, error) {
-	decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
-	if err != nil {
-		return nil, fmt.Errorf("could not decode: %w", err)
-	}
-
-	key, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
-
-	if err != nil {
-		return "", fmt.Errorf("validate: parse key: %w", err)
-	}
-
-	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected method: %s", t.Header["alg"])
-		}
-		return key, nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("validate: %w", err)
-	}
-
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok || !parsedToken.Valid {
-		return nil, fmt.Errorf("validate: invalid token")
-	}
-
-	return claims["sub"], nil
-}
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	// ErrTokenRevoked means the token is correctly signed and unexpired but has been revoked.
+	ErrTokenRevoked = errors.New("validate: token has been revoked")
+	// ErrRevocationCheck means the token is correctly signed but the revocations could not be read.
+	ErrRevocationCheck = errors.New("validate: check revocation")
+)
+
+func CreateToken(ctx context.Context, ttl time.Duration, payload interface{}, privateKey string) (string, error) {
+	return CreateTokenWithClaims(ctx, ttl, payload, nil, privateKey)
+}
+
+// CreateTokenWithClaims signs a token carrying extra claims next to the registered ones.
+func CreateTokenWithClaims(ctx context.Context, ttl time.Duration, payload interface{}, extra map[string]interface{}, privateKey string) (string, error) {
+	_, span := config.Tracer().Start(ctx, "CreateToken")
+	defer span.End()
+
+	key, err := config.ParsePrivateKey(privateKey)
+	if err != nil {
+		tokenIssueFailures.Inc()
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("create: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	claims := make(jwt.MapClaims)
+	for name, value := range extra {
+		claims[name] = value
+	}
+	claims["sub"] = payload
+	claims["jti"] = uuid.Must(uuid.NewRandom()).String()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+
+	if err != nil {
+		tokenIssueFailures.Inc()
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("create: sign token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ParseToken verifies the signature and time claims of token and returns all of its claims.
+func ParseToken(token string, publicKey string) (jwt.MapClaims, error) {
+	key, err := config.ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("validate: %w", err)
+	}
+
+	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected method: %s", t.Header["alg"])
+		}
+		return key, nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("validate: %w", err)
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || !parsedToken.Valid {
+		return nil, fmt.Errorf("validate: invalid token")
+	}
+
+	return claims, nil
+}
+
+func ValidateToken(ctx context.Context, token string, publicKey string) (interface{}, error) {
+	claims, err := ValidateTokenClaims(ctx, token, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims["sub"], nil
+}
+
+// ValidateTokenClaims is ValidateToken for callers that need claims besides the subject.
+func ValidateTokenClaims(ctx context.Context, token string, publicKey string) (jwt.MapClaims, error) {
+	ctx, span := config.Tracer().Start(ctx, "ValidateToken")
+	defer span.End()
+
+	claims, err := ParseToken(token, publicKey)
+	if err != nil {
+		tokenValidationFailures.WithLabelValues("invalid").Inc()
+		return nil, err
+	}
+
+	revoked, err := IsTokenRevoked(ctx, token, claims)
+	if err != nil {
+		tokenValidationFailures.WithLabelValues("error").Inc()
+		return nil, fmt.Errorf("%w: %w", ErrRevocationCheck, err)
+	}
+	if revoked {
+		tokenValidationFailures.WithLabelValues("revoked").Inc()
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
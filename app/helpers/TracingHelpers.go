@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// untracedPaths are polled by infrastructure and would only add noise to the traces.
+var untracedPaths = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// Tracing starts a span for every request, continuing the trace of the caller when the request
+// carries a W3C traceparent header. Handlers pass ctx.Request.Context() on so database calls and
+// token signing show up below it.
+func Tracing(serviceName string) gin.HandlerFunc {
+	return otelgin.Middleware(serviceName, otelgin.WithFilter(func(request *http.Request) bool {
+		return !untracedPaths[request.URL.Path]
+	}))
+}
@@ -1,155 +1,250 @@
-package helpers
-
-import (
-	"context"
-	"fmt"
-	"net/http"
-	"strings"
-
-	"github.com/RadenAbror/UserManagement/app/config"
-	"github.com/RadenAbror/UserManagement/app/models"
-	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-// func FindUserById(id string) (*models.DBResponse, error) {
-// 	oid, _ := primitive.ObjectIDFromHex(id)
-
-// 	var user *models.DBResponse
-// 	var userCollection *mongo.Collection = config.GetCollection(config.DB, "users")
-// 	var ctx context.Context
-
-// 	query := bson.M{"_id": oid}
-// 	err := userCollection.FindOne(ctx, query).Decode(&user)
-
-// 	if err != nil {
-// 		if err == mongo.ErrNoDocuments {
-// 			return &models.DBResponse{}, err
-// 		}
-// 		return nil, err
-// 	}
-
-// 	return user, nil
-// }
-
-func FindUserById(id string) (*models.DBResponse, error) {
-	oid := id
-
-	var user *models.DBResponse
-	var userCollection *mongo.Collection = config.GetCollection(config.DB, "users")
-	var ctx context.Context
-
-	query := bson.M{"id": oid}
-	err := userCollection.FindOne(ctx, query).Decode(&user)
-
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return &models.DBResponse{}, err
-		}
-		return nil, err
-	}
-
-	return user, nil
-}
-
-func FindUserByEmail(email string) (*models.DBResponse, error) {
-	var user *models.DBResponse
-	var userCollection *mongo.Collection = config.GetCollection(config.DB, "users")
-	var ctx context.Context
-
-	query := bson.M{"email": strings.ToLower(email)}
-	err := userCollection.FindOne(ctx, query).Decode(&user)
-
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return &models.DBResponse{}, err
-		}
-		return nil, err
-	}
-
-	return user, nil
-}
-
-func AuthorizationUser() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		var access_token string
-		cookie, err := ctx.Cookie("access_token")
-
-		authorizationHeader := ctx.Request.Header.Get("Authorization")
-		fields := strings.Fields(authorizationHeader)
-
-		if len(fields) != 0 && fields[0] == "Bearer" {
-			access_token = fields[1]
-		} else if err == nil {
-			access_token = cookie
-		}
-
-		if access_token == "" {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "You are not logged in"})
-			return
-		}
-
-		config, _ := config.LoadConfig(".")
-		sub, err := ValidateToken(access_token, config.AccessTokenPublicKey)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
-			return
-		}
-
-		user, err := FindUserById(fmt.Sprint(sub))
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "The user belonging to this token no logger exists"})
-			return
-		}
-
-		ctx.Set("currentUser", user)
-		ctx.Next()
-	}
-}
-
-func Authorization() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		var userToken models.UserAuthorization
-		var access_token string
-		// cookie, err := ctx.Cookie("access_token")
-
-		// validate the request body
-		if err := ctx.BindJSON(&userToken); err != nil {
-			ctx.JSON(http.StatusBadRequest, models.RequestResponse{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		cookie := userToken.AccessToken
-
-		authorizationHeader := ctx.Request.Header.Get("Authorization")
-		fields := strings.Fields(authorizationHeader)
-
-		if len(fields) != 0 && fields[0] == "Bearer" {
-			access_token = fields[1]
-		} else {
-			access_token = cookie
-		}
-
-		if access_token == "" {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "You are not logged in"})
-			return
-		}
-
-		config, _ := config.LoadConfig(".")
-		sub, err := ValidateToken(access_token, config.AccessTokenPublicKey)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
-			return
-		}
-
-		user, err := FindUserById(fmt.Sprint(sub))
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "The user belonging to this token no logger exists"})
-			return
-		}
-
-		ctx.Set("currentUser", user)
-		ctx.Next()
-	}
-}
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// func FindUserById(id string) (*models.DBResponse, error) {
+// 	oid, _ := primitive.ObjectIDFromHex(id)
+
+// 	var user *models.DBResponse
+// 	var userCollection *mongo.Collection = config.GetCollection(config.DB, "users")
+// 	var ctx context.Context
+
+// 	query := bson.M{"_id": oid}
+// 	err := userCollection.FindOne(ctx, query).Decode(&user)
+
+// 	if err != nil {
+// 		if err == mongo.ErrNoDocuments {
+// 			return &models.DBResponse{}, err
+// 		}
+// 		return nil, err
+// 	}
+
+// 	return user, nil
+// }
+
+func FindUserById(ctx context.Context, tenantId string, id string) (*models.DBResponse, error) {
+	oid := id
+
+	var user *models.DBResponse
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+
+	query := bson.M{"id": oid, "tenant_id": tenantId}
+	err := userCollection.FindOne(ctx, query).Decode(&user)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &models.DBResponse{}, err
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func FindUserByEmail(ctx context.Context, tenantId string, email string) (*models.DBResponse, error) {
+	var user *models.DBResponse
+	var userCollection *mongo.Collection = config.GetCollection(config.DB, config.UsersCollection)
+
+	query := bson.M{"email": strings.ToLower(email), "tenant_id": tenantId}
+	err := userCollection.FindOne(ctx, query).Decode(&user)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &models.DBResponse{}, err
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func AuthorizationUser(config config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if key := apiKeyFromRequest(ctx); key != "" {
+			authorizeApiKey(ctx, key)
+			return
+		}
+
+		var access_token string
+		cookie, err := ctx.Cookie("access_token")
+
+		authorizationHeader := ctx.Request.Header.Get("Authorization")
+		fields := strings.Fields(authorizationHeader)
+
+		if len(fields) != 0 && fields[0] == "Bearer" {
+			access_token = fields[1]
+		} else if err == nil {
+			access_token = cookie
+		}
+
+		if access_token == "" {
+			Fail(ctx, apperrors.New(apperrors.Unauthenticated))
+			return
+		}
+
+		claims, err := ValidateTokenClaims(ctx.Request.Context(), access_token, config.AccessTokenPublicKey)
+		if err != nil {
+			Fail(ctx, apperrors.Wrap(apperrors.InvalidToken, err))
+			return
+		}
+
+		if TokenScope(claims) == RegistrationScope && !ctx.GetBool("registrationScopeAllowed") {
+			Fail(ctx, apperrors.New(apperrors.SecurityKeyRequired))
+			return
+		}
+
+		user, err := FindUserById(ctx.Request.Context(), TokenTenant(config, claims), fmt.Sprint(claims["sub"]))
+		if err != nil {
+			Fail(ctx, apperrors.Wrap(apperrors.SessionUserNotFound, err))
+			return
+		}
+
+		if user.Disabled {
+			Fail(ctx, apperrors.New(apperrors.AccountDisabled))
+			return
+		}
+
+		if !bindTenant(ctx, user) {
+			return
+		}
+
+		if !setImpersonator(ctx, claims) {
+			return
+		}
+
+		if !setCurrentUser(ctx, user) {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// AuthorizationRegistration is AuthorizationUser that also accepts the session limited to
+// registering a security key, for the routes that registration goes through.
+func AuthorizationRegistration(config config.Config) gin.HandlerFunc {
+	authorize := AuthorizationUser(config)
+	return func(ctx *gin.Context) {
+		ctx.Set("registrationScopeAllowed", true)
+		authorize(ctx)
+	}
+}
+
+// TokenScope returns the scope an access token is limited to, or "" for a full session.
+func TokenScope(claims jwt.MapClaims) string {
+	scope, _ := claims[ScopeClaim].(string)
+	return scope
+}
+
+func Authorization(config config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var userToken models.UserAuthorization
+		var access_token string
+		// cookie, err := ctx.Cookie("access_token")
+
+		// validate the request body
+		if err := ctx.ShouldBindJSON(&userToken); err != nil {
+			Fail(ctx, apperrors.Binding(err))
+			return
+		}
+
+		cookie := userToken.AccessToken
+
+		authorizationHeader := ctx.Request.Header.Get("Authorization")
+		fields := strings.Fields(authorizationHeader)
+
+		if len(fields) != 0 && fields[0] == "Bearer" {
+			access_token = fields[1]
+		} else {
+			access_token = cookie
+		}
+
+		if access_token == "" {
+			Fail(ctx, apperrors.New(apperrors.Unauthenticated))
+			return
+		}
+
+		claims, err := ValidateTokenClaims(ctx.Request.Context(), access_token, config.AccessTokenPublicKey)
+		if err != nil {
+			Fail(ctx, apperrors.Wrap(apperrors.InvalidToken, err))
+			return
+		}
+
+		// the registration session is only good for this service's registration routes
+		if TokenScope(claims) != "" {
+			Fail(ctx, apperrors.New(apperrors.SecurityKeyRequired))
+			return
+		}
+
+		user, err := FindUserById(ctx.Request.Context(), TokenTenant(config, claims), fmt.Sprint(claims["sub"]))
+		if err != nil {
+			Fail(ctx, apperrors.Wrap(apperrors.SessionUserNotFound, err))
+			return
+		}
+
+		if user.Disabled {
+			Fail(ctx, apperrors.New(apperrors.AccountDisabled))
+			return
+		}
+
+		if !bindTenant(ctx, user) {
+			return
+		}
+
+		if !setImpersonator(ctx, claims) {
+			return
+		}
+
+		if !setCurrentUser(ctx, user) {
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// setImpersonator stores the admin named in an impersonation token's RFC 8693 "act" claim. It
+// reports false after aborting the request when that admin no longer exists, has been disabled
+// or has lost the admin rights of the organization.
+func setImpersonator(ctx *gin.Context, claims jwt.MapClaims) bool {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return true
+	}
+
+	impersonator, err := FindUserById(ctx.Request.Context(), TokenTenant(AppConfig(ctx), act), fmt.Sprint(act["sub"]))
+	if err != nil {
+		Fail(ctx, apperrors.Wrap(apperrors.ImpersonatorNotFound, err))
+		return false
+	}
+
+	if impersonator.Disabled || !IsTenantAdmin(ctx, impersonator) {
+		Fail(ctx, apperrors.New(apperrors.ImpersonatorNotAdmin))
+		return false
+	}
+
+	ctx.Set("impersonator", impersonator)
+	addLogFields(ctx, "impersonator_id", impersonator.Id)
+	return true
+}
+
+// DenyImpersonation blocks sensitive actions for sessions opened through admin impersonation.
+func DenyImpersonation() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, exists := ctx.Get("impersonator"); exists {
+			Fail(ctx, apperrors.New(apperrors.ImpersonationNotAllowed))
+			return
+		}
+		ctx.Next()
+	}
+}
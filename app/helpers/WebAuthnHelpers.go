@@ -0,0 +1,179 @@
+package helpers
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+	WebAuthnSecondFactor = "mfa"
+)
+
+// ScopeClaim limits what an access token can be used for. Tokens without it are full sessions.
+const ScopeClaim = "scope"
+
+// RegistrationScope is the scope of the session issued to users whose level requires a security
+// key they have not registered yet. It only reaches the key registration routes.
+const RegistrationScope = "webauthn_registration"
+
+// WebAuthnUser adapts a users document and its stored credentials to webauthn.User.
+type WebAuthnUser struct {
+	User        *models.DBResponse
+	Credentials []models.WebAuthnCredential
+}
+
+func (u *WebAuthnUser) WebAuthnID() []byte {
+	return []byte(u.User.Id)
+}
+
+func (u *WebAuthnUser) WebAuthnName() string {
+	return u.User.Email
+}
+
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	return u.User.Name
+}
+
+func (u *WebAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Credentials))
+	for _, credential := range u.Credentials {
+		credentials = append(credentials, credential.Credential)
+	}
+	return credentials
+}
+
+func NewWebAuthn(config config.Config) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          config.WebAuthnRPID,
+		RPDisplayName: config.WebAuthnRPName,
+		RPOrigins:     strings.Split(config.WebAuthnRPOrigins, ","),
+	})
+}
+
+// WebAuthnRequired reports whether the user's level must use a security key or passkey.
+func WebAuthnRequired(config config.Config, user *models.DBResponse) bool {
+	for _, level := range strings.Split(config.WebAuthnRequiredLevels, ",") {
+		if level = strings.TrimSpace(level); level != "" && level == user.Level {
+			return true
+		}
+	}
+	return false
+}
+
+func EncodeCredentialId(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+func FindWebAuthnUser(ctx context.Context, user *models.DBResponse) (*WebAuthnUser, error) {
+	var credentialCollection *mongo.Collection = config.GetCollection(config.DB, config.WebAuthnCredentialsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	results, err := credentialCollection.Find(ctx, bson.M{"user_id": user.Id})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	webAuthnUser := &WebAuthnUser{User: user, Credentials: []models.WebAuthnCredential{}}
+	if err := results.All(ctx, &webAuthnUser.Credentials); err != nil {
+		return nil, err
+	}
+
+	return webAuthnUser, nil
+}
+
+func SaveWebAuthnCredential(ctx context.Context, userId string, name string, credential *webauthn.Credential) (*models.WebAuthnCredential, error) {
+	var credentialCollection *mongo.Collection = config.GetCollection(config.DB, config.WebAuthnCredentialsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	stored := models.WebAuthnCredential{
+		ID:           uuid.Must(uuid.NewRandom()).String(),
+		UserId:       userId,
+		Name:         name,
+		CredentialId: EncodeCredentialId(credential.ID),
+		Credential:   *credential,
+		CreatedAt:    time.Now(),
+	}
+
+	if _, err := credentialCollection.InsertOne(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// TouchWebAuthnCredential stores the new signature counter after a successful assertion.
+func TouchWebAuthnCredential(ctx context.Context, credential *webauthn.Credential) error {
+	var credentialCollection *mongo.Collection = config.GetCollection(config.DB, config.WebAuthnCredentialsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := credentialCollection.UpdateOne(ctx,
+		bson.M{"credential_id": EncodeCredentialId(credential.ID)},
+		bson.M{"$set": bson.M{"credential.authenticator": credential.Authenticator, "last_used_at": time.Now()}})
+	return err
+}
+
+func DeleteWebAuthnCredential(ctx context.Context, userId string, id string) (bool, error) {
+	var credentialCollection *mongo.Collection = config.GetCollection(config.DB, config.WebAuthnCredentialsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := credentialCollection.DeleteOne(ctx, bson.M{"id": id, "user_id": userId})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func SaveWebAuthnSession(ctx context.Context, userId string, purpose string, data *webauthn.SessionData) (string, error) {
+	var sessionCollection *mongo.Collection = config.GetCollection(config.DB, config.WebAuthnSessionsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	session := models.WebAuthnSession{
+		ID:        uuid.Must(uuid.NewRandom()).String(),
+		UserId:    userId,
+		Purpose:   purpose,
+		Data:      *data,
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}
+
+	if _, err := sessionCollection.InsertOne(ctx, session); err != nil {
+		return "", err
+	}
+
+	return session.ID, nil
+}
+
+// TakeWebAuthnSession loads and deletes a ceremony session so its challenge can only be answered once.
+func TakeWebAuthnSession(ctx context.Context, id string, purposes ...string) (*models.WebAuthnSession, error) {
+	var sessionCollection *mongo.Collection = config.GetCollection(config.DB, config.WebAuthnSessionsCollection)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var session models.WebAuthnSession
+	query := bson.M{"id": id, "purpose": bson.M{"$in": purposes}, "expires_at": bson.M{"$gt": time.Now()}}
+	if err := sessionCollection.FindOneAndDelete(ctx, query).Decode(&session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
@@ -0,0 +1,215 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/helpers/webauthntest"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testOrigin = "https://id.example.com"
+
+func testWebAuthn(t *testing.T) *webauthn.WebAuthn {
+	web, err := NewWebAuthn(config.Config{WebAuthnRPID: "id.example.com", WebAuthnRPName: "Example", WebAuthnRPOrigins: testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return web
+}
+
+func newSoftwareAuthenticator(t *testing.T) *webauthntest.Authenticator {
+	return webauthntest.New(t, "id.example.com", testOrigin)
+}
+
+func ceremonyRequest(body []byte) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+// storedSession round-trips ceremony data through BSON the way SaveWebAuthnSession and
+// TakeWebAuthnSession do.
+func storedSession(t *testing.T, data *webauthn.SessionData) webauthn.SessionData {
+	raw, err := bson.Marshal(models.WebAuthnSession{ID: "session", Purpose: WebAuthnRegistration, Data: *data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session models.WebAuthnSession
+	if err := bson.Unmarshal(raw, &session); err != nil {
+		t.Fatal(err)
+	}
+	return session.Data
+}
+
+// storedCredential round-trips a credential through BSON the way SaveWebAuthnCredential and
+// FindWebAuthnUser do.
+func storedCredential(t *testing.T, credential *webauthn.Credential) models.WebAuthnCredential {
+	raw, err := bson.Marshal(models.WebAuthnCredential{ID: "credential", CredentialId: EncodeCredentialId(credential.ID), Credential: *credential})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored models.WebAuthnCredential
+	if err := bson.Unmarshal(raw, &stored); err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+// register runs a registration ceremony and returns the user holding the new credential.
+func register(t *testing.T, web *webauthn.WebAuthn, authenticator *webauthntest.Authenticator) *WebAuthnUser {
+	user := &WebAuthnUser{User: &models.DBResponse{Id: "user-1", Email: "ana@example.com", Name: "Ana"}}
+
+	creation, session, err := web.BeginRegistration(user, webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := web.FinishRegistration(user, storedSession(t, session), ceremonyRequest(authenticator.Create(t, creation)))
+	if err != nil {
+		t.Fatalf("FinishRegistration() failed: %v", err)
+	}
+	if !bytes.Equal(credential.ID, authenticator.CredentialId) {
+		t.Fatalf("credential id = %x, want %x", credential.ID, authenticator.CredentialId)
+	}
+
+	user.Credentials = append(user.Credentials, storedCredential(t, credential))
+	return user
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	web := testWebAuthn(t)
+	authenticator := newSoftwareAuthenticator(t)
+	user := register(t, web, authenticator)
+
+	if got := string(authenticator.UserHandle); got != "user-1" {
+		t.Errorf("user handle = %q, want the user id", got)
+	}
+
+	for i := 1; i <= 2; i++ {
+		assertion, session, err := web.BeginLogin(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		credential, err := web.FinishLogin(user, storedSession(t, session), ceremonyRequest(authenticator.Get(t, assertion)))
+		if err != nil {
+			t.Fatalf("login %d: FinishLogin() failed: %v", i, err)
+		}
+		if credential.Authenticator.CloneWarning || credential.Authenticator.SignCount != uint32(i) {
+			t.Errorf("login %d: authenticator = %+v, want counter %d without a clone warning", i, credential.Authenticator, i)
+		}
+		user.Credentials[0].Credential.Authenticator = credential.Authenticator
+	}
+}
+
+func TestWebAuthnDiscoverableLogin(t *testing.T) {
+	web := testWebAuthn(t)
+	authenticator := newSoftwareAuthenticator(t)
+	user := register(t, web, authenticator)
+
+	assertion, session, err := web.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(authenticator.Get(t, assertion)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := web.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		if string(userHandle) != user.User.Id {
+			t.Errorf("user handle = %q, want %q", userHandle, user.User.Id)
+		}
+		return user, nil
+	}, storedSession(t, session), parsed)
+	if err != nil {
+		t.Fatalf("ValidateDiscoverableLogin() failed: %v", err)
+	}
+	if !bytes.Equal(credential.ID, authenticator.CredentialId) {
+		t.Errorf("credential id = %x, want %x", credential.ID, authenticator.CredentialId)
+	}
+}
+
+func TestWebAuthnLoginRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(a *webauthntest.Authenticator, assertion *protocol.CredentialAssertion)
+	}{
+		{"another key", func(a *webauthntest.Authenticator, _ *protocol.CredentialAssertion) {
+			a.Key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}},
+		{"another origin", func(a *webauthntest.Authenticator, _ *protocol.CredentialAssertion) {
+			a.Origin = "https://phishing.example.net"
+		}},
+		{"another relying party", func(a *webauthntest.Authenticator, _ *protocol.CredentialAssertion) {
+			a.RPID = "phishing.example.net"
+		}},
+		{"another challenge", func(_ *webauthntest.Authenticator, assertion *protocol.CredentialAssertion) {
+			assertion.Response.Challenge = protocol.URLEncodedBase64("not the challenge")
+		}},
+		{"unknown credential", func(a *webauthntest.Authenticator, _ *protocol.CredentialAssertion) {
+			a.CredentialId = []byte("unknown")
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			web := testWebAuthn(t)
+			authenticator := newSoftwareAuthenticator(t)
+			user := register(t, web, authenticator)
+
+			assertion, session, err := web.BeginLogin(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.tamper(authenticator, assertion)
+
+			if _, err := web.FinishLogin(user, *session, ceremonyRequest(authenticator.Get(t, assertion))); err == nil {
+				t.Error("FinishLogin() accepted the assertion")
+			}
+		})
+	}
+}
+
+func TestWebAuthnClonedAuthenticator(t *testing.T) {
+	web := testWebAuthn(t)
+	authenticator := newSoftwareAuthenticator(t)
+	user := register(t, web, authenticator)
+	user.Credentials[0].Credential.Authenticator.SignCount = 10
+
+	assertion, session, err := web.BeginLogin(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := web.FinishLogin(user, *session, ceremonyRequest(authenticator.Get(t, assertion)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !credential.Authenticator.CloneWarning {
+		t.Error("a counter that went backwards must raise the clone warning")
+	}
+}
+
+func TestWebAuthnRequired(t *testing.T) {
+	appConfig := config.Config{WebAuthnRequiredLevels: "admin, superadmin"}
+
+	for level, want := range map[string]bool{"admin": true, "superadmin": true, "member": false, "": false} {
+		if got := WebAuthnRequired(appConfig, &models.DBResponse{Level: level}); got != want {
+			t.Errorf("WebAuthnRequired(%q) = %v, want %v", level, got, want)
+		}
+	}
+
+	if WebAuthnRequired(config.Config{}, &models.DBResponse{Level: ""}) {
+		t.Error("no level may require a security key when WEBAUTHN_REQUIRED_LEVELS is empty")
+	}
+}
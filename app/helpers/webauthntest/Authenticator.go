@@ -0,0 +1,126 @@
+// Package webauthntest provides a security key kept in memory, for tests of the WebAuthn ceremonies.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// Authenticator holds one ES256 credential with a signature counter. Tests tamper with its fields
+// to produce assertions a relying party must reject.
+type Authenticator struct {
+	RPID         string
+	Origin       string
+	Key          *ecdsa.PrivateKey
+	CredentialId []byte
+	UserHandle   []byte
+	Counter      uint32
+}
+
+func New(t testing.TB, rpID string, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialId := make([]byte, 16)
+	if _, err := rand.Read(credentialId); err != nil {
+		t.Fatal(err)
+	}
+	return &Authenticator{RPID: rpID, Origin: origin, Key: key, CredentialId: credentialId}
+}
+
+func (a *Authenticator) authenticatorData(flags protocol.AuthenticatorFlags, attested []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIdHash[:], byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.Counter)
+	return append(data, attested...)
+}
+
+func (a *Authenticator) clientData(t testing.TB, ceremony protocol.CeremonyType, challenge string) []byte {
+	clientData, err := json.Marshal(map[string]string{"type": string(ceremony), "challenge": challenge, "origin": a.Origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientData
+}
+
+// Create answers navigator.credentials.create with a "none" attestation.
+func (a *Authenticator) Create(t testing.TB, options *protocol.CredentialCreation) []byte {
+	a.UserHandle = options.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         1, // P-256
+		XCoord:        a.Key.X.FillBytes(make([]byte, 32)),
+		YCoord:        a.Key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.CredentialId)))
+	attested = append(append(attested, a.CredentialId...), publicKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified|protocol.FlagAttestedCredentialData, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    encode(a.clientData(t, protocol.CreateCeremony, options.Response.Challenge.String())),
+		"attestationObject": encode(attestationObject),
+	})
+}
+
+// Get answers navigator.credentials.get, signing with the next counter value.
+func (a *Authenticator) Get(t testing.TB, options *protocol.CredentialAssertion) []byte {
+	a.Counter++
+	authenticatorData := a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified, nil)
+	clientData := a.clientData(t, protocol.AssertCeremony, options.Response.Challenge.String())
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authenticatorData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.Key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.response(t, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authenticatorData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.UserHandle),
+	})
+}
+
+func (a *Authenticator) response(t testing.TB, response map[string]string) []byte {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       encode(a.CredentialId),
+		"rawId":    encode(a.CredentialId),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,78 @@
+package i18n
+
+import "github.com/RadenAbror/UserManagement/app/apperrors"
+
+// english is the catalogue for en.
+var english = catalogue{
+	errors: map[apperrors.Code]string{
+		apperrors.InvalidRequest:   "The request body is malformed!",
+		apperrors.ValidationFailed: "The submitted data is invalid!",
+		apperrors.InternalError:    "An internal server error occurred",
+		apperrors.UpstreamError:    "An external service could not be reached!",
+
+		apperrors.Unauthenticated:        "You are not logged in!",
+		apperrors.InvalidToken:           "The token is invalid or has expired!",
+		apperrors.SessionUserNotFound:    "The user belonging to this session no longer exists!",
+		apperrors.ImpersonatorNotFound:   "The admin impersonating this user no longer exists!",
+		apperrors.ImpersonatorNotAdmin:   "The admin impersonating this user no longer has admin rights!",
+		apperrors.InvalidApiKey:          "The API key is invalid or has expired!",
+		apperrors.UnknownEmail:           "This email is not registered!",
+		apperrors.InvalidPassword:        "Wrong password!",
+		apperrors.InvalidCredentials:     "Wrong email or current password!",
+		apperrors.AccountDisabled:        "Your account has been disabled!",
+		apperrors.PasswordExpired:        "Your password has expired, please change it",
+		apperrors.UnknownProvider:        "Unknown login provider!",
+		apperrors.InvalidLoginSession:    "The login session is invalid, please try again!",
+		apperrors.ExternalLoginFailed:    "Login through the external provider failed!",
+		apperrors.UnverifiedEmail:        "The email of the external account is not verified!",
+		apperrors.InvalidWebAuthnSession: "The WebAuthn session is invalid or has expired!",
+		apperrors.WebAuthnFailed:         "The security key could not be verified!",
+		apperrors.SecurityKeyRejected:    "The security key could not be registered!",
+		apperrors.NoSecurityKey:          "This account has no security key yet!",
+		apperrors.ClonedSecurityKey:      "The security key was detected as a clone!",
+		apperrors.SecurityKeyRequired:    "Register a security key before using this account!",
+
+		apperrors.Forbidden:               "You are not allowed to access this module!",
+		apperrors.ApiKeyScope:             "The API key does not have the required scope!",
+		apperrors.ApiKeyNotAllowed:        "This action is not available with an API key!",
+		apperrors.ImpersonationNotAllowed: "This action is not available while impersonating a user!",
+		apperrors.SelfImpersonation:       "You cannot impersonate yourself!",
+		apperrors.PrivilegedImpersonation: "You cannot impersonate an admin with the same or higher rights!",
+		apperrors.WrongOrganization:       "You do not belong to this organization!",
+		apperrors.OrganizationDisabled:    "This organization has been disabled!",
+
+		apperrors.PasswordMismatch: "The password confirmation does not match the password",
+		apperrors.PasswordPolicy:   "The password does not meet the password policy",
+
+		apperrors.UserNotFound:            "User not found!",
+		apperrors.LevelNotFound:           "Level not found!",
+		apperrors.GroupNotFound:           "Group not found!",
+		apperrors.ParentGroupNotFound:     "Parent group not found!",
+		apperrors.UnknownGroupManager:     "A group manager is not a registered user!",
+		apperrors.MembershipNotFound:      "Membership not found!",
+		apperrors.InvalidMembershipPeriod: "valid_until must be after valid_from!",
+		apperrors.OrganizationNotFound:    "Organization not found!",
+		apperrors.ApiKeyNotFound:          "API key not found!",
+		apperrors.CredentialNotFound:      "Security key not found!",
+
+		apperrors.EmailTaken:           "This email address is already registered",
+		apperrors.SlugTaken:            "The organization slug is already taken!",
+		apperrors.GroupCycle:           "A group cannot be moved below itself or one of its descendants!",
+		apperrors.GroupHasChildren:     "The group still has subgroups!",
+		apperrors.OrganizationHasUsers: "The organization still has users!",
+		apperrors.DefaultOrganization:  "The default organization cannot be disabled or deleted!",
+	},
+	passwordRules: map[string]string{
+		"too_short":              "The password must be at least %d characters long",
+		"missing_uppercase":      "The password must contain an uppercase letter",
+		"missing_lowercase":      "The password must contain a lowercase letter",
+		"missing_digit":          "The password must contain a digit",
+		"missing_symbol":         "The password must contain a symbol",
+		"contains_personal_info": "The password must not contain your email address",
+		"banned_word":            "The password must not contain the word %q",
+		"breached":               "This password appeared in a data breach, choose another one",
+		"reused":                 "The password must differ from your last %d passwords",
+		"check_unavailable":      "The password could not be checked against the password lists, try again later",
+	},
+	fieldType: "%s must be of type %s",
+}
@@ -0,0 +1,78 @@
+package i18n
+
+import "github.com/RadenAbror/UserManagement/app/apperrors"
+
+// indonesian is the catalogue for id, the default locale.
+var indonesian = catalogue{
+	errors: map[apperrors.Code]string{
+		apperrors.InvalidRequest:   "Format request tidak valid!",
+		apperrors.ValidationFailed: "Data yang dikirim tidak valid!",
+		apperrors.InternalError:    "Terjadi kesalahan pada server",
+		apperrors.UpstreamError:    "Layanan eksternal tidak dapat dihubungi!",
+
+		apperrors.Unauthenticated:        "Anda belum login!",
+		apperrors.InvalidToken:           "Token tidak valid atau sudah kedaluwarsa!",
+		apperrors.SessionUserNotFound:    "Pengguna pemilik sesi ini sudah tidak ada!",
+		apperrors.ImpersonatorNotFound:   "Admin yang mengimpersonasi pengguna ini sudah tidak ada!",
+		apperrors.ImpersonatorNotAdmin:   "Admin yang mengimpersonasi pengguna ini sudah tidak memiliki hak admin!",
+		apperrors.InvalidApiKey:          "API key tidak valid atau sudah kedaluwarsa!",
+		apperrors.UnknownEmail:           "Email tidak terdaftar!",
+		apperrors.InvalidPassword:        "Password salah!",
+		apperrors.InvalidCredentials:     "Email atau password lama salah!",
+		apperrors.AccountDisabled:        "Akun Anda telah dinonaktifkan!",
+		apperrors.PasswordExpired:        "Password Anda telah kedaluwarsa, silakan ganti password",
+		apperrors.UnknownProvider:        "Provider login tidak dikenal!",
+		apperrors.InvalidLoginSession:    "Sesi login tidak valid, silakan ulangi!",
+		apperrors.ExternalLoginFailed:    "Login melalui provider eksternal gagal!",
+		apperrors.UnverifiedEmail:        "Email akun eksternal belum terverifikasi!",
+		apperrors.InvalidWebAuthnSession: "Sesi WebAuthn tidak valid atau kedaluwarsa!",
+		apperrors.WebAuthnFailed:         "Verifikasi security key gagal!",
+		apperrors.SecurityKeyRejected:    "Security key tidak dapat didaftarkan!",
+		apperrors.NoSecurityKey:          "Akun ini belum memiliki security key!",
+		apperrors.ClonedSecurityKey:      "Security key terdeteksi sebagai salinan!",
+		apperrors.SecurityKeyRequired:    "Daftarkan security key sebelum menggunakan akun ini!",
+
+		apperrors.Forbidden:               "Anda tidak diijinkan mengkases modul ini!",
+		apperrors.ApiKeyScope:             "API key tidak memiliki scope yang dibutuhkan!",
+		apperrors.ApiKeyNotAllowed:        "Aksi ini tidak dapat dilakukan dengan API key!",
+		apperrors.ImpersonationNotAllowed: "Aksi ini tidak dapat dilakukan selama impersonasi!",
+		apperrors.SelfImpersonation:       "Anda tidak dapat mengimpersonasi diri sendiri!",
+		apperrors.PrivilegedImpersonation: "Anda tidak dapat mengimpersonasi admin dengan hak akses yang sama atau lebih tinggi!",
+		apperrors.WrongOrganization:       "Anda bukan anggota organisasi ini!",
+		apperrors.OrganizationDisabled:    "Organisasi ini telah dinonaktifkan!",
+
+		apperrors.PasswordMismatch: "Konfirmasi Password tidak sama dengan Password",
+		apperrors.PasswordPolicy:   "Password tidak memenuhi kebijakan",
+
+		apperrors.UserNotFound:            "Pengguna tidak ditemukan!",
+		apperrors.LevelNotFound:           "Level tidak ditemukan!",
+		apperrors.GroupNotFound:           "Grup tidak ditemukan!",
+		apperrors.ParentGroupNotFound:     "Induk grup tidak ditemukan!",
+		apperrors.UnknownGroupManager:     "Manajer grup tidak terdaftar!",
+		apperrors.MembershipNotFound:      "Keanggotaan tidak ditemukan!",
+		apperrors.InvalidMembershipPeriod: "valid_until harus setelah valid_from!",
+		apperrors.OrganizationNotFound:    "Organisasi tidak ditemukan!",
+		apperrors.ApiKeyNotFound:          "API key tidak ditemukan!",
+		apperrors.CredentialNotFound:      "Security key tidak ditemukan!",
+
+		apperrors.EmailTaken:           "Alamat email sudah terdaftar",
+		apperrors.SlugTaken:            "Slug organisasi sudah digunakan!",
+		apperrors.GroupCycle:           "Grup tidak dapat dipindahkan ke bawah dirinya sendiri atau turunannya!",
+		apperrors.GroupHasChildren:     "Grup masih memiliki sub grup!",
+		apperrors.OrganizationHasUsers: "Organisasi masih memiliki pengguna!",
+		apperrors.DefaultOrganization:  "Organisasi default tidak dapat dinonaktifkan atau dihapus!",
+	},
+	passwordRules: map[string]string{
+		"too_short":              "Password minimal %d karakter",
+		"missing_uppercase":      "Password harus mengandung huruf besar",
+		"missing_lowercase":      "Password harus mengandung huruf kecil",
+		"missing_digit":          "Password harus mengandung angka",
+		"missing_symbol":         "Password harus mengandung simbol",
+		"contains_personal_info": "Password tidak boleh mengandung alamat email",
+		"banned_word":            "Password tidak boleh mengandung kata %q",
+		"breached":               "Password ini pernah bocor dalam insiden keamanan, gunakan password lain",
+		"reused":                 "Password tidak boleh sama dengan %d password terakhir",
+		"check_unavailable":      "Password tidak dapat diperiksa terhadap daftar password, coba lagi nanti",
+	},
+	fieldType: "%s harus bertipe %s",
+}
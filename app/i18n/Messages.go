@@ -0,0 +1,129 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"golang.org/x/text/language"
+)
+
+// Locale is a language the API answers in.
+type Locale string
+
+const (
+	Indonesian Locale = "id"
+	English    Locale = "en"
+)
+
+// catalogue holds the messages of one locale. passwordRules and fieldType are fmt formats.
+type catalogue struct {
+	errors        map[apperrors.Code]string
+	passwordRules map[string]string
+	fieldType     string
+}
+
+var catalogues = map[Locale]catalogue{
+	Indonesian: indonesian,
+	English:    english,
+}
+
+// supported is in the same order as the tags of matcher.
+var supported = []Locale{Indonesian, English}
+
+var matcher = language.NewMatcher([]language.Tag{language.Indonesian, language.English})
+
+// Parse returns the supported locale named by value, e.g. "en" or "en-US".
+func Parse(value string) (Locale, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(value)), "-")
+	for _, locale := range supported {
+		if string(locale) == base {
+			return locale, true
+		}
+	}
+	return "", false
+}
+
+// Match picks the supported locale that best fits an Accept-Language header, or fallback when
+// the header names none of them.
+func Match(acceptLanguage string, fallback Locale) Locale {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return fallback
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return fallback
+	}
+	return supported[index]
+}
+
+// Message returns the message for code, in Indonesian when locale has none.
+func Message(locale Locale, code apperrors.Code) string {
+	if message, found := catalogues[locale].errors[code]; found {
+		return message
+	}
+	if message, found := indonesian.errors[code]; found {
+		return message
+	}
+	return string(code)
+}
+
+// Details returns the details of err with their messages in locale: the failed rule of every
+// invalid field and every violated password rule.
+func Details(locale Locale, err *apperrors.Error) interface{} {
+	catalogue, found := catalogues[locale]
+	if !found {
+		catalogue = indonesian
+	}
+
+	switch details := err.Details.(type) {
+	case []apperrors.FieldError:
+		translated := make([]apperrors.FieldError, len(details))
+		for i, field := range details {
+			if cause := field.Cause(); cause != nil {
+				field.Message = cause.Translate(translator(locale))
+			} else if field.Rule == "type" {
+				field.Message = fmt.Sprintf(catalogue.fieldType, field.Field, field.Param)
+			}
+			translated[i] = field
+		}
+		return translated
+	case []models.PasswordViolation:
+		translated := make([]models.PasswordViolation, len(details))
+		for i, violation := range details {
+			if format, found := catalogue.passwordRules[violation.Code]; found {
+				violation.Message = fmt.Sprintf(format, violation.Args...)
+			}
+			translated[i] = violation
+		}
+		return translated
+	}
+	return err.Details
+}
+
+// Check reports error codes and password rules missing from a catalogue, so an untranslated
+// message is found at startup.
+func Check() error {
+	var missing []string
+	for _, locale := range supported {
+		for _, code := range apperrors.Codes() {
+			if _, found := catalogues[locale].errors[code]; !found {
+				missing = append(missing, fmt.Sprintf("%s: %s", locale, code))
+			}
+		}
+		for rule := range indonesian.passwordRules {
+			if _, found := catalogues[locale].passwordRules[rule]; !found {
+				missing = append(missing, fmt.Sprintf("%s: password rule %s", locale, rule))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New("missing translations: " + strings.Join(missing, ", "))
+	}
+	return nil
+}
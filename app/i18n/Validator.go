@@ -0,0 +1,28 @@
+package i18n
+
+import (
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	id_translations "github.com/go-playground/validator/v10/translations/id"
+)
+
+var universal = ut.New(id.New(), id.New(), en.New())
+
+func translator(locale Locale) ut.Translator {
+	trans, found := universal.GetTranslator(string(locale))
+	if !found {
+		trans, _ = universal.GetTranslator(string(Indonesian))
+	}
+	return trans
+}
+
+// RegisterValidator adds the id and en messages of the built-in rules to validate.
+func RegisterValidator(validate *validator.Validate) error {
+	if err := id_translations.RegisterDefaultTranslations(validate, translator(Indonesian)); err != nil {
+		return err
+	}
+	return en_translations.RegisterDefaultTranslations(validate, translator(English))
+}
@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned change to the database. Versions are applied in ascending order and
+// never edited once released; fix a bad migration by adding a new one.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// AppliedMigration is the record kept in schema_migrations for each version.
+type AppliedMigration struct {
+	Version     int        `json:"version" bson:"_id"`
+	Description string     `json:"description" bson:"description"`
+	StartedAt   time.Time  `json:"started_at" bson:"started_at"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty" bson:"applied_at,omitempty"`
+}
+
+// All returns the registered migrations sorted by version.
+func All() []Migration {
+	all := append([]Migration(nil), registered...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// Run applies every migration that has no record in schema_migrations yet. The record is
+// inserted before the migration runs, so when several instances start together only the one
+// whose insert succeeds applies it. A migration that fails leaves its record without applied_at
+// and stops the run; remove the record once the cause is fixed to retry it.
+func Run(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	migrationCollection := collection(db, config.SchemaMigrationsCollection)
+	var applied []Migration
+
+	for _, migration := range All() {
+		record := AppliedMigration{Version: migration.Version, Description: migration.Description, StartedAt: time.Now()}
+
+		_, err := migrationCollection.InsertOne(ctx, record)
+		if mongo.IsDuplicateKeyError(err) {
+			var existing AppliedMigration
+			if err := migrationCollection.FindOne(ctx, bson.M{"_id": migration.Version}).Decode(&existing); err != nil {
+				return applied, err
+			}
+			if existing.AppliedAt == nil {
+				return applied, fmt.Errorf("migration %d (%s) was started at %s but never finished", migration.Version, migration.Description, existing.StartedAt.Format(time.RFC3339))
+			}
+			continue
+		}
+		if err != nil {
+			return applied, err
+		}
+
+		if err := migration.Up(ctx, db); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		_, err = migrationCollection.UpdateOne(ctx, bson.M{"_id": migration.Version}, bson.M{"$set": bson.M{"applied_at": time.Now()}})
+		if err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Status lists the records in schema_migrations in version order.
+func Status(ctx context.Context, db *mongo.Database) ([]AppliedMigration, error) {
+	results, err := collection(db, config.SchemaMigrationsCollection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []AppliedMigration
+	if err := results.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ensureIndexes creates the given indexes on one collection. Creating an index that already exists
+// with the same options is a no-op in MongoDB, so migrations stay safe to re-run by hand.
+func ensureIndexes(ctx context.Context, db *mongo.Database, name string, indexes ...mongo.IndexModel) error {
+	if _, err := collection(db, name).Indexes().CreateMany(ctx, indexes); err != nil {
+		return fmt.Errorf("creating indexes on %s: %w", name, err)
+	}
+	return nil
+}
+
+// dropIndex removes an index by name, treating one that does not exist as already dropped.
+func dropIndex(ctx context.Context, db *mongo.Database, name string, index string) error {
+	if _, err := collection(db, name).Indexes().DropOne(ctx, index); err != nil {
+		var commandErr mongo.CommandError
+		if !errors.As(err, &commandErr) || commandErr.Name != "IndexNotFound" {
+			return fmt.Errorf("dropping %s index %s: %w", name, index, err)
+		}
+	}
+	return nil
+}
+
+// collection resolves a logical collection name through COLLECTION_PREFIX and COLLECTION_<NAME>.
+func collection(db *mongo.Database, name string) *mongo.Collection {
+	return db.Collection(config.Get().CollectionName(name))
+}
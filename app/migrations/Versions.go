@@ -0,0 +1,231 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// caseInsensitive matches strings that differ only in case. The users.email indexes of migrations 2
+// and 5 used it until migration 10 replaced them, since queries without it cannot use them.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+var registered = []Migration{
+	{
+		Version:     1,
+		Description: "normalise stored email addresses",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := collection(db, config.UsersCollection).UpdateMany(ctx, bson.M{"email": bson.M{"$type": "string"}}, mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"email": bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}}}}},
+			})
+			if err != nil {
+				return err
+			}
+
+			// report duplicates up front, the unique index in the next migration would fail on them anyway
+			results, err := collection(db, config.UsersCollection).Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{"_id": "$email", "count": bson.M{"$sum": 1}}}},
+				{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+			})
+			if err != nil {
+				return err
+			}
+
+			var duplicates []bson.M
+			if err := results.All(ctx, &duplicates); err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				emails := make([]interface{}, len(duplicates))
+				for i, duplicate := range duplicates {
+					emails[i] = duplicate["_id"]
+				}
+				return fmt.Errorf("users share these email addresses and must be merged first: %v", emails)
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "index users",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return ensureIndexes(ctx, db, config.UsersCollection,
+				mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetCollation(caseInsensitive)},
+				mongo.IndexModel{Keys: bson.D{{Key: "level", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "group", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "external_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}}, Options: options.Index().SetSparse(true)},
+			)
+		},
+	},
+	{
+		Version:     3,
+		Description: "unique id on levels, groups and per-user collections",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			unique := mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)}
+			byUser := mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}}
+
+			if err := ensureIndexes(ctx, db, config.LevelsCollection, unique); err != nil {
+				return err
+			}
+			if err := ensureIndexes(ctx, db, config.GroupsCollection, unique); err != nil {
+				return err
+			}
+			if err := ensureIndexes(ctx, db, config.ApiKeysCollection, unique, byUser,
+				mongo.IndexModel{Keys: bson.D{{Key: "prefix", Value: 1}}, Options: options.Index().SetUnique(true)},
+			); err != nil {
+				return err
+			}
+			if err := ensureIndexes(ctx, db, config.WebAuthnCredentialsCollection, unique, byUser,
+				mongo.IndexModel{Keys: bson.D{{Key: "credential_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			); err != nil {
+				return err
+			}
+			return ensureIndexes(ctx, db, config.ImpersonationLogsCollection, unique,
+				mongo.IndexModel{Keys: bson.D{{Key: "actor_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "subject_id", Value: 1}}},
+			)
+		},
+	},
+	{
+		Version:     4,
+		Description: "expire revoked tokens and WebAuthn sessions",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			expire := mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}
+
+			if err := ensureIndexes(ctx, db, config.RevokedTokensCollection, expire,
+				mongo.IndexModel{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+			); err != nil {
+				return err
+			}
+			return ensureIndexes(ctx, db, config.WebAuthnSessionsCollection, expire,
+				mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			)
+		},
+	},
+	{
+		Version:     5,
+		Description: "move existing data into the default organization and make emails unique per organization",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			appConfig := config.Get()
+			tenantId := appConfig.DefaultTenantId()
+			now := time.Now()
+
+			if err := ensureIndexes(ctx, db, config.OrganizationsCollection,
+				mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+			); err != nil {
+				return err
+			}
+
+			_, err := collection(db, config.OrganizationsCollection).UpdateOne(ctx,
+				bson.M{"id": tenantId},
+				bson.M{"$setOnInsert": models.Organization{
+					ID:         tenantId,
+					Name:       tenantId,
+					Slug:       tenantId,
+					AdminLevel: appConfig.DefaultAdminLevel(),
+					CreatedAt:  now,
+					UpdatedAt:  now,
+				}},
+				options.Update().SetUpsert(true))
+			if err != nil {
+				return err
+			}
+
+			untenanted := bson.M{"tenant_id": bson.M{"$in": bson.A{nil, ""}}}
+			for _, name := range []string{config.UsersCollection, config.LevelsCollection, config.GroupsCollection, config.ApiKeysCollection, config.ImpersonationLogsCollection} {
+				if _, err := collection(db, name).UpdateMany(ctx, untenanted, bson.M{"$set": bson.M{"tenant_id": tenantId}}); err != nil {
+					return fmt.Errorf("assigning %s to %s: %w", name, tenantId, err)
+				}
+			}
+
+			// the same address may now be registered once in every organization
+			if err := dropIndex(ctx, db, config.UsersCollection, "email_1"); err != nil {
+				return err
+			}
+
+			if err := ensureIndexes(ctx, db, config.UsersCollection,
+				mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetCollation(caseInsensitive)},
+			); err != nil {
+				return err
+			}
+			for _, name := range []string{config.LevelsCollection, config.GroupsCollection} {
+				if err := ensureIndexes(ctx, db, name, mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     6,
+		Description: "index the group tree",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := collection(db, config.GroupsCollection).UpdateMany(ctx,
+				bson.M{"ancestors": bson.M{"$not": bson.M{"$type": "array"}}},
+				bson.M{"$set": bson.M{"ancestors": bson.A{}, "parent_id": ""}}); err != nil {
+				return err
+			}
+
+			return ensureIndexes(ctx, db, config.GroupsCollection,
+				mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "parent_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "ancestors", Value: 1}}},
+			)
+		},
+	},
+	{
+		Version:     7,
+		Description: "index group memberships",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return ensureIndexes(ctx, db, config.GroupMembershipsCollection,
+				mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "group_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}}},
+			)
+		},
+	},
+	{
+		Version:     8,
+		Description: "give API keys without an expiry the default one",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			expiresAt := time.Now().AddDate(0, 0, models.ApiKeyDefaultExpiryDays)
+			_, err := collection(db, config.ApiKeysCollection).UpdateMany(ctx,
+				bson.M{"expires_at": nil},
+				bson.M{"$set": bson.M{"expires_at": expiresAt}})
+			return err
+		},
+	},
+	{
+		Version:     9,
+		Description: "mark password hashes made from the sanitised password",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// every hash stored so far went through bluemonday first; the marker is cleared when the
+			// password is next hashed, so the sanitised form is only ever tried against these
+			_, err := collection(db, config.UsersCollection).UpdateMany(ctx,
+				bson.M{"password": bson.M{"$nin": bson.A{nil, ""}}},
+				bson.M{"$set": bson.M{"password_legacy_sanitized": true}})
+			return err
+		},
+	},
+	{
+		Version:     10,
+		Description: "index emails without a collation",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// emails are stored normalised, and lookups name no collation, so a plain index serves them
+			if err := dropIndex(ctx, db, config.UsersCollection, "tenant_id_1_email_1"); err != nil {
+				return err
+			}
+			return ensureIndexes(ctx, db, config.UsersCollection,
+				mongo.IndexModel{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+			)
+		},
+	},
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	ApiKeyScopeRead  = "read"
+	ApiKeyScopeWrite = "write"
+)
+
+// ApiKeyDefaultExpiryDays is the lifetime of keys created without expires_in_days. Every key expires.
+const ApiKeyDefaultExpiryDays = 90
+
+type ApiKey struct {
+	ID         string     `json:"id" bson:"id"`
+	UserId     string     `json:"-" bson:"user_id"`
+	TenantId   string     `json:"-" bson:"tenant_id"`
+	Name       string     `json:"name" bson:"name"`
+	Prefix     string     `json:"prefix" bson:"prefix"`
+	Hash       string     `json:"-" bson:"hash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at" bson:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+type ApiKeyCreate struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// ApiKeyCreated is returned once, when the key is minted; only its hash is stored.
+type ApiKeyCreated struct {
+	ApiKey
+	Key string `json:"key"`
+}
@@ -1,7 +1,52 @@
 package models
 
+import "time"
+
+// Group is a node of the organization chart. Ancestors holds the ids from the root down to
+// the parent, so a subtree is everything whose Ancestors contain the group id.
 type Group struct {
-	ID      string `json:"id,omitempty"`
-	Name    string `json:"name,omitempty" bson:"name" validate:"required"`
-	Acronym string `json:"acronym,omitempty" bson:"acronym" validate:"required"`
+	ID          string   `json:"id,omitempty"`
+	TenantId    string   `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Name        string   `json:"name,omitempty" bson:"name" validate:"required"`
+	Acronym     string   `json:"acronym,omitempty" bson:"acronym" validate:"required"`
+	ExternalId  string   `json:"external_id,omitempty" bson:"external_id,omitempty"`
+	ParentId    string   `json:"parent_id,omitempty" bson:"parent_id"`
+	Ancestors   []string `json:"ancestors,omitempty" bson:"ancestors"`
+	Managers    []string `json:"managers,omitempty" bson:"managers,omitempty" validate:"dive,required"`
+	Permissions []string `json:"permissions,omitempty" bson:"permissions,omitempty" validate:"dive,required"`
+}
+
+// GroupAccess is what a group grants, including what it inherits from its ancestors.
+type GroupAccess struct {
+	Managers    []string `json:"managers"`
+	Permissions []string `json:"permissions"`
+}
+
+// GroupMembership puts a user in a group besides their primary Group, with a role that only
+// applies inside that group. Without ValidFrom/ValidUntil the membership does not expire.
+type GroupMembership struct {
+	ID         string     `json:"id" bson:"id"`
+	TenantId   string     `json:"tenant_id,omitempty" bson:"tenant_id"`
+	UserId     string     `json:"user_id" bson:"user_id"`
+	GroupId    string     `json:"group_id" bson:"group_id"`
+	Role       string     `json:"role" bson:"role"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty" bson:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty" bson:"valid_until,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// Active reports whether the membership is in force at t.
+func (membership GroupMembership) Active(t time.Time) bool {
+	if membership.ValidFrom != nil && t.Before(*membership.ValidFrom) {
+		return false
+	}
+	return membership.ValidUntil == nil || t.Before(*membership.ValidUntil)
+}
+
+type GroupMembershipSave struct {
+	UserId     string     `json:"user_id" validate:"required"`
+	Role       string     `json:"role" validate:"omitempty,max=64"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
 }
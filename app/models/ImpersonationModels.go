@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type ImpersonationRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type ImpersonationLog struct {
+	ID           string    `json:"id" bson:"id"`
+	TenantId     string    `json:"tenant_id" bson:"tenant_id"`
+	ActorId      string    `json:"actor_id" bson:"actor_id"`
+	ActorEmail   string    `json:"actor_email" bson:"actor_email"`
+	SubjectId    string    `json:"subject_id" bson:"subject_id"`
+	SubjectEmail string    `json:"subject_email" bson:"subject_email"`
+	Reason       string    `json:"reason" bson:"reason"`
+	ClientIP     string    `json:"client_ip" bson:"client_ip"`
+	UserAgent    string    `json:"user_agent" bson:"user_agent"`
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at" bson:"expires_at"`
+}
@@ -1,7 +1,8 @@
 package models
 
 type Level struct {
-	ID      string `json:"id,omitempty"`
-	Name    string `json:"name,omitempty" bson:"name" validate:"required"`
-	Acronym string `json:"acronym,omitempty" bson:"acronym" validate:"required"`
+	ID       string `json:"id,omitempty"`
+	TenantId string `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Name     string `json:"name,omitempty" bson:"name" validate:"required"`
+	Acronym  string `json:"acronym,omitempty" bson:"acronym" validate:"required"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type TokenRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+// IntrospectionResponse follows RFC 7662 section 2.2. Only Active is set for
+// inactive tokens.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	Tenant    string `json:"tid,omitempty"`
+	// Act names the admin acting through an impersonation token.
+	Act map[string]interface{} `json:"act,omitempty"`
+}
+
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type RevokedToken struct {
+	Key       string    `json:"key" bson:"key"`
+	Subject   string    `json:"sub" bson:"sub"`
+	TokenType string    `json:"token_type" bson:"token_type"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at" bson:"revoked_at"`
+}
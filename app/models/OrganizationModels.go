@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Organization is a tenant. Users, levels and groups belong to exactly one organization.
+type Organization struct {
+	ID         string    `json:"id" bson:"id"`
+	Name       string    `json:"name" bson:"name" validate:"required"`
+	Slug       string    `json:"slug" bson:"slug" validate:"required,hostname_rfc1123,excludesall=."`
+	AdminLevel string    `json:"admin_level,omitempty" bson:"admin_level,omitempty"`
+	Disabled   bool      `json:"disabled,omitempty" bson:"disabled,omitempty"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Args fill the placeholders of the rule's message, e.g. the minimum length.
+	Args []interface{} `json:"-"`
+}
+
+type PasswordChange struct {
+	Email              string `json:"email" validate:"required"`
+	OldPassword        string `json:"old_password" validate:"required"`
+	NewPassword        string `json:"new_password" validate:"required"`
+	NewPasswordConfirm string `json:"new_password_confirm" validate:"required"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+const (
+	ScimUserSchema          = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimGroupSchema         = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimGroupExtension      = "urn:usermanagement:params:scim:schemas:extension:2.0:Group"
+	ScimListResponseSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimPatchOpSchema       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimErrorSchema         = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ScimServiceProviderConf = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ScimResourceTypeSchema  = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+type ScimMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+type ScimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type ScimMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type ScimUser struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName" validate:"required"`
+	Name        *ScimName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Password    string           `json:"password,omitempty"`
+	Emails      []ScimMultiValue `json:"emails,omitempty"`
+	Active      *bool            `json:"active,omitempty"`
+	Groups      []ScimMultiValue `json:"groups,omitempty"`
+	Meta        *ScimMeta        `json:"meta,omitempty"`
+}
+
+type ScimGroupExtensionAttributes struct {
+	Acronym string `json:"acronym,omitempty"`
+}
+
+type ScimGroup struct {
+	Schemas     []string                      `json:"schemas"`
+	ID          string                        `json:"id,omitempty"`
+	ExternalID  string                        `json:"externalId,omitempty"`
+	DisplayName string                        `json:"displayName" validate:"required"`
+	Members     []ScimMultiValue              `json:"members,omitempty"`
+	Extension   *ScimGroupExtensionAttributes `json:"urn:usermanagement:params:scim:schemas:extension:2.0:Group,omitempty"`
+	Meta        *ScimMeta                     `json:"meta,omitempty"`
+}
+
+type ScimPatchOperation struct {
+	Op    string      `json:"op" validate:"required"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations" validate:"required,min=1,dive"`
+}
+
+type ScimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int64       `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type ScimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
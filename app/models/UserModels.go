@@ -1,89 +1,129 @@
-package models
-
-import (
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-)
-
-type User struct {
-	Id              string    `json:"id,omitempty"`
-	Name            string    `json:"name,omitempty" validate:"required"`
-	Password        string    `json:"password" validate:"required,min=8"`
-	PasswordConfirm string    `json:"passwordConfirm" validate:"required"`
-	Email           string    `json:"email,omitempty" validate:"required"`
-	Level           string    `json:"level,omitempty" validate:"required"`
-	Group           string    `json:"group,omitempty" validate:"required"`
-	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at" bson:"updated_at"`
-}
-
-type UserSave struct {
-	Id        string    `json:"id,omitempty"`
-	Name      string    `json:"name,omitempty" validate:"required"`
-	Password  string    `json:"password" validate:"required,min=8"`
-	Email     string    `json:"email,omitempty" validate:"required"`
-	Level     string    `json:"level,omitempty" validate:"required"`
-	Group     string    `json:"group,omitempty" validate:"required"`
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
-}
-
-type UserEdit struct {
-	Id              string    `json:"id,omitempty"`
-	Name            string    `json:"name,omitempty" validate:"required"`
-	Password        string    `json:"password"`
-	PasswordConfirm string    `json:"passwordConfirm"`
-	Email           string    `json:"email,omitempty" validate:"required"`
-	Level           string    `json:"level,omitempty" validate:"required"`
-	Group           string    `json:"group,omitempty" validate:"required"`
-	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at" bson:"updated_at"`
-}
-
-type UserAuth struct {
-	Email    string `json:"email,omitempty" validate:"required"`
-	Password string `json:"password" validate:"required"`
-}
-
-type UserAuthorization struct {
-	AccessToken string `json:"access_token" validate:"required"`
-}
-
-type DBResponse struct {
-	ID        primitive.ObjectID `json:"_id" bson:"_id"`
-	Id        string             `json:"id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
-	Password  string             `json:"password" bson:"password"`
-	Email     string             `json:"email" bson:"email"`
-	Level     string             `json:"level,omitempty" bson:"level"`
-	Group     string             `json:"group,omitempty" bson:"group"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
-}
-
-type UserResponse struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id"`
-	Name      string             `json:"name" bson:"name"`
-	Password  string             `json:"password" bson:"password"`
-	Email     string             `json:"email" bson:"email"`
-	Level     string             `json:"level,omitempty" bson:"level"`
-	Group     string             `json:"group,omitempty" bson:"group"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
-}
-
-func FilteredResponse(user *DBResponse) UserResponse {
-	return UserResponse{
-		Email:     user.Email,
-		Name:      user.Name,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	}
-}
-
-type RequestResponse struct {
-	Status  int                    `json:"status"`
-	Message string                 `json:"message"`
-	Data    map[string]interface{} `json:"data"`
-}
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type User struct {
+	Id              string    `json:"id,omitempty"`
+	TenantId        string    `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Name            string    `json:"name,omitempty" validate:"required"`
+	Password        string    `json:"password" validate:"required"`
+	PasswordConfirm string    `json:"passwordConfirm" validate:"required"`
+	Email           string    `json:"email,omitempty" validate:"required"`
+	Level           string    `json:"level,omitempty"`
+	Group           string    `json:"group,omitempty"`
+	Locale          string    `json:"locale,omitempty" validate:"omitempty,oneof=id en"`
+	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+type UserSave struct {
+	Id                string           `json:"id,omitempty"`
+	TenantId          string           `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Name              string           `json:"name,omitempty" validate:"required"`
+	Password          string           `json:"password" validate:"required,min=8"`
+	Email             string           `json:"email,omitempty" validate:"required"`
+	Level             string           `json:"level,omitempty" validate:"required"`
+	Group             string           `json:"group,omitempty" validate:"required"`
+	Locale            string           `json:"locale,omitempty" bson:"locale,omitempty"`
+	Provider          string           `json:"provider,omitempty" bson:"provider,omitempty"`
+	ExternalId        string           `json:"external_id,omitempty" bson:"external_id,omitempty"`
+	Disabled          bool             `json:"disabled,omitempty" bson:"disabled,omitempty"`
+	Identities        []LinkedIdentity `json:"identities,omitempty" bson:"identities,omitempty"`
+	PasswordChangedAt *time.Time       `json:"password_changed_at,omitempty" bson:"password_changed_at,omitempty"`
+	CreatedAt         time.Time        `json:"created_at" bson:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at" bson:"updated_at"`
+}
+
+type UserEdit struct {
+	Id              string    `json:"id,omitempty"`
+	Name            string    `json:"name,omitempty" validate:"required"`
+	Password        string    `json:"password"`
+	PasswordConfirm string    `json:"passwordConfirm"`
+	Email           string    `json:"email,omitempty" validate:"required"`
+	Level           string    `json:"level,omitempty"`
+	Group           string    `json:"group,omitempty"`
+	Locale          string    `json:"locale,omitempty" validate:"omitempty,oneof=id en"`
+	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+type UserAuth struct {
+	Email    string `json:"email,omitempty" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type UserAuthorization struct {
+	AccessToken string `json:"access_token" validate:"required"`
+}
+
+type DBResponse struct {
+	ID                primitive.ObjectID `json:"_id" bson:"_id"`
+	Id                string             `json:"id,omitempty"`
+	TenantId          string             `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Name              string             `json:"name" bson:"name"`
+	Password          string             `json:"password" bson:"password"`
+	Email             string             `json:"email" bson:"email"`
+	Level             string             `json:"level,omitempty" bson:"level"`
+	Group             string             `json:"group,omitempty" bson:"group"`
+	Locale            string             `json:"locale,omitempty" bson:"locale,omitempty"`
+	Provider          string             `json:"provider,omitempty" bson:"provider,omitempty"`
+	ExternalId        string             `json:"external_id,omitempty" bson:"external_id,omitempty"`
+	Disabled          bool               `json:"disabled,omitempty" bson:"disabled,omitempty"`
+	Identities        []LinkedIdentity   `json:"identities,omitempty" bson:"identities,omitempty"`
+	PasswordHistory   []string           `json:"-" bson:"password_history,omitempty"`
+	PasswordChangedAt *time.Time         `json:"password_changed_at,omitempty" bson:"password_changed_at,omitempty"`
+	CreatedAt         time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at" bson:"updated_at"`
+	// PasswordLegacySanitized marks a hash made from the bluemonday-sanitised password by an older release.
+	PasswordLegacySanitized bool `json:"-" bson:"password_legacy_sanitized,omitempty"`
+	// Memberships holds the active group memberships, loaded for the current user only.
+	Memberships []GroupMembership `json:"memberships,omitempty" bson:"-"`
+}
+
+type UserResponse struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Name      string             `json:"name" bson:"name"`
+	Password  string             `json:"password" bson:"password"`
+	Email     string             `json:"email" bson:"email"`
+	Level     string             `json:"level,omitempty" bson:"level"`
+	Group     string             `json:"group,omitempty" bson:"group"`
+	Locale    string             `json:"locale,omitempty" bson:"locale"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+func FilteredResponse(user *DBResponse) UserResponse {
+	return UserResponse{
+		Email:     user.Email,
+		Name:      user.Name,
+		Locale:    user.Locale,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+type RequestResponse struct {
+	Status  int                    `json:"status"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// ExternalIdentity is the account reported by an upstream OAuth2 provider after login.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type LinkedIdentity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email,omitempty" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+type WebAuthnCredential struct {
+	ID           string              `json:"id" bson:"id"`
+	UserId       string              `json:"-" bson:"user_id"`
+	Name         string              `json:"name" bson:"name"`
+	CredentialId string              `json:"credential_id" bson:"credential_id"`
+	Credential   webauthn.Credential `json:"-" bson:"credential"`
+	CreatedAt    time.Time           `json:"created_at" bson:"created_at"`
+	LastUsedAt   *time.Time          `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+// WebAuthnSession keeps the challenge of a ceremony between its begin and finish requests.
+type WebAuthnSession struct {
+	ID        string               `bson:"id"`
+	UserId    string               `bson:"user_id,omitempty"`
+	Purpose   string               `bson:"purpose"`
+	Data      webauthn.SessionData `bson:"data"`
+	ExpiresAt time.Time            `bson:"expires_at"`
+}
+
+type WebAuthnLogin struct {
+	Email string `json:"email,omitempty"`
+}
@@ -0,0 +1,40 @@
+package openapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+)
+
+// swaggerUI is the pinned Swagger UI release the docs page loads from the CDN.
+const swaggerUI = "https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14/"
+
+// DocsPage renders the document with Swagger UI, loaded from a CDN by the browser.
+//
+//go:embed Docs.html
+var DocsPage []byte
+
+// DocsPolicy is the Content-Security-Policy of DocsPage. It only lets the pinned Swagger UI files
+// and the page's own inline script run, and keeps every request the page makes on this origin.
+var DocsPolicy = docsPolicy(DocsPage)
+
+func docsPolicy(page []byte) string {
+	const open, end = "<script>", "</script>"
+
+	inline := ""
+	if start := bytes.Index(page, []byte(open)); start >= 0 {
+		script := page[start+len(open):]
+		if stop := bytes.Index(script, []byte(end)); stop >= 0 {
+			sum := sha256.Sum256(script[:stop])
+			inline = fmt.Sprintf(" 'sha256-%s'", base64.StdEncoding.EncodeToString(sum[:]))
+		}
+	}
+
+	return "default-src 'none'; " +
+		"script-src " + swaggerUI + "swagger-ui-bundle.js" + inline + "; " +
+		// Swagger UI sets inline styles on the elements it renders
+		"style-src " + swaggerUI + "swagger-ui.css 'unsafe-inline'; " +
+		"img-src 'self' data:; connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+}
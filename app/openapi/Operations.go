@@ -0,0 +1,646 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/go-webauthn/webauthn/protocol"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// route documents one registered route. Errors are the apperrors codes the handler can answer
+// with besides those of its guards; responses holds answers outside that format, such as
+// redirects and the OAuth and SCIM endpoints.
+type route struct {
+	method      string
+	path        string
+	id          string
+	tag         string
+	summary     string
+	description string
+	guards      []guard
+	parameters  []Parameter
+	body        *RequestBody
+	status      int
+	response    *Schema
+	headers     map[string]Header
+	errors      []apperrors.Code
+	responses   map[string]Response
+}
+
+// guard documents a middleware: the credentials it accepts and the errors it can abort with.
+// excludes drops a scheme an earlier guard accepts.
+type guard struct {
+	security []SecurityRequirement
+	excludes string
+	errors   []apperrors.Code
+}
+
+var (
+	authorizationUser = guard{
+		security: []SecurityRequirement{{"bearerAuth": {}}, {"cookieAuth": {}}, {"apiKey": {}}},
+		errors: []apperrors.Code{
+			apperrors.Unauthenticated, apperrors.InvalidToken, apperrors.SessionUserNotFound,
+			apperrors.ImpersonatorNotFound, apperrors.ImpersonatorNotAdmin, apperrors.InvalidApiKey, apperrors.ApiKeyScope,
+			apperrors.AccountDisabled, apperrors.WrongOrganization, apperrors.SecurityKeyRequired,
+		},
+	}
+	authorization = guard{
+		security: []SecurityRequirement{{"bearerAuth": {}}, {}},
+		errors: []apperrors.Code{
+			apperrors.InvalidRequest, apperrors.Unauthenticated, apperrors.InvalidToken,
+			apperrors.SessionUserNotFound, apperrors.ImpersonatorNotFound, apperrors.ImpersonatorNotAdmin, apperrors.AccountDisabled,
+			apperrors.WrongOrganization, apperrors.SecurityKeyRequired,
+		},
+	}
+	denyApiKey          = guard{excludes: "apiKey", errors: []apperrors.Code{apperrors.ApiKeyNotAllowed}}
+	denyImpersonation   = guard{errors: []apperrors.Code{apperrors.ImpersonationNotAllowed}}
+	requireAdmin        = guard{errors: []apperrors.Code{apperrors.Forbidden}}
+	requireSuperAdmin   = guard{errors: []apperrors.Code{apperrors.Forbidden}}
+	requireGroupManager = guard{errors: []apperrors.Code{apperrors.GroupNotFound, apperrors.Forbidden}}
+	scimAuthorization   = guard{security: []SecurityRequirement{{"scimToken": {}}}}
+	oauthClient         = guard{security: []SecurityRequirement{{"clientBasic": {}}, {}}}
+)
+
+// bound are the errors of a JSON body that cannot be decoded or fails validation.
+var bound = []apperrors.Code{apperrors.InvalidRequest, apperrors.ValidationFailed}
+
+func codes(groups ...[]apperrors.Code) []apperrors.Code {
+	var all []apperrors.Code
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+// serviceRoutes are registered by bootstrap for operations, outside the organization of a
+// request.
+func serviceRoutes() []route {
+	return []route{
+		{
+			method: http.MethodGet, path: "/metrics", id: "metrics", tag: "Service",
+			summary: "Prometheus metrics",
+			responses: map[string]Response{
+				"200": {Description: "Metrics in the Prometheus text format", Content: map[string]MediaType{"text/plain": {Schema: str()}}},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/healthz", id: "healthz", tag: "Service",
+			summary:  "Liveness probe",
+			status:   http.StatusOK,
+			response: object(fields{"status": str()}),
+		},
+		{
+			method: http.MethodGet, path: "/readyz", id: "readyz", tag: "Service",
+			summary:     "Readiness probe",
+			description: "Fails while MongoDB or the token keys are unavailable and once shutdown has begun.",
+			status:      http.StatusOK,
+			response:    Ref("Readiness"),
+			responses: map[string]Response{
+				"503": {Description: "Not ready", Content: jsonContent(Ref("Readiness"))},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/openapi.json", id: "openapi", tag: "Service",
+			summary:  "This document",
+			status:   http.StatusOK,
+			response: &Schema{Type: "object", Description: "OpenAPI 3 document"},
+		},
+		{
+			method: http.MethodGet, path: "/docs", id: "docs", tag: "Service",
+			summary: "Interactive API documentation",
+			responses: map[string]Response{
+				"200": {Description: "HTML page rendering this document", Content: map[string]MediaType{"text/html": {Schema: str()}}},
+			},
+		},
+	}
+}
+
+// apiRoutes are the routes of routes.Routes. They all run in an organization, so they also
+// accept X-Tenant-ID and fail when the organization is unknown or disabled.
+func apiRoutes(registry schemas) []route {
+	insertResult := registry.of(mongo.InsertOneResult{})
+	deleted := envelope(str())
+
+	var all []route
+	for _, routes := range [][]route{
+		userRoutes(registry, insertResult, deleted),
+		securityKeyRoutes(registry, deleted),
+		apiKeyRoutes(registry, deleted),
+		directoryRoutes(registry, insertResult, deleted),
+		scimRoutes(registry),
+	} {
+		all = append(all, routes...)
+	}
+	return all
+}
+
+// userRoutes are the logins, sessions and user accounts.
+func userRoutes(registry schemas, insertResult *Schema, deleted *Schema) []route {
+	return []route{
+		{
+			method: http.MethodPost, path: "/user/auth", id: "login", tag: "Authentication",
+			summary:     "Log in with email and password",
+			description: "Sets the access_token, refresh_token and logged_in cookies. Users with a security key get a WebAuthn assertion to finish through /auth/webauthn/login/finish instead. Users whose level requires a security key they have not registered only get a session for registering one, and log in again afterwards.",
+			body:        jsonBody(registry.of(models.UserAuth{})),
+			status:      http.StatusOK,
+			response:    &Schema{OneOf: []*Schema{Ref("Session"), Ref("SecondFactorRequired"), Ref("KeyRegistrationRequired")}},
+			headers:     setCookie,
+			errors:      codes(bound, []apperrors.Code{apperrors.UnknownEmail, apperrors.InvalidPassword, apperrors.AccountDisabled, apperrors.PasswordExpired}),
+		},
+		{
+			method: http.MethodPost, path: "/user/password/change", id: "changePassword", tag: "Authentication",
+			summary:     "Change the password",
+			description: "Needs no session, so users whose password has expired can replace it.",
+			body:        jsonBody(registry.of(models.PasswordChange{})),
+			status:      http.StatusOK,
+			response:    envelope(str()),
+			errors:      codes(bound, []apperrors.Code{apperrors.PasswordMismatch, apperrors.InvalidCredentials, apperrors.AccountDisabled, apperrors.PasswordPolicy}),
+		},
+		{
+			method: http.MethodPost, path: "/authorization", id: "authorize", tag: "Authentication",
+			summary:     "Resolve an access token to its user",
+			description: "The token is read from the Authorization header or, without one, from access_token in the body.",
+			guards:      []guard{authorization},
+			body:        jsonBody(registry.of(models.UserAuthorization{})),
+			status:      http.StatusOK,
+			response:    Ref("Me"),
+		},
+		{
+			method: http.MethodGet, path: "/me", id: "getMe", tag: "Authentication",
+			summary:  "The logged-in user",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: Ref("Me"),
+		},
+		{
+			method: http.MethodGet, path: "/user/logout", id: "logout", tag: "Authentication",
+			summary:  "Log out",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: object(fields{"status": str()}),
+			headers:  setCookie,
+		},
+		{
+			method: http.MethodGet, path: "/auth/:provider/login", id: "socialLogin", tag: "Authentication",
+			summary: "Start a login with an external provider",
+			responses: map[string]Response{
+				"302": {Description: "Redirect to the provider", Headers: map[string]Header{"Location": {Schema: str()}}},
+			},
+			errors: []apperrors.Code{apperrors.UnknownProvider},
+		},
+		{
+			method: http.MethodGet, path: "/auth/:provider/callback", id: "socialCallback", tag: "Authentication",
+			summary:     "Finish a login with an external provider",
+			description: "Redirects to OAUTH_LOGIN_REDIRECT when it is configured, otherwise answers with the session. Users with a security key, or whose level requires one, get the same answers as from /user/auth instead.",
+			parameters: []Parameter{
+				query("code", "Authorization code from the provider"),
+				query("state", "State issued by the login request"),
+				query("error", "Error reported by the provider"),
+			},
+			status:   http.StatusOK,
+			response: &Schema{OneOf: []*Schema{Ref("Session"), Ref("SecondFactorRequired"), Ref("KeyRegistrationRequired")}},
+			headers:  setCookie,
+			responses: map[string]Response{
+				"302": {Description: "Logged in, redirect to OAUTH_LOGIN_REDIRECT", Headers: map[string]Header{"Location": {Schema: str()}}},
+			},
+			errors: []apperrors.Code{
+				apperrors.UnknownProvider, apperrors.ExternalLoginFailed, apperrors.InvalidLoginSession, apperrors.UpstreamError,
+				apperrors.UnknownEmail, apperrors.UnverifiedEmail, apperrors.AccountDisabled,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/oauth/introspect", id: "introspectToken", tag: "OAuth",
+			summary:     "Token introspection (RFC 7662)",
+			description: "Clients authenticate with HTTP Basic or client_id and client_secret in the form.",
+			guards:      []guard{oauthClient},
+			body:        formBody(),
+			responses: map[string]Response{
+				"200": {Description: "Token state, only active is set for inactive tokens", Content: jsonContent(registry.of(models.IntrospectionResponse{}))},
+				"400": oauthError(registry, "Malformed request"),
+				"401": oauthError(registry, "Client authentication failed"),
+				"503": oauthError(registry, "Revocations cannot be checked"),
+			},
+		},
+		{
+			method: http.MethodPost, path: "/oauth/revoke", id: "revokeToken", tag: "OAuth",
+			summary:     "Token revocation (RFC 7009)",
+			description: "Clients authenticate with HTTP Basic or client_id and client_secret in the form.",
+			guards:      []guard{oauthClient},
+			body:        formBody(),
+			responses: map[string]Response{
+				"200": {Description: "Revoked, or the token was not valid"},
+				"400": oauthError(registry, "Malformed request"),
+				"401": oauthError(registry, "Client authentication failed"),
+				"503": oauthError(registry, "The revocation could not be checked or stored"),
+			},
+		},
+		{
+			method: http.MethodPost, path: "/user/create", id: "createUser", tag: "Users",
+			summary:     "Register a user",
+			description: "The level and group are REGISTER_DEFAULT_LEVEL and REGISTER_DEFAULT_GROUP; the ones in the request are ignored.",
+			body:        jsonBody(registry.of(models.User{})),
+			status:      http.StatusCreated,
+			response:    envelope(insertResult),
+			errors:      codes(bound, []apperrors.Code{apperrors.PasswordMismatch, apperrors.PasswordPolicy, apperrors.EmailTaken}),
+		},
+		{
+			method: http.MethodGet, path: "/user/read/:userId", id: "getUser", tag: "Users",
+			summary:  "Read a user",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(registry.of(models.UserSave{})),
+			errors:   []apperrors.Code{apperrors.UserNotFound},
+		},
+		{
+			method: http.MethodPut, path: "/user/update/:userId", id: "updateUser", tag: "Users",
+			summary:     "Update a user",
+			description: "The password is only changed when one is sent. Without a level, group or locale the stored one is kept. Only admins update other users or change a level or group.",
+			guards:      []guard{authorizationUser, denyApiKey, denyImpersonation},
+			body:        jsonBody(registry.of(models.UserEdit{})),
+			status:      http.StatusOK,
+			response:    envelope(registry.of(models.UserSave{})),
+			errors: codes(bound, []apperrors.Code{
+				apperrors.PasswordMismatch, apperrors.UserNotFound,
+				apperrors.Forbidden, apperrors.PasswordPolicy, apperrors.EmailTaken,
+			}),
+		},
+		{
+			method: http.MethodDelete, path: "/user/delete/:userId", id: "deleteUser", tag: "Users",
+			summary:     "Delete a user",
+			description: "Only admins delete other users.",
+			guards:      []guard{authorizationUser, denyApiKey, denyImpersonation},
+			status:      http.StatusOK,
+			response:    deleted,
+			errors:      []apperrors.Code{apperrors.Forbidden, apperrors.UserNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/users", id: "listUsers", tag: "Users",
+			summary:  "List the users of the organization",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(array(registry.of(models.User{}))),
+		},
+	}
+}
+
+func securityKeyRoutes(registry schemas, deleted *Schema) []route {
+	sessionCookie := "Sets the webauthn_session cookie the finish request has to send back."
+
+	return []route{
+		{
+			method: http.MethodPost, path: "/auth/webauthn/login/begin", id: "beginWebAuthnLogin", tag: "Security keys",
+			summary:     "Start a login with a security key",
+			description: "Without an email the browser offers the passkeys it knows for this site. " + sessionCookie,
+			body:        &RequestBody{Content: jsonContent(registry.of(models.WebAuthnLogin{}))},
+			status:      http.StatusOK,
+			response:    envelope(registry.of(protocol.CredentialAssertion{})),
+			headers:     setCookie,
+			errors:      []apperrors.Code{apperrors.InvalidRequest, apperrors.UnknownEmail, apperrors.NoSecurityKey},
+		},
+		{
+			method: http.MethodPost, path: "/auth/webauthn/login/finish", id: "finishWebAuthnLogin", tag: "Security keys",
+			summary:     "Finish a login with a security key",
+			description: "Also completes the second factor requested by /user/auth.",
+			body:        jsonBody(registry.of(protocol.CredentialAssertionResponse{})),
+			status:      http.StatusOK,
+			response:    Ref("Session"),
+			headers:     setCookie,
+			errors: []apperrors.Code{
+				apperrors.InvalidRequest, apperrors.InvalidWebAuthnSession, apperrors.SessionUserNotFound,
+				apperrors.WebAuthnFailed, apperrors.ClonedSecurityKey, apperrors.AccountDisabled,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/me/webauthn/register/begin", id: "beginWebAuthnRegistration", tag: "Security keys",
+			summary:     "Start registering a security key",
+			description: "Also accepts the session limited to registering a security key. " + sessionCookie,
+			guards:      []guard{authorizationUser, denyApiKey, denyImpersonation},
+			status:      http.StatusOK,
+			response:    envelope(registry.of(protocol.CredentialCreation{})),
+			headers:     setCookie,
+		},
+		{
+			method: http.MethodPost, path: "/me/webauthn/register/finish", id: "finishWebAuthnRegistration", tag: "Security keys",
+			summary:     "Finish registering a security key",
+			description: "Also accepts the session limited to registering a security key.",
+			guards:      []guard{authorizationUser, denyApiKey, denyImpersonation},
+			parameters:  []Parameter{query("name", "Name shown for the key, Security key by default")},
+			body:        jsonBody(registry.of(protocol.CredentialCreationResponse{})),
+			status:      http.StatusCreated,
+			response:    envelope(registry.of(models.WebAuthnCredential{})),
+			errors:      []apperrors.Code{apperrors.InvalidWebAuthnSession, apperrors.SecurityKeyRejected},
+		},
+		{
+			method: http.MethodGet, path: "/me/webauthn/credentials", id: "listWebAuthnCredentials", tag: "Security keys",
+			summary:  "List the security keys of the logged-in user",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(array(registry.of(models.WebAuthnCredential{}))),
+		},
+		{
+			method: http.MethodDelete, path: "/me/webauthn/credentials/:credentialId", id: "deleteWebAuthnCredential", tag: "Security keys",
+			summary:  "Delete a security key",
+			guards:   []guard{authorizationUser, denyApiKey, denyImpersonation},
+			status:   http.StatusOK,
+			response: deleted,
+			errors:   []apperrors.Code{apperrors.CredentialNotFound},
+		},
+	}
+}
+
+func apiKeyRoutes(registry schemas, deleted *Schema) []route {
+	return []route{
+		{
+			method: http.MethodPost, path: "/me/api-keys", id: "createApiKey", tag: "API keys",
+			summary:     "Create an API key",
+			description: "The key is only returned here, the service keeps its hash. Without expires_in_days the key expires after 90 days.",
+			guards:      []guard{authorizationUser, denyApiKey, denyImpersonation},
+			body:        jsonBody(registry.of(models.ApiKeyCreate{})),
+			status:      http.StatusCreated,
+			response:    envelope(registry.of(models.ApiKeyCreated{})),
+			errors:      bound,
+		},
+		{
+			method: http.MethodGet, path: "/me/api-keys", id: "listApiKeys", tag: "API keys",
+			summary:  "List the API keys of the logged-in user",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(array(registry.of(models.ApiKey{}))),
+		},
+		{
+			method: http.MethodDelete, path: "/me/api-keys/:keyId", id: "deleteApiKey", tag: "API keys",
+			summary:  "Revoke an API key",
+			guards:   []guard{authorizationUser, denyApiKey, denyImpersonation},
+			status:   http.StatusOK,
+			response: deleted,
+			errors:   []apperrors.Code{apperrors.ApiKeyNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/admin/impersonate/:userId", id: "impersonateUser", tag: "Impersonation",
+			summary:     "Impersonate a user",
+			description: "Returns a short-lived access token for the user that records the admin as actor. Admins with the same or higher rights cannot be impersonated.",
+			guards:      []guard{authorizationUser, denyApiKey, denyImpersonation, requireAdmin},
+			body:        jsonBody(registry.of(models.ImpersonationRequest{})),
+			status:      http.StatusCreated,
+			response: envelope(object(fields{
+				"access_token": str(),
+				"expires_at":   &Schema{Type: "string", Format: "date-time"},
+				"log":          registry.of(models.ImpersonationLog{}),
+			})),
+			errors: codes(bound, []apperrors.Code{apperrors.UserNotFound, apperrors.SelfImpersonation, apperrors.PrivilegedImpersonation}),
+		},
+		{
+			method: http.MethodGet, path: "/admin/impersonations", id: "listImpersonations", tag: "Impersonation",
+			summary: "List impersonations, newest first",
+			guards:  []guard{authorizationUser, denyApiKey, denyImpersonation, requireAdmin},
+			parameters: []Parameter{
+				query("subject_id", "Only impersonations of this user"),
+				query("actor_id", "Only impersonations by this admin"),
+			},
+			status:   http.StatusOK,
+			response: envelope(array(registry.of(models.ImpersonationLog{}))),
+		},
+	}
+}
+
+// directoryRoutes are the levels, groups and organizations.
+func directoryRoutes(registry schemas, insertResult *Schema, deleted *Schema) []route {
+	level := registry.of(models.Level{})
+	group := registry.of(models.Group{})
+	organization := registry.of(models.Organization{})
+	groupErrors := []apperrors.Code{apperrors.UnknownGroupManager, apperrors.ParentGroupNotFound, apperrors.GroupCycle}
+	superAdmin := []guard{authorizationUser, denyApiKey, denyImpersonation, requireSuperAdmin}
+
+	return []route{
+		{
+			method: http.MethodPost, path: "/level/create", id: "createLevel", tag: "Levels",
+			summary:  "Create a level",
+			guards:   []guard{authorizationUser, denyApiKey, requireAdmin},
+			body:     jsonBody(level),
+			status:   http.StatusCreated,
+			response: envelope(insertResult),
+			errors:   bound,
+		},
+		{
+			method: http.MethodGet, path: "/levels", id: "listLevels", tag: "Levels",
+			summary:  "List levels",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(array(level)),
+		},
+		{
+			method: http.MethodGet, path: "/level/read/:levelId", id: "getLevel", tag: "Levels",
+			summary:  "Read a level",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(level),
+			errors:   []apperrors.Code{apperrors.LevelNotFound},
+		},
+		{
+			method: http.MethodPut, path: "/level/update/:levelId", id: "updateLevel", tag: "Levels",
+			summary:  "Update a level",
+			guards:   []guard{authorizationUser, denyApiKey, requireAdmin},
+			body:     jsonBody(level),
+			status:   http.StatusOK,
+			response: envelope(level),
+			errors:   codes(bound, []apperrors.Code{apperrors.LevelNotFound}),
+		},
+		{
+			method: http.MethodDelete, path: "/level/delete/:levelId", id: "deleteLevel", tag: "Levels",
+			summary:  "Delete a level",
+			guards:   []guard{authorizationUser, denyApiKey, requireAdmin},
+			status:   http.StatusOK,
+			response: deleted,
+			errors:   []apperrors.Code{apperrors.LevelNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/group/create", id: "createGroup", tag: "Groups",
+			summary:  "Create a group",
+			guards:   []guard{authorizationUser, denyApiKey, requireAdmin},
+			body:     jsonBody(group),
+			status:   http.StatusCreated,
+			response: envelope(insertResult),
+			errors:   codes(bound, groupErrors),
+		},
+		{
+			method: http.MethodGet, path: "/groups", id: "listGroups", tag: "Groups",
+			summary: "List groups",
+			guards:  []guard{authorizationUser},
+			parameters: []Parameter{
+				query("parent_id", "Only the children of this group; empty for the root groups"),
+			},
+			status:   http.StatusOK,
+			response: envelope(array(group)),
+		},
+		{
+			method: http.MethodGet, path: "/group/read/:groupId", id: "getGroup", tag: "Groups",
+			summary:  "Read a group with the managers and permissions it inherits",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelopeWith(fields{"data": group, "access": registry.of(models.GroupAccess{})}),
+			errors:   []apperrors.Code{apperrors.GroupNotFound},
+		},
+		{
+			method: http.MethodPut, path: "/group/update/:groupId", id: "updateGroup", tag: "Groups",
+			summary:  "Update a group",
+			guards:   []guard{authorizationUser, denyApiKey, requireAdmin},
+			body:     jsonBody(group),
+			status:   http.StatusOK,
+			response: envelope(group),
+			errors:   codes(bound, []apperrors.Code{apperrors.GroupNotFound}, groupErrors),
+		},
+		{
+			method: http.MethodDelete, path: "/group/delete/:groupId", id: "deleteGroup", tag: "Groups",
+			summary:  "Delete a group without sub groups",
+			guards:   []guard{authorizationUser, denyApiKey, requireAdmin},
+			status:   http.StatusOK,
+			response: deleted,
+			errors:   []apperrors.Code{apperrors.GroupNotFound, apperrors.GroupHasChildren},
+		},
+		{
+			method: http.MethodGet, path: "/group/ancestors/:groupId", id: "groupAncestors", tag: "Groups",
+			summary:  "The groups above a group, from the root down",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(array(group)),
+			errors:   []apperrors.Code{apperrors.GroupNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/group/descendants/:groupId", id: "groupDescendants", tag: "Groups",
+			summary:  "Every group below a group",
+			guards:   []guard{authorizationUser},
+			status:   http.StatusOK,
+			response: envelope(array(group)),
+			errors:   []apperrors.Code{apperrors.GroupNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/group/members/:groupId", id: "groupMembers", tag: "Groups",
+			summary:    "List the members of a group",
+			guards:     []guard{authorizationUser, requireGroupManager},
+			parameters: []Parameter{query("subtree", "true to include the members of every group below")},
+			status:     http.StatusOK,
+			response: envelopeWith(fields{
+				"data":        array(registry.of(models.User{})),
+				"memberships": array(registry.of(models.GroupMembership{})),
+			}),
+		},
+		{
+			method: http.MethodPost, path: "/group/members/:groupId", id: "addGroupMember", tag: "Groups",
+			summary:     "Add a member to a group",
+			description: "Changes the role and validity of an existing membership.",
+			guards:      []guard{authorizationUser, denyApiKey, requireGroupManager},
+			body:        jsonBody(registry.of(models.GroupMembershipSave{})),
+			status:      http.StatusOK,
+			response:    envelope(registry.of(models.GroupMembership{})),
+			errors:      codes(bound, []apperrors.Code{apperrors.InvalidMembershipPeriod, apperrors.UserNotFound}),
+		},
+		{
+			method: http.MethodDelete, path: "/group/members/:groupId/:userId", id: "removeGroupMember", tag: "Groups",
+			summary:  "Remove a member from a group",
+			guards:   []guard{authorizationUser, denyApiKey, requireGroupManager},
+			status:   http.StatusOK,
+			response: deleted,
+			errors:   []apperrors.Code{apperrors.MembershipNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/organization/create", id: "createOrganization", tag: "Organizations",
+			summary:  "Create an organization",
+			guards:   superAdmin,
+			body:     jsonBody(organization),
+			status:   http.StatusCreated,
+			response: envelope(insertResult),
+			errors:   codes(bound, []apperrors.Code{apperrors.SlugTaken}),
+		},
+		{
+			method: http.MethodGet, path: "/organizations", id: "listOrganizations", tag: "Organizations",
+			summary:  "List organizations",
+			guards:   superAdmin,
+			status:   http.StatusOK,
+			response: envelope(array(organization)),
+		},
+		{
+			method: http.MethodGet, path: "/organization/read/:organizationId", id: "getOrganization", tag: "Organizations",
+			summary:  "Read an organization",
+			guards:   superAdmin,
+			status:   http.StatusOK,
+			response: envelope(organization),
+			errors:   []apperrors.Code{apperrors.OrganizationNotFound},
+		},
+		{
+			method: http.MethodPut, path: "/organization/update/:organizationId", id: "updateOrganization", tag: "Organizations",
+			summary:  "Update an organization",
+			guards:   superAdmin,
+			body:     jsonBody(organization),
+			status:   http.StatusOK,
+			response: envelope(organization),
+			errors:   codes(bound, []apperrors.Code{apperrors.DefaultOrganization, apperrors.SlugTaken, apperrors.OrganizationNotFound}),
+		},
+		{
+			method: http.MethodDelete, path: "/organization/delete/:organizationId", id: "deleteOrganization", tag: "Organizations",
+			summary:  "Delete an organization without users",
+			guards:   superAdmin,
+			status:   http.StatusOK,
+			response: deleted,
+			errors:   []apperrors.Code{apperrors.DefaultOrganization, apperrors.OrganizationHasUsers, apperrors.OrganizationNotFound},
+		},
+	}
+}
+
+// scimRoutes follow RFC 7644: SCIM media type and SCIM errors instead of the API envelope.
+func scimRoutes(registry schemas) []route {
+	user := registry.of(models.ScimUser{})
+	group := registry.of(models.ScimGroup{})
+	patch := registry.of(models.ScimPatchRequest{})
+	listParameters := []Parameter{
+		query("filter", "SCIM filter, e.g. userName eq \"jane@example.com\""),
+		{Name: "startIndex", In: "query", Description: "1-based index of the first result", Schema: &Schema{Type: "integer"}},
+		{Name: "count", In: "query", Description: "Results per page", Schema: &Schema{Type: "integer"}},
+	}
+	scim := []guard{scimAuthorization}
+
+	scimRoute := func(method string, path string, id string, summary string, body *Schema, status int, response *Schema, failures ...int) route {
+		responses := map[string]Response{
+			"401": scimError(registry, http.StatusUnauthorized),
+			"403": scimError(registry, http.StatusForbidden),
+			"500": scimError(registry, http.StatusInternalServerError),
+		}
+		for _, failure := range failures {
+			responses[statusKey(failure)] = scimError(registry, failure)
+		}
+		if response != nil {
+			responses[statusKey(status)] = Response{Description: http.StatusText(status), Content: scimContent(response)}
+		} else {
+			responses[statusKey(status)] = Response{Description: http.StatusText(status)}
+		}
+
+		documented := route{method: method, path: "/scim/v2" + path, id: id, tag: "SCIM", summary: summary, guards: scim, responses: responses}
+		if body != nil {
+			documented.body = &RequestBody{Required: true, Content: scimContent(body)}
+		}
+		return documented
+	}
+
+	listUsers := scimRoute(http.MethodGet, "/Users", "scimListUsers", "List users", nil, http.StatusOK, scimList(user), http.StatusBadRequest)
+	listUsers.parameters = listParameters
+	listGroups := scimRoute(http.MethodGet, "/Groups", "scimListGroups", "List groups", nil, http.StatusOK, scimList(group), http.StatusBadRequest)
+	listGroups.parameters = append(listParameters, query("excludedAttributes", "members to leave out the members"))
+
+	return []route{
+		scimRoute(http.MethodGet, "/ServiceProviderConfig", "scimServiceProviderConfig", "Supported SCIM features", nil, http.StatusOK, &Schema{Type: "object"}),
+		scimRoute(http.MethodGet, "/ResourceTypes", "scimResourceTypes", "Supported resource types", nil, http.StatusOK, scimList(&Schema{Type: "object"})),
+		listUsers,
+		scimRoute(http.MethodPost, "/Users", "scimCreateUser", "Create a user", user, http.StatusCreated, user, http.StatusBadRequest, http.StatusConflict),
+		scimRoute(http.MethodGet, "/Users/:id", "scimGetUser", "Read a user", nil, http.StatusOK, user, http.StatusNotFound),
+		scimRoute(http.MethodPut, "/Users/:id", "scimReplaceUser", "Replace a user", user, http.StatusOK, user, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+		scimRoute(http.MethodPatch, "/Users/:id", "scimPatchUser", "Patch a user", patch, http.StatusOK, user, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+		scimRoute(http.MethodDelete, "/Users/:id", "scimDeleteUser", "Delete a user", nil, http.StatusNoContent, nil, http.StatusNotFound),
+		listGroups,
+		scimRoute(http.MethodPost, "/Groups", "scimCreateGroup", "Create a group", group, http.StatusCreated, group, http.StatusBadRequest, http.StatusConflict),
+		scimRoute(http.MethodGet, "/Groups/:id", "scimGetGroup", "Read a group", nil, http.StatusOK, group, http.StatusNotFound),
+		scimRoute(http.MethodPut, "/Groups/:id", "scimReplaceGroup", "Replace a group", group, http.StatusOK, group, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+		scimRoute(http.MethodPatch, "/Groups/:id", "scimPatchGroup", "Patch a group", patch, http.StatusOK, group, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+		scimRoute(http.MethodDelete, "/Groups/:id", "scimDeleteGroup", "Delete a group", nil, http.StatusNoContent, nil, http.StatusNotFound, http.StatusConflict),
+	}
+}
@@ -0,0 +1,184 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	objectIdType  = reflect.TypeOf(primitive.ObjectID{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemas turns Go types into schemas, registering every struct once under its type name in
+// the components, so the documented bodies are always the models the handlers bind.
+type schemas map[string]*Schema
+
+// of returns the schema of the type of value.
+func (registry schemas) of(value interface{}) *Schema {
+	return registry.schema(reflect.TypeOf(value))
+}
+
+func (registry schemas) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == objectIdType:
+		return &Schema{Type: "string", Description: "MongoDB ObjectID"}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// encoded by its own MarshalJSON, its shape cannot be told from the fields; the byte
+		// slices of WebAuthn are base64url strings
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "base64url"}
+		}
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: registry.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: registry.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return registry.object(t)
+		}
+		if _, found := registry[t.Name()]; !found {
+			// reserve the name first, so a type that refers to itself ends in a $ref
+			registry[t.Name()] = &Schema{}
+			*registry[t.Name()] = *registry.object(t)
+		}
+		return Ref(t.Name())
+	}
+	return &Schema{}
+}
+
+// object describes the JSON fields of a struct, with the constraints of their validate tags.
+func (registry schemas) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	registry.fields(t, object)
+	return object
+}
+
+func (registry schemas) fields(t reflect.Type, object *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		// embedded structs without a name of their own are flattened, as encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				registry.fields(embedded, object)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := registry.schema(field.Type)
+		if constrain(property, field.Tag.Get("validate")) {
+			object.Required = append(object.Required, name)
+		}
+		object.Properties[name] = property
+	}
+}
+
+// constrain applies the validate rules the schema can express and reports whether the field is
+// required. Rules after dive apply to the elements of a slice.
+func constrain(property *Schema, rules string) bool {
+	if rules == "" {
+		return false
+	}
+
+	target := property
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			required = required || target == property
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			// the items of a $ref are shared with every other use, copy before narrowing
+			items := *target.Items
+			target.Items = &items
+			target = &items
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, value)
+			}
+		case "email":
+			target.Format = "email"
+		case "hostname_rfc1123":
+			target.Format = "hostname"
+		case "min", "max":
+			limit, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			setLimit(target, tag, limit)
+		}
+	}
+	return required
+}
+
+func setLimit(target *Schema, tag string, limit int) {
+	switch target.Type {
+	case "string":
+		if tag == "min" {
+			target.MinLength = &limit
+		} else {
+			target.MaxLength = &limit
+		}
+	case "array":
+		if tag == "min" {
+			target.MinItems = &limit
+		}
+	case "integer", "number":
+		value := float64(limit)
+		if tag == "min" {
+			target.Minimum = &value
+		} else {
+			target.Maximum = &value
+		}
+	}
+}
+
+// Ref points to a schema in the components.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
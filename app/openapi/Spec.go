@@ -0,0 +1,379 @@
+package openapi
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RadenAbror/UserManagement/app/apperrors"
+	"github.com/RadenAbror/UserManagement/app/i18n"
+	"github.com/RadenAbror/UserManagement/app/models"
+	"github.com/gin-gonic/gin"
+)
+
+const description = `Users, organizations, groups and access of the User Management service.
+
+Requests run in the organization named by the X-Tenant-ID header or the subdomain, else in the
+organization of the caller's token, else in the default organization.
+
+Errors share one body: data holds a message in the language of the response, code a stable
+error code (see ErrorCode) to branch on. Messages are in the locale of the logged-in user, else
+the best match for Accept-Language (id or en), else DEFAULT_LOCALE; the Content-Language header
+names the one used. OAuth and SCIM endpoints answer with the errors of their RFCs.`
+
+var tags = []Tag{
+	{Name: "Authentication", Description: "Logins and sessions"},
+	{Name: "Users"},
+	{Name: "Security keys", Description: "WebAuthn security keys and passkeys"},
+	{Name: "API keys", Description: "Personal API keys, sent as X-API-Key or Authorization: ApiKey <key>"},
+	{Name: "Impersonation"},
+	{Name: "Levels"},
+	{Name: "Groups", Description: "The organization chart"},
+	{Name: "Organizations", Description: "Tenants, managed by super admins"},
+	{Name: "OAuth", Description: "Token introspection and revocation for registered OAuth clients"},
+	{Name: "SCIM", Description: "SCIM 2.0 provisioning of one organization, authenticated with its token from SCIM_TOKEN or SCIM_TOKENS"},
+	{Name: "Service", Description: "Probes, metrics and documentation"},
+}
+
+var securitySchemes = map[string]SecurityScheme{
+	"bearerAuth":  {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Access token from a login"},
+	"cookieAuth":  {Type: "apiKey", In: "cookie", Name: "access_token", Description: "Access token cookie set by a login"},
+	"apiKey":      {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "Personal API key; Authorization: ApiKey <key> works too"},
+	"clientBasic": {Type: "http", Scheme: "basic", Description: "OAuth client id and secret from OAUTH_CLIENTS"},
+	"scimToken":   {Type: "http", Scheme: "bearer", Description: "SCIM_TOKEN or the organization's token in SCIM_TOKENS"},
+}
+
+// Spec describes every route the service registers.
+func Spec() *Document {
+	registry := schemas{}
+	document := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: "User Management API", Description: description, Version: "1.0.0"},
+		Tags:    tags,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: registry,
+			Parameters: map[string]Parameter{
+				"TenantId": {Name: "X-Tenant-ID", In: "header", Description: "Id or slug of the organization to work in", Schema: str()},
+			},
+			Examples:        errorExamples(),
+			SecuritySchemes: securitySchemes,
+		},
+	}
+
+	registerShared(registry)
+	for _, route := range serviceRoutes() {
+		document.add(route, false)
+	}
+	for _, route := range apiRoutes(registry) {
+		document.add(route, true)
+	}
+	return document
+}
+
+// registerShared adds the schemas the routes refer to by name.
+func registerShared(registry schemas) {
+	codes := []interface{}{}
+	for _, code := range apperrors.Codes() {
+		codes = append(codes, string(code))
+	}
+	registry["ErrorCode"] = &Schema{Type: "string", Description: "Stable error code, safe to branch on", Enum: codes}
+
+	registry["Error"] = object(fields{
+		"status":  &Schema{Type: "integer", Description: "HTTP status"},
+		"message": &Schema{Type: "string", Enum: []interface{}{"error"}},
+		"data": object(fields{
+			"data":       &Schema{Type: "string", Description: "Message in the language of the response"},
+			"code":       Ref("ErrorCode"),
+			"request_id": &Schema{Type: "string", Description: "Also in the X-Request-ID header and the logs"},
+			"details": &Schema{
+				Description: "Invalid fields for validation_failed and invalid_request, violated rules for password_policy",
+				OneOf:       []*Schema{array(registry.of(apperrors.FieldError{})), array(registry.of(models.PasswordViolation{})), {Type: "object"}},
+			},
+		}, "data", "code", "request_id"),
+	}, "status", "message", "data")
+
+	registry["Session"] = object(fields{
+		"status":       str(),
+		"access_token": str(),
+	}, "status", "access_token")
+	registry["KeyRegistrationRequired"] = object(fields{
+		"status":       &Schema{Type: "string", Enum: []interface{}{"webauthn_registration_required"}},
+		"access_token": &Schema{Type: "string", Description: "Only accepted by the security key registration routes"},
+	}, "status", "access_token")
+	registry["SecondFactorRequired"] = object(fields{
+		"status": &Schema{Type: "string", Enum: []interface{}{"mfa_required"}},
+		"data":   &Schema{Type: "object", Description: "WebAuthn assertion options, see CredentialAssertion"},
+	}, "status", "data")
+
+	user := registry.of(models.UserResponse{})
+	registry["Me"] = object(fields{
+		"status": str(),
+		"data": object(fields{
+			"user":         user,
+			"impersonator": user,
+			"permissions":  &Schema{Type: "array", Items: str(), Description: "Granted by the user's group and the groups of their active memberships, with what those inherit"},
+			"memberships":  array(registry.of(models.GroupMembership{})),
+		}, "user", "permissions"),
+	}, "status", "data")
+
+	check := object(fields{"status": str(), "duration_ms": &Schema{Type: "number"}})
+	registry["Readiness"] = object(fields{
+		"status": &Schema{Type: "string", Enum: []interface{}{"ok", "fail"}},
+		"checks": &Schema{Type: "object", AdditionalProperties: check},
+	})
+}
+
+func (document *Document) add(route route, tenant bool) {
+	operation := &Operation{
+		Tags:        []string{route.tag},
+		Summary:     route.summary,
+		Description: route.description,
+		OperationId: route.id,
+		RequestBody: route.body,
+		Responses:   map[string]Response{},
+	}
+
+	path, parameters := pathParameters(route.path)
+	operation.Parameters = append(parameters, route.parameters...)
+	if tenant {
+		operation.Parameters = append(operation.Parameters, Parameter{Ref: "#/components/parameters/TenantId"})
+	}
+
+	failures := append([]apperrors.Code{}, route.errors...)
+	excluded := map[string]bool{}
+	for _, guard := range route.guards {
+		if guard.security != nil && operation.Security == nil {
+			operation.Security = guard.security
+		}
+		if guard.excludes != "" {
+			excluded[guard.excludes] = true
+		}
+		failures = append(failures, guard.errors...)
+	}
+	operation.Security = withoutSchemes(operation.Security, excluded)
+
+	if route.status != 0 {
+		success := Response{Description: http.StatusText(route.status), Headers: route.headers}
+		if route.response != nil {
+			success.Content = jsonContent(route.response)
+		}
+		operation.Responses[statusKey(route.status)] = success
+	}
+
+	// every route of routes.Routes runs behind ResolveTenant, whatever its own error format
+	if tenant {
+		failures = append(failures, apperrors.OrganizationNotFound, apperrors.OrganizationDisabled)
+	}
+	if len(failures) > 0 {
+		for status, response := range errorResponses(append(failures, apperrors.InternalError)) {
+			operation.Responses[status] = response
+		}
+	}
+
+	for status, response := range route.responses {
+		operation.Responses[status] = response
+	}
+
+	if document.Paths[path] == nil {
+		document.Paths[path] = PathItem{}
+	}
+	document.Paths[path][strings.ToLower(route.method)] = operation
+}
+
+// pathParameters turns the gin parameters of a path into OpenAPI ones.
+func pathParameters(ginPath string) (string, []Parameter) {
+	var parameters []Parameter
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			segments[i] = "{" + name + "}"
+			parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: str()})
+		}
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+func withoutSchemes(security []SecurityRequirement, excluded map[string]bool) []SecurityRequirement {
+	if len(excluded) == 0 {
+		return security
+	}
+
+	kept := []SecurityRequirement{}
+	for _, requirement := range security {
+		allowed := true
+		for scheme := range requirement {
+			allowed = allowed && !excluded[scheme]
+		}
+		if allowed {
+			kept = append(kept, requirement)
+		}
+	}
+	return kept
+}
+
+// errorResponses groups codes by their status, with an example body for every code.
+func errorResponses(codes []apperrors.Code) map[string]Response {
+	byStatus := map[int][]apperrors.Code{}
+	seen := map[apperrors.Code]bool{}
+	for _, code := range codes {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		status := apperrors.New(code).Status
+		byStatus[status] = append(byStatus[status], code)
+	}
+
+	responses := map[string]Response{}
+	for status, codes := range byStatus {
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+		names := make([]string, len(codes))
+		examples := map[string]Example{}
+		for i, code := range codes {
+			names[i] = string(code)
+			examples[string(code)] = Example{Ref: "#/components/examples/" + string(code)}
+		}
+
+		responses[statusKey(status)] = Response{
+			Description: http.StatusText(status) + ": " + strings.Join(names, ", "),
+			Content:     map[string]MediaType{"application/json": {Schema: Ref("Error"), Examples: examples}},
+		}
+	}
+	return responses
+}
+
+// errorExamples holds the body of every error code, with its message in Indonesian.
+func errorExamples() map[string]Example {
+	examples := map[string]Example{}
+	for _, code := range apperrors.Codes() {
+		status := apperrors.New(code).Status
+		examples[string(code)] = Example{Value: models.RequestResponse{
+			Status:  status,
+			Message: "error",
+			Data: map[string]interface{}{
+				"data":       i18n.Message(i18n.Indonesian, code),
+				"code":       code,
+				"request_id": "5f0c6a8e9b1d4c2aa0e3c7d1b2f4e6a8",
+			},
+		}}
+	}
+	return examples
+}
+
+// Check reports the registered routes the document does not describe and the documented routes
+// nobody registers, so the two cannot drift apart.
+func Check(routes gin.RoutesInfo) error {
+	document := Spec()
+
+	registered := map[string]bool{}
+	var problems []string
+	for _, route := range routes {
+		path, _ := pathParameters(route.Path)
+		key := route.Method + " " + path
+		registered[key] = true
+		if _, found := document.Paths[path][strings.ToLower(route.Method)]; !found {
+			problems = append(problems, "undocumented route "+key)
+		}
+	}
+
+	for path, item := range document.Paths {
+		for method := range item {
+			key := strings.ToUpper(method) + " " + path
+			if !registered[key] {
+				problems = append(problems, "documented route "+key+" is not registered")
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New("openapi: " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+type fields map[string]*Schema
+
+func object(properties fields, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+func str() *Schema {
+	return &Schema{Type: "string"}
+}
+
+func array(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// envelope is the body of a successful response, models.RequestResponse with data under data.
+func envelope(data *Schema) *Schema {
+	return envelopeWith(fields{"data": data})
+}
+
+func envelopeWith(data fields) *Schema {
+	return object(fields{
+		"status":  &Schema{Type: "integer"},
+		"message": &Schema{Type: "string", Enum: []interface{}{"success"}},
+		"data":    object(data, "data"),
+	}, "status", "message", "data")
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: jsonContent(schema)}
+}
+
+// formBody is the RFC 7009 and 7662 request; the client may authenticate in the form as well.
+func formBody() *RequestBody {
+	form := object(fields{
+		"token":           str(),
+		"token_type_hint": &Schema{Type: "string", Description: "access_token or refresh_token, other hints are ignored"},
+		"client_id":       str(),
+		"client_secret":   str(),
+	}, "token")
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/x-www-form-urlencoded": {Schema: form}}}
+}
+
+func query(name string, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: str()}
+}
+
+var setCookie = map[string]Header{
+	"Set-Cookie": {Description: "Session cookies", Schema: str()},
+}
+
+func oauthError(registry schemas, description string) Response {
+	return Response{Description: description, Content: jsonContent(registry.of(models.OAuthError{}))}
+}
+
+func scimContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/scim+json": {Schema: schema}}
+}
+
+func scimError(registry schemas, status int) Response {
+	return Response{Description: http.StatusText(status), Content: scimContent(registry.of(models.ScimError{}))}
+}
+
+// scimList is a models.ScimListResponse of resources.
+func scimList(resource *Schema) *Schema {
+	return object(fields{
+		"schemas":      array(str()),
+		"totalResults": &Schema{Type: "integer"},
+		"startIndex":   &Schema{Type: "integer"},
+		"itemsPerPage": &Schema{Type: "integer"},
+		"Resources":    array(resource),
+	}, "schemas", "totalResults", "Resources")
+}
+
+func statusKey(status int) string {
+	return strconv.Itoa(status)
+}
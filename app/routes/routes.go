@@ -1,27 +1,80 @@
-package routes
-
-import (
-	"github.com/RadenAbror/UserManagement/app/controllers"
-	"github.com/RadenAbror/UserManagement/app/helpers"
-	"github.com/gin-gonic/gin"
-)
-
-func Routes(router *gin.Engine) {
-	router.POST("/user/create", controllers.CreateUser())
-	router.POST("/user/auth", controllers.AuthUser())
-	router.POST("/authorization", helpers.Authorization(), controllers.GetMe())
-	router.GET("/user/read/:userId", helpers.AuthorizationUser(), controllers.GetAUser())
-	router.PUT("/user/update/:userId", helpers.AuthorizationUser(), controllers.EditAUser())
-	router.DELETE("/user/delete/:userId", helpers.AuthorizationUser(), controllers.DeleteAUser())
-	router.GET("/users", helpers.AuthorizationUser(), controllers.GetAllUsers())
-	router.GET("/me", helpers.AuthorizationUser(), controllers.GetMe())
-	router.GET("/user/logout", helpers.AuthorizationUser(), controllers.LogoutUser)
-
-	router.POST("/level/create", helpers.AuthorizationUser(), controllers.CreateLevel())
-	router.GET("/levels", helpers.AuthorizationUser(), controllers.DataLevel())
-	router.GET("/level/read/:levelId", helpers.AuthorizationUser(), controllers.ReadLevel())
-	router.PUT("/level/update/:levelId", helpers.AuthorizationUser(), controllers.UpdateLevel())
-	router.DELETE("/level/delete/:levelId", helpers.AuthorizationUser(), controllers.DeleteLevel())
-
-	router.POST("/group/create", helpers.AuthorizationUser(), controllers.CreateGroup())
-}
+package routes
+
+import (
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/controllers"
+	"github.com/RadenAbror/UserManagement/app/helpers"
+	"github.com/gin-gonic/gin"
+)
+
+func Routes(router *gin.Engine, config config.Config) {
+	router.Use(helpers.ResolveTenant(config))
+
+	router.POST("/user/create", controllers.CreateUser(config))
+	router.POST("/user/auth", controllers.AuthUser(config))
+	router.POST("/user/password/change", controllers.ChangePassword(config))
+	router.POST("/authorization", helpers.Authorization(config), controllers.GetMe())
+	router.GET("/auth/:provider/login", controllers.SocialLogin(config))
+	router.GET("/auth/:provider/callback", controllers.SocialCallback(config))
+	router.POST("/oauth/introspect", controllers.IntrospectToken(config))
+	router.POST("/oauth/revoke", controllers.RevokeToken(config))
+	router.GET("/user/read/:userId", helpers.AuthorizationUser(config), controllers.GetAUser())
+	router.PUT("/user/update/:userId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), controllers.EditAUser(config))
+	router.DELETE("/user/delete/:userId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), controllers.DeleteAUser())
+	router.GET("/users", helpers.AuthorizationUser(config), controllers.GetAllUsers())
+	router.GET("/me", helpers.AuthorizationUser(config), controllers.GetMe())
+	router.GET("/user/logout", helpers.AuthorizationUser(config), controllers.LogoutUser)
+
+	router.POST("/auth/webauthn/login/begin", controllers.BeginWebAuthnLogin(config))
+	router.POST("/auth/webauthn/login/finish", controllers.FinishWebAuthnLogin(config))
+	router.POST("/me/webauthn/register/begin", helpers.AuthorizationRegistration(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), controllers.BeginWebAuthnRegistration(config))
+	router.POST("/me/webauthn/register/finish", helpers.AuthorizationRegistration(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), controllers.FinishWebAuthnRegistration(config))
+	router.GET("/me/webauthn/credentials", helpers.AuthorizationUser(config), controllers.ListWebAuthnCredentials())
+	router.DELETE("/me/webauthn/credentials/:credentialId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), controllers.DeleteWebAuthnCredential())
+
+	router.POST("/me/api-keys", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), controllers.CreateApiKey())
+	router.GET("/me/api-keys", helpers.AuthorizationUser(config), controllers.DataApiKey())
+	router.DELETE("/me/api-keys/:keyId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), controllers.DeleteApiKey())
+
+	router.POST("/admin/impersonate/:userId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), helpers.RequireAdmin(), controllers.ImpersonateUser(config))
+	router.GET("/admin/impersonations", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), helpers.RequireAdmin(), controllers.DataImpersonation())
+
+	router.POST("/level/create", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireAdmin(), controllers.CreateLevel())
+	router.GET("/levels", helpers.AuthorizationUser(config), controllers.DataLevel())
+	router.GET("/level/read/:levelId", helpers.AuthorizationUser(config), controllers.ReadLevel())
+	router.PUT("/level/update/:levelId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireAdmin(), controllers.UpdateLevel())
+	router.DELETE("/level/delete/:levelId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireAdmin(), controllers.DeleteLevel())
+
+	router.POST("/group/create", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireAdmin(), controllers.CreateGroup())
+	router.GET("/groups", helpers.AuthorizationUser(config), controllers.DataGroup())
+	router.GET("/group/read/:groupId", helpers.AuthorizationUser(config), controllers.ReadGroup())
+	router.PUT("/group/update/:groupId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireAdmin(), controllers.UpdateGroup())
+	router.DELETE("/group/delete/:groupId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireAdmin(), controllers.DeleteGroup())
+	router.GET("/group/ancestors/:groupId", helpers.AuthorizationUser(config), controllers.GroupAncestors())
+	router.GET("/group/descendants/:groupId", helpers.AuthorizationUser(config), controllers.GroupDescendants())
+	router.GET("/group/members/:groupId", helpers.AuthorizationUser(config), helpers.RequireGroupManager("groupId"), controllers.GroupMembers())
+	router.POST("/group/members/:groupId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireGroupManager("groupId"), controllers.AddGroupMember())
+	router.DELETE("/group/members/:groupId/:userId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.RequireGroupManager("groupId"), controllers.RemoveGroupMember())
+
+	router.POST("/organization/create", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), helpers.RequireSuperAdmin(), controllers.CreateOrganization())
+	router.GET("/organizations", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), helpers.RequireSuperAdmin(), controllers.DataOrganization())
+	router.GET("/organization/read/:organizationId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), helpers.RequireSuperAdmin(), controllers.ReadOrganization())
+	router.PUT("/organization/update/:organizationId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), helpers.RequireSuperAdmin(), controllers.UpdateOrganization(config))
+	router.DELETE("/organization/delete/:organizationId", helpers.AuthorizationUser(config), helpers.DenyApiKey(), helpers.DenyImpersonation(), helpers.RequireSuperAdmin(), controllers.DeleteOrganization(config))
+
+	scim := router.Group("/scim/v2", helpers.ScimAuthorization(config))
+	scim.GET("/ServiceProviderConfig", controllers.ScimServiceProviderConfig())
+	scim.GET("/ResourceTypes", controllers.ScimResourceTypes())
+	scim.GET("/Users", controllers.ScimListUsers())
+	scim.POST("/Users", controllers.ScimCreateUser(config))
+	scim.GET("/Users/:id", controllers.ScimGetUser())
+	scim.PUT("/Users/:id", controllers.ScimReplaceUser(config))
+	scim.PATCH("/Users/:id", controllers.ScimPatchUser(config))
+	scim.DELETE("/Users/:id", controllers.ScimDeleteUser())
+	scim.GET("/Groups", controllers.ScimListGroups())
+	scim.POST("/Groups", controllers.ScimCreateGroup())
+	scim.GET("/Groups/:id", controllers.ScimGetGroup())
+	scim.PUT("/Groups/:id", controllers.ScimReplaceGroup())
+	scim.PATCH("/Groups/:id", controllers.ScimPatchGroup())
+	scim.DELETE("/Groups/:id", controllers.ScimDeleteGroup())
+}
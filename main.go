@@ -1,32 +1,104 @@
-package main
-
-import (
-	"github.com/RadenAbror/UserManagement/app/config"
-	"github.com/RadenAbror/UserManagement/app/routes"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-)
-
-var (
-	server *gin.Engine
-)
-
-func main() {
-
-	server = gin.Default()
-
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"http://localhost:1224", "http://localhost:3000"}
-	corsConfig.AllowCredentials = true
-
-	server.Use(cors.New(corsConfig))
-
-	// run database
-	config.ConnectDB()
-
-	// route link
-	routes.Routes(server)
-
-	// run server
-	server.Run("localhost:1224")
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/RadenAbror/UserManagement/app/bootstrap"
+	"github.com/RadenAbror/UserManagement/app/config"
+	"github.com/RadenAbror/UserManagement/app/migrations"
+)
+
+// exit statuses, so a supervisor can tell a bad deployment from a dependency outage
+const (
+	exitOK        = 0
+	exitFailure   = 1
+	exitConfig    = 2
+	exitDatabase  = 3
+	exitMigration = 4
+	exitServer    = 5
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app, err := bootstrap.New(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer app.Close()
+
+	// "migrate" applies pending migrations and exits, "migrate status" lists the applied ones
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if len(os.Args) > 2 && os.Args[2] == "status" {
+			if err := migrationStatus(ctx, app); err != nil {
+				return fail(err)
+			}
+			return exitOK
+		}
+		return fail(app.Migrate(ctx))
+	}
+
+	if err := app.Migrate(ctx); err != nil {
+		return fail(err)
+	}
+
+	return fail(app.Run(ctx))
+}
+
+func fail(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	slog.Error("exiting", "error", err)
+	switch {
+	case errors.Is(err, bootstrap.ErrConfig):
+		return exitConfig
+	case errors.Is(err, bootstrap.ErrDatabase):
+		return exitDatabase
+	case errors.Is(err, bootstrap.ErrMigration):
+		return exitMigration
+	case errors.Is(err, bootstrap.ErrServer):
+		return exitServer
+	}
+	return exitFailure
+}
+
+func migrationStatus(ctx context.Context, app *bootstrap.App) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	records, err := migrations.Status(ctx, config.GetDatabase(app.Client))
+	if err != nil {
+		return err
+	}
+
+	applied := map[int]migrations.AppliedMigration{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	for _, migration := range migrations.All() {
+		state := "pending"
+		if record, ok := applied[migration.Version]; ok {
+			state = "started " + record.StartedAt.Format(time.RFC3339) + ", not finished"
+			if record.AppliedAt != nil {
+				state = "applied " + record.AppliedAt.Format(time.RFC3339)
+			}
+		}
+		fmt.Printf("%4d  %-55s %s\n", migration.Version, migration.Description, state)
+	}
+	return nil
+}